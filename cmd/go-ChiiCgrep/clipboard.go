@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// copyToClipboard は data をシステムのクリップボードに書き込みます。
+// isHTML の場合は、対応する環境ではHTML形式として書き込み、メールなどに書式付きで貼り付けられるようにします。
+func copyToClipboard(data []byte, isHTML bool) error {
+	cmd, err := clipboardCommand(runtime.GOOS, isHTML)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return fmt.Errorf("%s: %v: %s", cmd.Path, err, msg)
+		}
+		return fmt.Errorf("%s: %v", cmd.Path, err)
+	}
+	return nil
+}
+
+// clipboardCommand は goos で標準入力の内容をクリップボードに書き込むコマンドを組み立てます。
+// Linux などでは Wayland の wl-copy、X11 の xclip、xsel の順に利用できるものを使います。
+func clipboardCommand(goos string, isHTML bool) (*exec.Cmd, error) {
+	switch goos {
+	case "windows":
+		// clip.exe は UTF-8 の日本語を正しく扱えないため、PowerShell で UTF-8 として読み込む
+		script := "[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())"
+		if isHTML {
+			script += " -AsHtml"
+		}
+		return exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script), nil
+	case "darwin":
+		return exec.Command("pbcopy"), nil
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if path, err := exec.LookPath("wl-copy"); err == nil {
+			if isHTML {
+				return exec.Command(path, "--type", "text/html"), nil
+			}
+			return exec.Command(path), nil
+		}
+	}
+	if path, err := exec.LookPath("xclip"); err == nil {
+		if isHTML {
+			return exec.Command(path, "-selection", "clipboard", "-t", "text/html"), nil
+		}
+		return exec.Command(path, "-selection", "clipboard"), nil
+	}
+	if path, err := exec.LookPath("xsel"); err == nil {
+		return exec.Command(path, "--clipboard", "--input"), nil
+	}
+	return nil, errors.New("no clipboard command found (install wl-clipboard, xclip or xsel)")
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFlags は各サブコマンドに共通の -config、-profile、-search の値を保持します。
+type configFlags struct {
+	path    string
+	profile string
+	search  string
+	// unknownKeys は設定ファイルのうち、どのサブコマンドのフラグにも対応しないキーについての警告です。
+	// -quiet や -log-file などのログの設定を反映してから出力するため、apply では出力せずに保持します。
+	unknownKeys []string
+}
+
+// register は -config、-profile、-search を fs に登録します。
+func (c *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "config", "", "Path to a YAML config file providing default option values (default: .chiicgrep.yaml in the current or home directory).")
+	fs.StringVar(&c.profile, "profile", "", "Name of the profile in the config file to use.")
+	fs.StringVar(&c.search, "search", "", "Name of a saved search in the config file's 'searches' section to run.")
+}
+
+// envPrefix は既定値を与える環境変数の接頭辞です。フラグ -trim-cells には CHIICGREP_TRIM_CELLS が対応します。
+const envPrefix = "CHIICGREP_"
+
+// defaultConfigName はカレントフォルダまたはホームフォルダから自動で読み込む設定ファイルの名前です。
+const defaultConfigName = ".chiicgrep.yaml"
+
+// apply は環境変数と設定ファイルの値を fs のフラグに反映します。
+// 優先順位はコマンドライン引数 > 環境変数 > 設定ファイルです。
+// -config が指定されていない場合は、カレントフォルダ、ホームフォルダの順に .chiicgrep.yaml を探します。
+// fs.Parse の後に呼び出してください。
+func (c *configFlags) apply(fs *flag.FlagSet) error {
+	if err := applyEnv(fs, os.Environ()); err != nil {
+		return err
+	}
+	path := c.path
+	if path == "" {
+		path = findDefaultConfig()
+	}
+	if path == "" {
+		if c.profile != "" {
+			return fmt.Errorf("-profile requires -config or a %s file", defaultConfigName)
+		}
+		if c.search != "" {
+			return fmt.Errorf("-search requires -config or a %s file", defaultConfigName)
+		}
+		return nil
+	}
+	fc, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	c.unknownKeys = fc.unknownKeys(path)
+	return fc.apply(fs, c.profile, c.search)
+}
+
+// warnUnknownKeys は apply で見つかった設定ファイルの不明なキーを警告します。
+// ログの設定が反映されるよう、logging.apply の後に呼び出してください。
+func (c *configFlags) warnUnknownKeys() {
+	for _, msg := range c.unknownKeys {
+		warnf("%s", msg)
+	}
+}
+
+// applyEnv は env のうち CHIICGREP_ で始まる環境変数の値を、対応するフラグに設定します。
+// コマンドラインで指定されたフラグと、このサブコマンドにないフラグに対応する変数は無視します。
+// 設定したフラグは指定済みとして扱われるため、設定ファイルの値で上書きされません。
+func applyEnv(fs *flag.FlagSet, env []string) error {
+	explicit := explicitFlags(fs)
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutPrefix(key, envPrefix)
+		if !ok || value == "" {
+			continue
+		}
+		name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+		if explicit[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for environment variable %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// findDefaultConfig はカレントフォルダ、ホームフォルダの順に .chiicgrep.yaml を探し、最初に見つかったパスを返します。
+// 見つからない場合は空文字列を返します。
+func findDefaultConfig() string {
+	var dirs []string
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, defaultConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// fileConfig は設定ファイルの内容を保持します。
+// トップレベルのキーと各プロファイル、各保存済み検索のキーは、コマンドラインのフラグ名（先頭の - を除いたもの）に対応します。
+// 保存済み検索の説明を書く description 以外で、どのサブコマンドのフラグにも対応しないキーは警告して無視します。
+//
+//	font: メイリオ
+//	profiles:
+//	  monthly-audit:
+//	    in: C:\data\monthly
+//	    cols: [氏名, 住所, 備考]
+//	    target: 重要
+//	searches:
+//	  failed-payments:
+//	    description: 決済が失敗した注文
+//	    cols: [注文番号, 金額, ステータス]
+//	    target: 失敗
+//	    highlight-if: [金額>=100000]
+type fileConfig struct {
+	Defaults map[string]any
+	Profiles map[string]map[string]any
+	Searches map[string]map[string]any
+}
+
+// loadConfigFile はYAML形式の設定ファイルを読み込みます。
+func loadConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	cfg := &fileConfig{Defaults: raw}
+	if cfg.Profiles, err = configSection(raw, "profiles", "profile", path); err != nil {
+		return nil, err
+	}
+	if cfg.Searches, err = configSection(raw, "searches", "search", path); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadThemeFile はHTMLレポートの配色を指定するYAML形式のファイルを読み込みます。
+// ファイルはCSSの変数の名前（chiicgrep.ThemeVariables）と色の対応です。
+func loadThemeFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read theme file %s: %w", path, err)
+	}
+	theme := map[string]string{}
+	if err := yaml.Unmarshal(data, &theme); err != nil {
+		return nil, fmt.Errorf("could not parse theme file %s: %w", path, err)
+	}
+	return theme, nil
+}
+
+// configSection は raw から名前付きの設定の集まり key を取り出し、raw から取り除きます。
+// kind はエラーメッセージで1つの設定を指す名前です。
+func configSection(raw map[string]any, key, kind, path string) (map[string]map[string]any, error) {
+	section := map[string]map[string]any{}
+	v, ok := raw[key]
+	if !ok {
+		return section, nil
+	}
+	delete(raw, key)
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("config file %s: '%s' must be a mapping", path, key)
+	}
+	for name, v := range m {
+		values, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("config file %s: %s '%s' must be a mapping", path, kind, name)
+		}
+		section[name] = values
+	}
+	return section, nil
+}
+
+// sectionNames は名前付きの設定の名前を名前順で返します。
+func sectionNames(section map[string]map[string]any) []string {
+	names := make([]string, 0, len(section))
+	for name := range section {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply は設定ファイルの値を fs のフラグに反映します。
+// 優先順位はコマンドライン引数 > 保存済み検索 > プロファイル > トップレベルの既定値です。
+func (c *fileConfig) apply(fs *flag.FlagSet, profile, search string) error {
+	explicit := explicitFlags(fs)
+
+	// 優先するものを先に適用し、後の層の値で上書きしないようにする
+	var layers []map[string]any
+	if search != "" {
+		values, ok := c.Searches[search]
+		if !ok {
+			return fmt.Errorf("saved search '%s' not found (available: %s)", search, strings.Join(sectionNames(c.Searches), ", "))
+		}
+		layers = append(layers, values)
+	}
+	if profile != "" {
+		values, ok := c.Profiles[profile]
+		if !ok {
+			return fmt.Errorf("profile '%s' not found (available: %s)", profile, strings.Join(sectionNames(c.Profiles), ", "))
+		}
+		layers = append(layers, values)
+	}
+	layers = append(layers, c.Defaults)
+
+	applied := make(map[string]bool)
+	for _, layer := range layers {
+		for name, v := range layer {
+			if explicit[name] || applied[name] {
+				continue
+			}
+			f := fs.Lookup(name)
+			if f == nil {
+				// 設定ファイルは全サブコマンドで共有されるため、このサブコマンドにないキーは無視する
+				continue
+			}
+			if err := setConfigValue(f, v); err != nil {
+				return fmt.Errorf("invalid value for '%s' in config file: %w", name, err)
+			}
+			applied[name] = true
+		}
+	}
+	return nil
+}
+
+// configOnlyKeys はフラグに対応しないが、設定ファイルに書いてよいキーです。
+var configOnlyKeys = map[string]bool{"description": true}
+
+// knownConfigKeys は設定ファイルに書けるキーの集合を返します。
+// 設定ファイルは全サブコマンドで共有されるため、いずれかのサブコマンドのフラグ名であれば有効です。
+func knownConfigKeys() map[string]bool {
+	known := make(map[string]bool)
+	for key := range configOnlyKeys {
+		known[key] = true
+	}
+	flagSets := []func() *flag.FlagSet{
+		func() *flag.FlagSet { fs, _ := defineExtractFlags(); return fs },
+		func() *flag.FlagSet { fs, _ := defineStatsFlags(); return fs },
+		func() *flag.FlagSet { fs, _ := defineDiffFlags(); return fs },
+		func() *flag.FlagSet { fs, _ := defineInspectFlags(); return fs },
+		func() *flag.FlagSet { fs, _ := defineServeFlags(); return fs },
+	}
+	for _, define := range flagSets {
+		define().VisitAll(func(f *flag.Flag) { known[f.Name] = true })
+	}
+	return known
+}
+
+// unknownKeys はどのサブコマンドのフラグにも対応しないキーについての警告を返します。
+// キーの書き間違い（max-results を max-result と書くなど）で設定が黙って無視されないようにします。
+func (c *fileConfig) unknownKeys(path string) []string {
+	known := knownConfigKeys()
+	var warnings []string
+	check := func(where string, values map[string]any) {
+		for _, name := range sortedKeys(values) {
+			if !known[name] {
+				warnings = append(warnings, fmt.Sprintf("config file %s: unknown key '%s'%s is ignored", path, name, where))
+			}
+		}
+	}
+	check("", c.Defaults)
+	for _, name := range sectionNames(c.Profiles) {
+		check(fmt.Sprintf(" in profile '%s'", name), c.Profiles[name])
+	}
+	for _, name := range sectionNames(c.Searches) {
+		check(fmt.Sprintf(" in search '%s'", name), c.Searches[name])
+	}
+	return warnings
+}
+
+// sortedKeys は values のキーを名前順で返します。
+func sortedKeys(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setConfigValue は設定ファイルの値 v をフラグ f に設定します。
+// 複数回指定できるフラグにリストを与えた場合は、要素ごとに指定したものとして扱います。
+func setConfigValue(f *flag.Flag, v any) error {
+	if list, ok := v.([]any); ok {
+		if _, repeatable := f.Value.(*stringList); repeatable {
+			for _, item := range list {
+				if err := f.Value.Set(fmt.Sprint(item)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return f.Value.Set(configValueString(v))
+}
+
+// configValueString は設定ファイルの値をフラグに渡す文字列に変換します。
+// リストはカンマ区切りで連結されます。
+func configValueString(v any) string {
+	if list, ok := v.([]any); ok {
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// diffOptions は diff サブコマンドの設定を保持します。
+// OldPath と NewPath 以外の入力の設定は、両方の入力に共通して適用されます。
+type diffOptions struct {
+	chiicgrep.Config
+	OldPath string
+	NewPath string
+	Key     string
+	OutFile string
+	Format  string
+	// NoMkdir は -out のフォルダがない場合に作成せず、エラーとします（-no-mkdir）。
+	NoMkdir bool
+	// Force は既存の出力ファイルを確認せずに上書きします（-force）。
+	Force bool
+	// KeepPrev は置き換える前の出力ファイルを残します（-keep-prev）。
+	KeepPrev bool
+}
+
+// parseDiffFlags は diff サブコマンドの引数を解析します。
+func parseDiffFlags(args []string) diffOptions {
+	fs, finish := defineDiffFlags()
+	fs.Parse(args)
+	return finish()
+}
+
+// defineDiffFlags は diff サブコマンドのフラグを登録した FlagSet と、fs.Parse の後に呼び出して
+// オプションを組み立てる関数を返します。
+func defineDiffFlags() (*flag.FlagSet, func() diffOptions) {
+	var opts diffOptions
+	var columnsStr string
+	var colMaps stringList
+	var conf configFlags
+	var logging logFlags
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.StringVar(&opts.OldPath, "old", "", "Path to the old CSV file or directory.")
+	fs.StringVar(&opts.NewPath, "new", "", "Path to the new CSV file or directory.")
+	fs.StringVar(&opts.Key, "key", "", "Column that identifies the same row in both inputs.")
+	fs.StringVar(&columnsStr, "cols", "", "Comma-separated list of columns to compare (name or name:label; default: all columns).")
+	fs.StringVar(&opts.SearchTarget, "target", "", "Only compare rows containing this string.")
+	fs.BoolVar(&opts.Recursive, "r", false, "Search for CSV files recursively in subdirectories.")
+	fs.BoolVar(&opts.NoIgnore, "no-ignore", false, "Process all CSV files, ignoring exclusions in "+chiicgrep.IgnoreFileName+" files.")
+	fs.BoolVar(&opts.TrimCells, "trim-cells", false, "Strip leading/trailing whitespace (including full-width spaces) from headers and values.")
+	fs.Var(&colMaps, "col-map", colMapUsage)
+	fs.StringVar(&opts.OutFile, "out", "", "Path to the output file (optional).")
+	fs.BoolVar(&opts.NoMkdir, "no-mkdir", false, "Fail instead of creating missing directories in the -out path.")
+	fs.BoolVar(&opts.Force, "force", false, "Overwrite an existing -out file without asking (otherwise asks on a terminal and refuses elsewhere).")
+	fs.BoolVar(&opts.KeepPrev, "keep-prev", false, "Keep the previous -out file as <name>.prev.<ext> (e.g. report.prev.html) when replacing it.")
+	fs.StringVar(&opts.Format, "format", "", "Output format: html or text (default: html with -out, text otherwise).")
+	fs.IntVar(&opts.Retry, "retry", 0, "Number of times to retry opening a file locked by another process (e.g. open in Excel).")
+	fs.DurationVar(&opts.RetryWait, "retry-wait", time.Second, "Wait before the first retry of a locked file; doubled on each retry.")
+	fs.IntVar(&opts.Jobs, "jobs", runtime.NumCPU(), "Number of files to process concurrently.")
+	conf.register(fs)
+	logging.register(fs)
+	registerAliases(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s diff -old <path> -new <path> -key <column> [options]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Lists rows added, removed and changed between two CSV files or directories.")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+
+	return fs, func() diffOptions {
+
+		if err := conf.apply(fs); err != nil {
+			fatalf("Error: %v", err)
+		}
+		opts.ColumnMaps = parseColumnMaps(colMaps)
+		if err := logging.apply(); err != nil {
+			fatalf("Error: %v", err)
+		}
+		conf.warnUnknownKeys()
+		if opts.OldPath == "" || opts.NewPath == "" || opts.Key == "" {
+			fs.Usage()
+			os.Exit(exitError)
+		}
+		if columnsStr != "" {
+			opts.Columns = chiicgrep.ParseColumns(columnsStr)
+		}
+		if opts.Format == "" {
+			opts.Format = "text"
+			if opts.OutFile != "" {
+				opts.Format = "html"
+			}
+		}
+		switch opts.Format {
+		case "html", "text":
+		default:
+			fatalf("Error: unknown output format %q", opts.Format)
+		}
+		if opts.OutFile != "" {
+			out, err := expandOutputPath(opts.OutFile, opts.NewPath, time.Now())
+			if err != nil {
+				fatalf("Error: -out: %v", err)
+			}
+			opts.OutFile = out
+			if err := checkOverwrite([]string{opts.OutFile}, opts.Force, true); err != nil {
+				fatalf("Error: %v", err)
+			}
+			if err := prepareOutputDirs([]string{opts.OutFile}, !opts.NoMkdir); err != nil {
+				fatalf("Error: %v", err)
+			}
+		}
+		return opts
+	}
+}
+
+// runDiff は diff サブコマンドを実行します。
+// diff(1) と同様に、差分がなければ 0、差分があれば 1、エラーの場合は 2 で終了します。
+func runDiff(args []string) {
+	opts := parseDiffFlags(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), interruptSignals...)
+	defer stop()
+
+	oldCfg, newCfg := opts.Config, opts.Config
+	oldCfg.InputPath, newCfg.InputPath = opts.OldPath, opts.NewPath
+	res, err := chiicgrep.Diff(ctx, oldCfg, newCfg, opts.Key)
+	if err != nil {
+		if ctx.Err() != nil {
+			stop()
+			log.Println("Interrupted: nothing was written.")
+			os.Exit(exitInterrupted)
+		}
+		fatalf("Error: %v", err)
+	}
+	if err := writeDiff(opts, res); err != nil {
+		fatalf("Error: %v", err)
+	}
+	if res.HasDifferences() {
+		os.Exit(1)
+	}
+}
+
+// writeDiff は比較の結果を指定された形式で -out のファイルまたは標準出力に書き込みます。
+func writeDiff(opts diffOptions, res chiicgrep.DiffResult) (err error) {
+	var w io.Writer = os.Stdout
+	if opts.OutFile != "" {
+		f, ferr := createOutputFile(opts.OutFile, opts.KeepPrev)
+		if ferr != nil {
+			return ferr
+		}
+		// 最後まで書き込めた場合だけ既存のファイルを置き換える
+		defer func() {
+			if err != nil {
+				f.Abort()
+			} else {
+				err = f.Commit()
+			}
+		}()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+
+	if opts.Format == "html" {
+		err = writeDiffHTML(bw, opts, res)
+	} else {
+		writeDiffText(bw, res)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	return nil
+}
+
+// writeDiffText は追加された行を "+"、削除された行を "-"、変更された行を "~" で始めて出力します。
+// 変更された行には、値が異なった列の変更前と変更後の値を続けます。
+func writeDiffText(w io.Writer, res chiicgrep.DiffResult) {
+	for _, row := range res.Added {
+		fmt.Fprintf(w, "+ %s=%s (%s, Line: %d)\n", res.Key, row.Key, row.File, row.Line)
+	}
+	for _, row := range res.Removed {
+		fmt.Fprintf(w, "- %s=%s (%s, Line: %d)\n", res.Key, row.Key, row.File, row.Line)
+	}
+	for _, c := range res.Changed {
+		fmt.Fprintf(w, "~ %s=%s (%s, Line: %d)\n", res.Key, c.New.Key, c.New.File, c.New.Line)
+		for i, col := range res.Columns {
+			if c.Changed[i] {
+				fmt.Fprintf(w, "    %s: [%s] -> [%s]\n", col.Label, c.Old.Values[i], c.New.Values[i])
+			}
+		}
+	}
+	fmt.Fprintf(w, "Diff: %d added, %d removed, %d changed, %d unchanged\n", len(res.Added), len(res.Removed), len(res.Changed), res.Unchanged)
+}
+
+var diffTemplate = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="UTF-8">
+<title>差分レポート</title>
+<style>
+body { font-family: "Meiryo UI", "Hiragino Sans", sans-serif; margin: 1.5em; color: #222; background: #fafafa; }
+h1 { font-size: 1.4em; border-bottom: 2px solid #0097a7; padding-bottom: 0.2em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+.info { color: #555; }
+table { border-collapse: collapse; background: #fff; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.8em; white-space: pre-wrap; }
+th { background: #e0f7fa; color: #00838f; }
+tr.added td { background: #e8f5e9; }
+tr.removed td { background: #ffebee; }
+tr.changed td { background: #fffde7; }
+td.modified { background: #fff59d; font-weight: bold; }
+.old { color: #c62828; text-decoration: line-through; }
+.new { color: #2e7d32; }
+</style>
+</head>
+<body>
+<h1>差分レポート</h1>
+<p class="info">旧: {{.Old}} / 新: {{.New}} / キー: {{.Result.Key}}<br>
+追加 {{len .Result.Added}} 件、削除 {{len .Result.Removed}} 件、変更 {{len .Result.Changed}} 件、変更なし {{.Result.Unchanged}} 件</p>
+{{$cols := .Result.Columns}}{{$key := .Result.Key}}
+{{with .Result.Added}}<h2>追加された行</h2>
+<table>
+<tr><th>{{$key}}</th>{{range $cols}}<th>{{.Label}}</th>{{end}}</tr>
+{{range .}}<tr class="added"><td>{{.Key}}</td>{{range .Values}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+{{with .Result.Removed}}<h2>削除された行</h2>
+<table>
+<tr><th>{{$key}}</th>{{range $cols}}<th>{{.Label}}</th>{{end}}</tr>
+{{range .}}<tr class="removed"><td>{{.Key}}</td>{{range .Values}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+{{with .Result.Changed}}<h2>変更された行</h2>
+<table>
+<tr><th>{{$key}}</th>{{range $cols}}<th>{{.Label}}</th>{{end}}</tr>
+{{range .}}{{$c := .}}<tr class="changed"><td>{{.New.Key}}</td>{{range $i, $v := .New.Values}}{{if index $c.Changed $i}}<td class="modified"><span class="old">{{index $c.Old.Values $i}}</span> → <span class="new">{{$v}}</span></td>{{else}}<td>{{$v}}</td>{{end}}{{end}}</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// writeDiffHTML は追加、削除、変更された行を、それぞれ緑、赤、黄で色分けした表として出力します。
+func writeDiffHTML(w io.Writer, opts diffOptions, res chiicgrep.DiffResult) error {
+	return diffTemplate.Execute(w, struct {
+		Old, New string
+		Result   chiicgrep.DiffResult
+	}{opts.OldPath, opts.NewPath, res})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// runDryRun は -dry-run の処理です。入力の列挙とヘッダーの解決だけを行い、その結果を w に出力します。
+// 処理されないファイルがある場合は exitError、それ以外は exitMatch を返します。
+func runDryRun(ctx context.Context, opts options, w io.Writer) (int, error) {
+	plans, err := chiicgrep.PlanFiles(ctx, opts.Config)
+	if err != nil {
+		return exitError, err
+	}
+	writePlan(w, plans)
+	for _, p := range plans {
+		if p.Err != nil || len(p.MissingRequired) > 0 {
+			return exitError, nil
+		}
+	}
+	return exitMatch, nil
+}
+
+// writePlan はファイルごとに、見つかった列と見つからなかった列、該当するタグ付け規則を出力します。
+func writePlan(w io.Writer, plans []chiicgrep.FilePlan) {
+	processed, skipped, failed := 0, 0, 0
+	for _, p := range plans {
+		fmt.Fprintln(w, p.File)
+		switch {
+		case p.Err != nil:
+			failed++
+			fmt.Fprintf(w, "  error: %v\n", p.Err)
+			continue
+		case p.Skipped():
+			skipped++
+			fmt.Fprintln(w, "  skipped: none of the specified columns found")
+		default:
+			processed++
+		}
+		if len(p.Found) > 0 {
+			fmt.Fprintf(w, "  found:   %s\n", strings.Join(p.Found, ", "))
+		}
+		if len(p.Missing) > 0 {
+			fmt.Fprintf(w, "  missing: %s\n", strings.Join(p.Missing, ", "))
+		}
+		if len(p.MissingRequired) > 0 {
+			fmt.Fprintf(w, "  missing required: %s\n", strings.Join(p.MissingRequired, ", "))
+		}
+		for _, rule := range p.TagRules {
+			fmt.Fprintf(w, "  tag:     %s (%s)\n", rule.Tag, rule)
+		}
+	}
+	fmt.Fprintf(w, "Dry run: %d files would be processed, %d skipped, %d unreadable\n", processed, skipped, failed)
+}
@@ -0,0 +1,1143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// options はコマンドライン引数から構成される設定を保持します。
+type options struct {
+	chiicgrep.Config
+	NoColor bool
+	OutFile string
+	// ExtraOutputs は2つ目以降の -out のファイルです。形式は拡張子から決まります。
+	ExtraOutputs []string
+	// SplitByTag は -out に加えて、ファイルのタグごとのレコードを -out と同じフォルダの <タグ名>.html などに書き込みます。
+	SplitByTag bool
+	// NoMkdir は -out のフォルダがない場合に作成せず、エラーとします。
+	NoMkdir bool
+	// Force は既存の出力ファイルを確認せずに上書きします。
+	Force bool
+	// Compress は -out のファイルを gzip で圧縮し、名前の末尾に .gz を付けます。
+	Compress bool
+	// KeepPrev は置き換える前の出力ファイルを report.prev.html のような名前で残します。
+	KeepPrev bool
+	// ToClipboard は出力をクリップボードにも書き込みます。
+	ToClipboard bool
+	AfterOpen   bool
+	// Browser は -after-open でレポートを開くコマンドです。空の場合はOSの既定のアプリケーションで開きます。
+	Browser string
+	Font    string
+	Format  string
+	// TagDefs は -define-tag で追加・上書きするタグの定義です。
+	TagDefs []chiicgrep.TagDef
+	TUI     bool
+	// FilesWithMatches と CountOnly はレポートの代わりにファイル名や件数だけを出力するモードです。
+	FilesWithMatches bool
+	CountOnly        bool
+	Progress         bool
+	// DryRun は入力の列挙とヘッダーの解決だけを行い、処理の見込みを出力するモードです。
+	DryRun bool
+	// FindColumn は -cols の代わりに、このパターンに一致する列を持つファイルを探すモードのパターンです。
+	FindColumn string
+	Watch      bool
+	// LiveReload はウォッチモードでレポートを配信するライブリロードサーバーのアドレスです。
+	LiveReload string
+	// Schedule はレポートを繰り返し生成するcron式です。StatusAddr は実行の状態を返すサーバーのアドレスです。
+	Schedule   string
+	StatusAddr string
+	// OutTemplates はプレースホルダーを展開する前の -out です。-schedule では実行ごとに展開します。
+	OutTemplates []string
+	// StateFile は前回の実行で出力したレコードを記録するファイルです。前回になかったレコードに「新規」の印を付けます。
+	StateFile string
+	// NewOut は前回になかったレコードだけを出力するファイルです。NewOutTemplate はプレースホルダーを展開する前の指定です。
+	NewOut         string
+	NewOutTemplate string
+	// UploadSpec は -upload の指定です。-schedule では実行ごとの出力ファイル名から Upload を作り直します。
+	UploadSpec string
+	// ImageColumns と EmbedImages はHTMLレポートで値を画像として表示する列と、画像を埋め込むかの指定です。
+	ImageColumns []string
+	// Dashboard はHTMLレポートをダッシュボードとして出力する場合の、パネルの並びです。
+	Dashboard []chiicgrep.DashboardPanel
+	// Theme はHTMLレポートの配色を変えるCSSの変数の値です。
+	Theme map[string]string
+	// Palette はHTMLレポートの強調表示とタグの配色です。
+	Palette chiicgrep.Palette
+	// PrintLayout はHTMLレポートをA4用紙への印刷に合わせて出力します。
+	PrintLayout bool
+	// JSONColumns はHTMLレポートでJSONを整形して表示する列です。
+	JSONColumns []string
+	EmbedImages bool
+	// ShowCodes は -map で置き換えた値の元のコードを、HTMLレポートのツールチップとして表示するかの指定です。
+	ShowCodes bool
+	// MaxValueLen はHTMLレポートで値を省略して表示する文字数です。0の場合は省略しません。
+	MaxValueLen int
+	// CommandLine と Options はHTMLレポートの「レポートの情報」に記載する、実行時のコマンドラインと実際のオプションです。
+	CommandLine string
+	Options     [][2]string
+	// Mail は処理の完了後にレポートをメールで送る設定です。
+	Mail mailOptions
+	// Upload は処理の完了後に出力ファイルをアップロードする先です。
+	Upload *uploadTarget
+	// NotifyWebhook は処理の完了後に結果をPOSTするWebhookのURLです。
+	NotifyWebhook string
+}
+
+// maskKeyEnv は -mask の hash で使う鍵を指定する環境変数です。鍵はコマンドラインやレポートに残らないよう、環境変数でだけ指定できます。
+const maskKeyEnv = "CHIICGREP_MASK_KEY"
+
+// extractFlagGroups は extract の使い方で、フラグを分類して表示する順序です。
+var extractFlagGroups = []flagGroup{
+	{"Input", []string{"in", "r", "no-ignore", "dedup-files", "col-map", "col-alias", "join", "require-cols", "trim-cells", "collapse-spaces", "lazy-quotes", "allow-variable-fields", "strict", "retry", "retry-wait", "jobs"}},
+	{"Filtering", []string{"cols", "target", "filter", "in-list", "not-in-list", "where", "replace", "normalize", "context", "sort", "sort-buffer", "temp-dir", "timeline", "dedup", "dedup-by", "max-results", "max-per-file", "sample", "seed", "head", "tail"}},
+	{"Highlighting and tags", []string{"highlight-if", "tag-file", "tag-dir", "tag-match", "only-tagged", "tag-row", "define-tag", "validate", "unique-key"}},
+	{"Aggregation", []string{"aggregate", "top", "pivot"}},
+	{"Output", []string{"out", "state", "new-out", "split-by-tag", "no-mkdir", "compress", "force", "keep-prev", "format", "map", "show-codes", "mask", "max-value-len", "json-col", "font", "image-col", "embed-images", "geo-cols", "gantt", "dashboard", "theme-file", "accent-color", "palette", "print-layout", "no-color", "to-clipboard", "after-open", "browser", "l", "c", "dry-run", "find-col", "tui", "watch", "live-reload", "schedule", "status-addr", "progress"}},
+	{"Delivery", []string{"mail-to", "mail-from", "smtp-server", "mail-attach", "upload", "notify-webhook"}},
+	{"Logging and configuration", []string{"quiet", "verbose", "log-format", "log-file", "log-max-size", "config", "profile", "search", "version"}},
+}
+
+// parseExtractFlags は extract サブコマンドの引数を解析し、設定を構成します。
+func parseExtractFlags(args []string) options {
+	fs, finish := defineExtractFlags()
+	fs.Parse(args)
+	return finish()
+}
+
+// defineExtractFlags は extract サブコマンドのフラグを登録した FlagSet と、fs.Parse の後に呼び出して
+// オプションを組み立てる関数を返します。
+func defineExtractFlags() (*flag.FlagSet, func() options) {
+	var opts options
+	var columnsStr, whereStr string
+	var sampleSize, headSize, tailSize int
+	var sampleSeed int64
+	var highlightRules, filters, inLists, notInLists, colMaps, colAliases stringList
+	var tagRules, tagDirs, rowTagRules, tagDefs, validations stringList
+	var aggregates, topValues stringList
+	var masks, valueMaps, replacements stringList
+	var outFiles stringList
+	var tagMatch string
+	var onlyTagged string
+	var sortStr string
+	var dedupBy, uniqueKey string
+	var pivot string
+	var join string
+	var normalize string
+	var requiredStr string
+	var mailTo string
+	var showVersion bool
+	var imageCols, jsonCols, geoCols, ganttCols, dashboard, themeFile, accentColor, palette string
+	var upload string
+	var conf configFlags
+	var logging logFlags
+
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	fs.StringVar(&opts.InputPath, "in", "", "Path to the CSV file or directory (\"-\" reads CSV from standard input).")
+	fs.StringVar(&columnsStr, "cols", "", "Comma-separated list of column names to extract (name or name:label, in output order).")
+	fs.StringVar(&opts.SearchTarget, "target", "", "A string to filter lines by.")
+	fs.Var(&filters, "filter", "Only keep rows where a condition holds, e.g. \"予定終了日<[実績終了日]\" (repeatable; all must hold; same syntax as -highlight-if).")
+	fs.Var(&inLists, "in-list", "Only keep rows whose column value appears in a list file (one value per line), e.g. \"社員番号:ids.txt\" (repeatable).")
+	fs.Var(&notInLists, "not-in-list", "Drop rows whose column value appears in a list file (one value per line), e.g. \"社員番号:retired.txt\" (repeatable).")
+	fs.StringVar(&whereStr, "where", "", "Only keep rows matching an expression over column values, e.g. 'int(金額) > 10000 && 備考 contains \"至急\"'.")
+	fs.StringVar(&join, "join", "", "Add columns from a lookup CSV to each row by a shared key column, e.g. \"members.csv on 社員番号\".")
+	fs.StringVar(&requiredStr, "require-cols", "", "Comma-separated list of columns every file must have; violations are reported and exit with status 2.")
+	fs.Var(&highlightRules, "highlight-if", "Highlight the cell when a condition holds, e.g. \"ステータス=保留\" (repeatable; ops: = != ~ !~ < <= > >=).")
+	fs.BoolVar(&opts.TrimCells, "trim-cells", false, "Strip leading/trailing whitespace (including full-width spaces) from headers and values.")
+	fs.BoolVar(&opts.CollapseSpaces, "collapse-spaces", false, "Collapse runs of whitespace inside values into a single space (implies -trim-cells).")
+	fs.Var(&replacements, "replace", "Rewrite a column's values with a sed-style regexp before matching and output, e.g. \"パス:s|\\\\|/|g\" (flags: g, i; repeatable).")
+	fs.StringVar(&normalize, "normalize", "", "Unicode normalization applied before matching -target and -highlight-if (nfkc).")
+	fs.Var(&tagRules, "tag-file", "Tag files whose path contains a keyword or matches /regexp/, e.g. \"important:重要\" or \"warning:/error|fail/\" (repeatable).")
+	fs.Var(&tagDirs, "tag-dir", "Tag every CSV under a directory, e.g. \"archived:old_data/\"; relative paths are resolved against -in (repeatable).")
+	fs.Var(&rowTagRules, "tag-row", "Tag individual records when a condition holds, e.g. \"要確認:ステータス=保留\" (repeatable).")
+	fs.StringVar(&onlyTagged, "only-tagged", "", "Comma-separated tags; only files tagged with one of them by -tag-file/-tag-dir are processed.")
+	fs.StringVar(&tagMatch, "tag-match", "path", "What -tag-file rules match against: path (full path) or base (file name only).")
+	fs.Var(&validations, "validate", "Flag cells that break a data quality rule, e.g. \"メール:regex:^[^@]+@[^@]+$\" or \"金額:range:0..10000000\" (kinds: regex, range, len, enum, type, required; repeatable); violations exit with status 2.")
+	fs.StringVar(&uniqueKey, "unique-key", "", "Comma-separated key columns whose values must not repeat across the whole run; duplicates are listed with links to each row and exit with status 2.")
+	fs.Var(&tagDefs, "define-tag", "Define or recolor a tag for the HTML report, e.g. \"urgent:#ff0000\" (repeatable).")
+	fs.BoolVar(&opts.Recursive, "r", false, "Search for CSV files recursively in subdirectories.")
+	fs.BoolVar(&opts.NoIgnore, "no-ignore", false, "Process all CSV files, ignoring exclusions in "+chiicgrep.IgnoreFileName+" files.")
+	fs.BoolVar(&opts.DedupFiles, "dedup-files", false, "Process files with identical content only once, noting the skipped copies next to the processed file.")
+	fs.Var(&colMaps, "col-map", colMapUsage)
+	fs.Var(&colAliases, "col-alias", colAliasUsage)
+	fs.BoolVar(&opts.NoColor, "no-color", false, "Disable color output.")
+	fs.Var(&outFiles, "out", "Path to the output file (optional; repeatable, additional files get their format from the extension: .html, .json, .csv, .tsv, .txt).")
+	fs.BoolVar(&opts.Compress, "compress", false, "Compress -out files with gzip and add .gz to their names (e.g. report.html.gz); names already ending in .gz are always compressed.")
+	fs.StringVar(&opts.StateFile, "state", "", "Remember the records written by this run in this file and mark records not seen in the previous run as 新規 (new).")
+	fs.StringVar(&opts.NewOut, "new-out", "", "With -state, also write only the new records to this file (format from the extension, placeholders as in -out).")
+	fs.BoolVar(&opts.SplitByTag, "split-by-tag", false, "Also write each file tag's records to <tag>.<ext> next to -out (e.g. important.html), plus untagged.<ext> for untagged files.")
+	fs.BoolVar(&opts.NoMkdir, "no-mkdir", false, "Fail instead of creating missing directories in the -out path.")
+	fs.BoolVar(&opts.Force, "force", false, "Overwrite existing -out files without asking (otherwise asks on a terminal and refuses elsewhere).")
+	fs.BoolVar(&opts.KeepPrev, "keep-prev", false, "Keep the previous -out file as <name>.prev.<ext> (e.g. report.prev.html) when replacing it.")
+	fs.BoolVar(&opts.ToClipboard, "to-clipboard", false, "Copy the output to the clipboard instead of standard output (HTML with -format html, otherwise tab-separated rows).")
+	fs.BoolVar(&opts.AfterOpen, "after-open", false, "Open the output file after processing (requires -out).")
+	fs.StringVar(&opts.Browser, "browser", "", "Command used by -after-open to open the report, with optional arguments, e.g. \"firefox --new-window\" (default: the OS default application).")
+	fs.Var(&valueMaps, "map", "Replace coded values of a column with labels from a two-column CSV (code, label; first row is a header), e.g. \"ステータス:status_codes.csv\" (repeatable).")
+	fs.BoolVar(&opts.ShowCodes, "show-codes", false, "Show the original code of -map values as a tooltip in the HTML report.")
+	fs.StringVar(&jsonCols, "json-col", "", "Comma-separated columns whose JSON values are pretty-printed with syntax coloring in the HTML report (-target still matches the raw text).")
+	fs.IntVar(&opts.MaxValueLen, "max-value-len", 0, "Truncate values longer than this many characters in the HTML report; click a value to expand it (0 = no limit).")
+	fs.Var(&masks, "mask", "Hide a column's values in every output, e.g. \"電話番号:last4\" or \"メールアドレス:hash\" (methods: redact, hash, lastN, firstN; repeatable).")
+	fs.StringVar(&opts.Font, "font", "", fontUsage)
+	fs.StringVar(&imageCols, "image-col", "", "Comma-separated columns whose values are image paths or URLs, shown as thumbnails in the HTML report (relative paths are resolved against the CSV's folder).")
+	fs.StringVar(&geoCols, "geo-cols", "", "Latitude and longitude columns, e.g. \"緯度,経度\"; the HTML report plots matched records on an offline map linking to each record.")
+	fs.StringVar(&ganttCols, "gantt", "", "Start date, end date and optional label columns, e.g. \"開始日,終了日,タスク名\"; the HTML report draws matched records as bars on a timeline.")
+	fs.StringVar(&dashboard, "dashboard", "", "Lay out the HTML report as a grid of panels in this order, e.g. \"summary,top,aggregates,legend,records\" (panels: summary, records, top, aggregates, pivot, legend, map, gantt).")
+	fs.StringVar(&themeFile, "theme-file", "", "YAML file mapping color names to CSS colors that override the HTML report's palette (keys: accent, accent-dark, accent-light, text, background, surface, border, muted, value, highlight, highlight-text, highlight-border, error, bar).")
+	fs.StringVar(&accentColor, "accent-color", "", "Accent color of the HTML report, e.g. \"#003366\" (overrides accent in -theme-file).")
+	fs.StringVar(&palette, "palette", "", "Color palette of the HTML report: default, or colorblind for colors distinguishable with red-green color blindness plus icons and border styles on highlights and built-in tags.")
+	fs.BoolVar(&opts.PrintLayout, "print-layout", false, "Lay out the HTML report for printing on A4 paper: a page break before each file, page numbers, and long values printed in full.")
+	fs.BoolVar(&opts.EmbedImages, "embed-images", false, "Embed -image-col images in the HTML report as base64 so it can be shared as a single file.")
+	fs.StringVar(&opts.Format, "format", "", "Output format: html, text, tsv, csv or json (default: html with -out, text otherwise).")
+	fs.BoolVar(&opts.TUI, "tui", false, "Start the interactive terminal mode to pick columns and preview matches (-cols is optional).")
+	fs.BoolVar(&opts.FilesWithMatches, "l", false, "Only print the paths of files containing at least one matching row.")
+	fs.BoolVar(&opts.CountOnly, "c", false, "Only print the number of matching rows per file.")
+	fs.IntVar(&opts.Context, "context", 0, "Also write this many rows before and after each match, de-emphasized, like grep -C (not with -sort or -timeline).")
+	fs.StringVar(&opts.Timeline, "timeline", "", "Group records under date headings of this column in chronological order, with a date navigation sidebar in HTML.")
+	fs.StringVar(&sortStr, "sort", "", "Sort all matched records by columns, e.g. \"登録日:desc,氏名\" (options per key: asc, desc, num, date, str).")
+	fs.IntVar(&opts.SortBuffer, "sort-buffer", chiicgrep.DefaultSortBuffer, "Number of records -sort keeps in memory; beyond that, sorted chunks are written to temporary files and merged.")
+	fs.StringVar(&opts.TempDir, "temp-dir", "", "Directory for -sort's temporary files (default: the system temporary directory).")
+	fs.BoolVar(&opts.Dedup, "dedup", false, "Suppress records whose extracted values repeat an earlier record (across files).")
+	fs.StringVar(&dedupBy, "dedup-by", "", "Comma-separated key columns used to detect duplicates instead of the extracted values (implies -dedup).")
+	fs.Var(&aggregates, "aggregate", "Summarize a numeric column over the written records, e.g. \"金額:sum,avg,min,max\" (functions: sum, avg, min, max, count; repeatable).")
+	fs.Var(&topValues, "top", "List the most frequent values of a column over the written records, e.g. \"エラーコード:20\" (default 10 values; repeatable).")
+	fs.StringVar(&pivot, "pivot", "", "Cross-tabulate the written records, e.g. \"rows=部署 cols=月 value=金額 agg=sum\" (agg: sum, avg, min, max, count).")
+	fs.IntVar(&opts.MaxResults, "max-results", 0, "Stop after writing this many records in total (0 = unlimited).")
+	fs.IntVar(&opts.MaxPerFile, "max-per-file", 0, "Write at most this many records per file (0 = unlimited).")
+	fs.IntVar(&sampleSize, "sample", 0, "Write this many randomly chosen matching records per file.")
+	fs.Int64Var(&sampleSeed, "seed", 0, "Random seed for -sample, to choose the same records again (default: a new seed each run, shown in the report).")
+	fs.IntVar(&headSize, "head", 0, "Write only the first N matching records per file.")
+	fs.IntVar(&tailSize, "tail", 0, "Write only the last N matching records per file.")
+	fs.BoolVar(&opts.LazyQuotes, "lazy-quotes", false, "Tolerate stray or unterminated quotes in CSV fields.")
+	fs.BoolVar(&opts.AllowVariableFields, "allow-variable-fields", false, "Accept rows whose field count differs from the header; missing trailing columns are treated as empty.")
+	fs.BoolVar(&opts.Strict, "strict", false, "Abort with an error as soon as any file fails to read or has CSV parse errors.")
+	fs.IntVar(&opts.Retry, "retry", 0, "Number of times to retry opening a file locked by another process (e.g. open in Excel).")
+	fs.DurationVar(&opts.RetryWait, "retry-wait", time.Second, "Wait before the first retry of a locked file; doubled on each retry.")
+	fs.IntVar(&opts.Jobs, "jobs", runtime.NumCPU(), "Number of files to process concurrently.")
+	fs.StringVar(&opts.FindColumn, "find-col", "", "Only list the files with columns whose names contain a keyword or match /regexp/, with sample values (-cols is not needed).")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Only list the files that would be processed, the requested columns found or missing in each, and the tag rules that apply; no data rows are read.")
+	fs.BoolVar(&opts.Progress, "progress", false, "Show files processed, rows scanned, matches and ETA on stderr, plus per-file timing.")
+	fs.StringVar(&mailTo, "mail-to", "", "Comma-separated addresses to email the -out report to when the run finishes.")
+	fs.StringVar(&opts.Mail.From, "mail-from", "", "Sender address for -mail-to.")
+	fs.StringVar(&opts.Mail.Server, "smtp-server", "", "SMTP server (host:port) used by -mail-to.")
+	fs.BoolVar(&opts.Mail.Attach, "mail-attach", false, "Send the report as an attachment instead of the mail body.")
+	fs.StringVar(&upload, "upload", "", "Upload the -out report when the run finishes: s3://bucket/prefix/ or an http(s) URL to PUT to (a trailing / appends the file name).")
+	fs.StringVar(&opts.NotifyWebhook, "notify-webhook", "", "POST a JSON summary (files, matches, output path, errors) to this Slack/Teams-compatible webhook URL when the run finishes.")
+	fs.BoolVar(&opts.Watch, "watch", false, "Watch the input for CSV changes and regenerate the report (requires -out).")
+	fs.StringVar(&opts.Schedule, "schedule", "", "Keep running and generate the report on a cron schedule, e.g. \"0 6 * * *\" (minute hour day month weekday; also @hourly, @daily, @weekly, @monthly).")
+	fs.StringVar(&opts.StatusAddr, "status-addr", "", "With -schedule, serve the last run's status as JSON on this address (e.g. localhost:8080).")
+	fs.StringVar(&opts.LiveReload, "live-reload", "", "With -watch, serve the report on this address (e.g. localhost:35729) and refresh the browser on regeneration.")
+	fs.BoolVar(&showVersion, "version", false, "Print the version, git commit and build date, then exit.")
+	conf.register(fs)
+	logging.register(fs)
+	registerAliases(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s extract -in <path> -cols <col1,col2> [options]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Extracts matching rows from CSV files into a report.")
+		printFlagGroups(os.Stderr, fs, extractFlagGroups)
+	}
+
+	return fs, func() options {
+		if showVersion {
+			fmt.Println(versionString())
+			os.Exit(exitMatch)
+		}
+
+		if err := conf.apply(fs); err != nil {
+			fatalf("Error: %v", err)
+		}
+		if err := logging.apply(); err != nil {
+			fatalf("Error: %v", err)
+		}
+		conf.warnUnknownKeys()
+		opts.CommandLine = commandLine(os.Args)
+		opts.Options = effectiveOptions(fs)
+
+		if opts.InputPath == "" || (columnsStr == "" && !opts.TUI && opts.FindColumn == "") {
+			fs.Usage()
+			os.Exit(exitError)
+		}
+		if opts.FilesWithMatches && opts.CountOnly {
+			fatalf("Error: -l and -c cannot be used together")
+		}
+		now := time.Now()
+		for i, out := range outFiles {
+			expanded, err := expandOutputPath(out, opts.InputPath, now)
+			if err != nil {
+				fatalf("Error: -out: %v", err)
+			}
+			if opts.Compress && !isCompressed(expanded) {
+				expanded += ".gz"
+				out += ".gz"
+			}
+			opts.OutTemplates = append(opts.OutTemplates, out)
+			outFiles[i] = expanded
+		}
+		if len(outFiles) > 0 {
+			opts.OutFile = outFiles[0]
+			opts.ExtraOutputs = outFiles[1:]
+		}
+		if len(opts.ExtraOutputs) > 0 {
+			if opts.FilesWithMatches || opts.CountOnly {
+				fatalf("Error: multiple -out files cannot be used with -l or -c")
+			}
+			for _, out := range opts.ExtraOutputs {
+				if _, err := outputFormat(out); err != nil {
+					fatalf("Error: -out: %v", err)
+				}
+			}
+		}
+		if opts.NewOut != "" {
+			if opts.StateFile == "" {
+				fatalf("Error: -new-out requires -state")
+			}
+			opts.NewOutTemplate = opts.NewOut
+			if opts.Compress && !isCompressed(opts.NewOut) {
+				opts.NewOutTemplate += ".gz"
+			}
+			expanded, err := expandOutputPath(opts.NewOutTemplate, opts.InputPath, now)
+			if err != nil {
+				fatalf("Error: -new-out: %v", err)
+			}
+			if _, err := outputFormat(expanded); err != nil {
+				fatalf("Error: -new-out: %v", err)
+			}
+			opts.NewOut = expanded
+		}
+		if opts.StateFile != "" && (opts.FilesWithMatches || opts.CountOnly) {
+			fatalf("Error: -state cannot be used with -l or -c")
+		}
+		var splitOutputs []string
+		if opts.SplitByTag {
+			if opts.OutFile == "" {
+				fatalf("Error: -split-by-tag requires -out")
+			}
+			if opts.FilesWithMatches || opts.CountOnly {
+				fatalf("Error: -split-by-tag cannot be used with -l or -c")
+			}
+		}
+		if opts.Context < 0 {
+			fatalf("Error: -context must not be negative")
+		}
+		if opts.Retry < 0 {
+			fatalf("Error: -retry must not be negative")
+		}
+		if opts.SortBuffer <= 0 {
+			fatalf("Error: -sort-buffer must be positive")
+		}
+		var samples []chiicgrep.Sample
+		for _, s := range []struct {
+			flag string
+			size int
+			mode chiicgrep.SampleMode
+		}{{"sample", sampleSize, chiicgrep.SampleRandom}, {"head", headSize, chiicgrep.SampleHead}, {"tail", tailSize, chiicgrep.SampleTail}} {
+			if s.size < 0 {
+				fatalf("Error: -%s must not be negative", s.flag)
+			}
+			if s.size > 0 {
+				samples = append(samples, chiicgrep.Sample{Mode: s.mode, Size: s.size, Seed: sampleSeed})
+			}
+		}
+		switch {
+		case len(samples) > 1:
+			fatalf("Error: only one of -sample, -head and -tail can be used")
+		case len(samples) == 1 && opts.Context > 0:
+			fatalf("Error: -context cannot be used with -sample, -head or -tail")
+		case sampleSeed != 0 && sampleSize == 0:
+			fatalf("Error: -seed requires -sample")
+		case len(samples) == 1:
+			opts.Sample = &samples[0]
+		}
+		if opts.Context > 0 && (sortStr != "" || opts.Timeline != "") {
+			fatalf("Error: -context cannot be combined with -sort or -timeline")
+		}
+		if opts.Watch && opts.OutFile == "" {
+			fatalf("Error: -watch requires -out")
+		}
+		if opts.Schedule != "" {
+			switch {
+			case opts.OutFile == "":
+				fatalf("Error: -schedule requires -out")
+			case opts.Watch:
+				fatalf("Error: -schedule cannot be used with -watch")
+			case opts.FilesWithMatches || opts.CountOnly:
+				fatalf("Error: -schedule cannot be used with -l or -c")
+			case opts.AfterOpen:
+				fatalf("Error: -schedule cannot be used with -after-open")
+			}
+			if _, err := parseCron(opts.Schedule); err != nil {
+				fatalf("Error: -schedule: %v", err)
+			}
+		} else if opts.StatusAddr != "" {
+			fatalf("Error: -status-addr requires -schedule")
+		}
+		if opts.Compress && opts.OutFile == "" {
+			fatalf("Error: -compress requires -out")
+		}
+		if opts.LiveReload != "" && isCompressed(opts.OutFile) {
+			fatalf("Error: -live-reload cannot serve a compressed report")
+		}
+		if mailTo != "" {
+			for _, addr := range strings.Split(mailTo, ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					opts.Mail.To = append(opts.Mail.To, addr)
+				}
+			}
+			if opts.Watch {
+				fatalf("Error: -mail-to cannot be used with -watch")
+			}
+			if err := opts.Mail.validate(opts.OutFile); err != nil {
+				fatalf("Error: %v", err)
+			}
+		}
+		if upload != "" {
+			if opts.OutFile == "" {
+				fatalf("Error: -upload requires -out")
+			}
+			if opts.Watch {
+				fatalf("Error: -upload cannot be used with -watch")
+			}
+			t, err := parseUploadTarget(upload, filepath.Base(opts.OutFile))
+			if err != nil {
+				fatalf("Error: -upload: %v", err)
+			}
+			opts.Upload = &t
+			opts.UploadSpec = upload
+		}
+		if opts.NotifyWebhook != "" {
+			if opts.Watch {
+				fatalf("Error: -notify-webhook cannot be used with -watch")
+			}
+			if u, err := url.Parse(opts.NotifyWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				fatalf("Error: -notify-webhook must be an http or https URL, got %q", opts.NotifyWebhook)
+			}
+		}
+		if columnsStr != "" {
+			opts.Columns = chiicgrep.ParseColumns(columnsStr)
+		}
+		if imageCols != "" {
+			opts.ImageColumns = strings.Split(imageCols, ",")
+			for _, name := range opts.ImageColumns {
+				if len(opts.Columns) > 0 && !slices.ContainsFunc(opts.Columns, func(c chiicgrep.Column) bool { return c.Name == name }) {
+					warnf("-image-col: column '%s' is not in -cols and will not be shown", name)
+				}
+			}
+		}
+		if jsonCols != "" {
+			opts.JSONColumns = strings.Split(jsonCols, ",")
+			for _, name := range opts.JSONColumns {
+				if len(opts.Columns) > 0 && !slices.ContainsFunc(opts.Columns, func(c chiicgrep.Column) bool { return c.Name == name }) {
+					warnf("-json-col: column '%s' is not in -cols and will not be shown", name)
+				}
+			}
+		}
+		if geoCols != "" {
+			geo, err := chiicgrep.ParseGeoColumns(geoCols)
+			if err != nil {
+				fatalf("Error: -geo-cols: %v", err)
+			}
+			opts.Geo = geo
+		}
+		if dashboard != "" {
+			if opts.Timeline != "" {
+				fatalf("Error: -dashboard cannot be used with -timeline")
+			}
+			panels, err := chiicgrep.ParseDashboard(dashboard)
+			if err != nil {
+				fatalf("Error: -dashboard: %v", err)
+			}
+			opts.Dashboard = panels
+		}
+		checkFont(opts.Font)
+		if themeFile != "" || accentColor != "" {
+			theme := map[string]string{}
+			if themeFile != "" {
+				var err error
+				if theme, err = loadThemeFile(themeFile); err != nil {
+					fatalf("Error: -theme-file: %v", err)
+				}
+			}
+			if accentColor != "" {
+				theme["accent"] = accentColor
+			}
+			if err := chiicgrep.CheckTheme(theme); err != nil {
+				fatalf("Error: %v", err)
+			}
+			opts.Theme = theme
+		}
+		if palette != "" {
+			p, err := chiicgrep.ParsePalette(palette)
+			if err != nil {
+				fatalf("Error: -palette: %v", err)
+			}
+			opts.Palette = p
+		}
+		if ganttCols != "" {
+			gantt, err := chiicgrep.ParseGanttColumns(ganttCols)
+			if err != nil {
+				fatalf("Error: -gantt: %v", err)
+			}
+			opts.Gantt = gantt
+		}
+		if requiredStr != "" {
+			opts.RequiredColumns = strings.Split(requiredStr, ",")
+		}
+		filterRules, err := chiicgrep.ParseConditions(filters)
+		if err != nil {
+			fatalf("Error: -filter: %v", err)
+		}
+		opts.Filters = filterRules
+		opts.ColumnMaps = parseColumnMaps(colMaps)
+		opts.ColumnAliases = parseColumnAliases(colAliases)
+		for _, s := range inLists {
+			l, err := chiicgrep.ParseValueList(s, false)
+			if err != nil {
+				fatalf("Error: -in-list: %v", err)
+			}
+			opts.ValueLists = append(opts.ValueLists, l)
+		}
+		for _, s := range notInLists {
+			l, err := chiicgrep.ParseValueList(s, true)
+			if err != nil {
+				fatalf("Error: -not-in-list: %v", err)
+			}
+			opts.ValueLists = append(opts.ValueLists, l)
+		}
+		if whereStr != "" {
+			where, err := chiicgrep.ParseWhere(whereStr)
+			if err != nil {
+				fatalf("Error: %v", err)
+			}
+			opts.Where = where
+		}
+		rules, err := chiicgrep.ParseConditions(highlightRules)
+		if err != nil {
+			fatalf("Error: -highlight-if: %v", err)
+		}
+		opts.HighlightRules = rules
+		if tagMatch != "path" && tagMatch != "base" {
+			fatalf("Error: -tag-match must be path or base, got %q", tagMatch)
+		}
+		for _, s := range tagRules {
+			rule, err := chiicgrep.ParseTagRule(s)
+			if err != nil {
+				fatalf("Error: -tag-file: %v", err)
+			}
+			rule.BaseName = tagMatch == "base"
+			opts.TagRules = append(opts.TagRules, rule)
+		}
+		for _, s := range tagDirs {
+			rule, err := chiicgrep.ParseTagDirRule(s)
+			if err != nil {
+				fatalf("Error: -tag-dir: %v", err)
+			}
+			if !filepath.IsAbs(rule.Dir) {
+				rule.Dir = filepath.Join(inputDir(opts.InputPath), rule.Dir)
+			}
+			opts.TagRules = append(opts.TagRules, rule)
+		}
+		if onlyTagged != "" {
+			opts.OnlyTags = strings.Split(onlyTagged, ",")
+			for _, tag := range opts.OnlyTags {
+				if !slices.ContainsFunc(opts.TagRules, func(r chiicgrep.TagRule) bool { return r.Tag == tag }) {
+					warnf("-only-tagged: no -tag-file or -tag-dir rule assigns tag '%s'", tag)
+				}
+			}
+		}
+		for _, s := range rowTagRules {
+			rule, err := chiicgrep.ParseRowTagRule(s)
+			if err != nil {
+				fatalf("Error: -tag-row: %v", err)
+			}
+			opts.RowTagRules = append(opts.RowTagRules, rule)
+		}
+		for _, s := range validations {
+			rule, err := chiicgrep.ParseValidationRule(s)
+			if err != nil {
+				fatalf("Error: -validate: %v", err)
+			}
+			opts.Validations = append(opts.Validations, rule)
+		}
+		for _, s := range tagDefs {
+			def, err := chiicgrep.ParseTagDef(s)
+			if err != nil {
+				fatalf("Error: -define-tag: %v", err)
+			}
+			opts.TagDefs = append(opts.TagDefs, def)
+		}
+		if uniqueKey != "" {
+			opts.UniqueKey = strings.Split(uniqueKey, ",")
+		}
+		if dedupBy != "" {
+			opts.Dedup = true
+			opts.DedupBy = strings.Split(dedupBy, ",")
+		}
+		if sortStr != "" {
+			if opts.Sort, err = chiicgrep.ParseSortKeys(sortStr); err != nil {
+				fatalf("Error: -sort: %v", err)
+			}
+		}
+		for _, s := range aggregates {
+			agg, err := chiicgrep.ParseAggregate(s)
+			if err != nil {
+				fatalf("Error: -aggregate: %v", err)
+			}
+			opts.Aggregates = append(opts.Aggregates, agg)
+		}
+		for _, s := range topValues {
+			top, err := chiicgrep.ParseTopValues(s)
+			if err != nil {
+				fatalf("Error: -top: %v", err)
+			}
+			opts.TopValues = append(opts.TopValues, top)
+		}
+		for _, s := range replacements {
+			rep, err := chiicgrep.ParseReplacement(s)
+			if err != nil {
+				fatalf("Error: -replace: %v", err)
+			}
+			opts.Replacements = append(opts.Replacements, rep)
+		}
+		for _, s := range valueMaps {
+			m, err := chiicgrep.ParseValueMap(s)
+			if err != nil {
+				fatalf("Error: -map: %v", err)
+			}
+			opts.ValueMaps = append(opts.ValueMaps, m)
+		}
+		for _, s := range masks {
+			m, err := chiicgrep.ParseMask(s)
+			if err != nil {
+				fatalf("Error: -mask: %v", err)
+			}
+			opts.Masks = append(opts.Masks, m)
+		}
+		opts.MaskKey = os.Getenv(maskKeyEnv)
+		if join != "" {
+			j, err := chiicgrep.ParseJoin(join)
+			if err != nil {
+				fatalf("Error: -join: %v", err)
+			}
+			opts.Join = &j
+		}
+		if pivot != "" {
+			p, err := chiicgrep.ParsePivot(pivot)
+			if err != nil {
+				fatalf("Error: -pivot: %v", err)
+			}
+			opts.Pivot = &p
+		}
+		if opts.Normalize, err = chiicgrep.ParseNormalization(normalize); err != nil {
+			fatalf("Error: -normalize: %v", err)
+		}
+		if opts.InputPath == "-" {
+			opts.Source = &chiicgrep.ReaderSource{Name: "stdin", Reader: os.Stdin}
+		}
+		if opts.Format == "" {
+			opts.Format = "text"
+			if opts.OutFile != "" {
+				opts.Format = "html"
+			}
+		}
+		// 表計算ソフトなどへ貼り付けやすいよう、クリップボードにはテキストの代わりにタブ区切りで書き込む
+		if opts.ToClipboard && opts.Format == "text" {
+			opts.Format = "tsv"
+		}
+		if opts.SplitByTag {
+			// タグごとのファイルは -out と同じフォルダに作るため、-out などと同じ名前にならないか確認する
+			for _, tag := range append(tagNames(opts.TagRules), chiicgrep.UntaggedTag) {
+				path := splitOutputPath(opts.OutFile, tag)
+				if path == filepath.Clean(opts.OutFile) || slices.Contains(opts.ExtraOutputs, path) {
+					fatalf("Error: -split-by-tag: the file for tag %q would overwrite %s", tag, path)
+				}
+				splitOutputs = append(splitOutputs, path)
+			}
+		}
+		var outputs []string
+		if opts.OutFile != "" {
+			outputs = append(append([]string{opts.OutFile}, opts.ExtraOutputs...), splitOutputs...)
+		}
+		if opts.NewOut != "" {
+			outputs = append(outputs, opts.NewOut)
+		}
+		if len(outputs) > 0 && !opts.DryRun && opts.FindColumn == "" && !opts.TUI {
+			// 標準入力からCSVを読む場合は、確認の応答と入力が混ざらないよう確認を求めない
+			if err := checkOverwrite(outputs, opts.Force, opts.Source == nil); err != nil {
+				fatalf("Error: %v", err)
+			}
+			if err := prepareOutputDirs(outputs, !opts.NoMkdir); err != nil {
+				fatalf("Error: %v", err)
+			}
+		}
+		return opts
+	}
+}
+
+// inputDir は -in で指定された入力の基準となるフォルダを返します。ファイルの場合はそのフォルダです。
+func inputDir(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// newRenderer は指定された出力形式に対応する Renderer を作成します。
+func newRenderer(opts options, w io.Writer) (chiicgrep.Renderer, error) {
+	switch opts.Format {
+	case "html":
+		return chiicgrep.NewHTMLRenderer(w, chiicgrep.HTMLOptions{Font: opts.Font, Tags: opts.TagDefs, Generator: versionString(), Timeline: opts.Timeline,
+			ImageColumns: opts.ImageColumns, EmbedImages: opts.EmbedImages, JSONColumns: opts.JSONColumns, ShowCodes: opts.ShowCodes, MaxValueLen: opts.MaxValueLen, CommandLine: opts.CommandLine, Options: opts.Options, Dashboard: opts.Dashboard, Theme: opts.Theme, Palette: opts.Palette, PrintLayout: opts.PrintLayout}), nil
+	case "text":
+		return chiicgrep.NewTextRenderer(w, chiicgrep.TextOptions{Tags: opts.TagDefs, Timeline: opts.Timeline}), nil
+	case "tsv":
+		r := chiicgrep.NewTSVRenderer(w, opts.Columns)
+		r.ContextColumn = opts.Context > 0
+		return r, nil
+	case "csv":
+		r := chiicgrep.NewCSVRenderer(w, opts.Columns)
+		r.ContextColumn = opts.Context > 0
+		return r, nil
+	case "json":
+		return chiicgrep.NewJSONRenderer(w), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", opts.Format)
+	}
+}
+
+// tagNames は rules のタグ名を重複なく返します。
+func tagNames(rules []chiicgrep.TagRule) []string {
+	var names []string
+	for _, r := range rules {
+		if !slices.Contains(names, r.Tag) {
+			names = append(names, r.Tag)
+		}
+	}
+	return names
+}
+
+// outputFormat は2つ目以降の -out のファイルの出力形式を拡張子から判定します。
+func outputFormat(path string) (string, error) {
+	switch strings.ToLower(uncompressedExt(path)) {
+	case ".html", ".htm":
+		return "html", nil
+	case ".json":
+		return "json", nil
+	case ".csv":
+		return "csv", nil
+	case ".tsv":
+		return "tsv", nil
+	case ".txt":
+		return "text", nil
+	}
+	return "", fmt.Errorf("cannot determine the output format of %s from its extension (use .html, .json, .csv, .tsv or .txt, optionally followed by .gz)", path)
+}
+
+// runExtract は extract サブコマンドを実行します。
+func runExtract(args []string) {
+	opts := parseExtractFlags(args)
+
+	if opts.DryRun {
+		code, err := runDryRun(context.Background(), opts, os.Stdout)
+		if err != nil {
+			if errors.Is(err, chiicgrep.ErrNoCSVFiles) {
+				log.Println("No CSV files found.")
+				os.Exit(exitNoMatch)
+			}
+			fatalf("Error: %v", err)
+		}
+		os.Exit(code)
+	}
+
+	if opts.FindColumn != "" {
+		code, err := runFindColumns(context.Background(), opts, os.Stdout)
+		if err != nil {
+			if errors.Is(err, chiicgrep.ErrNoCSVFiles) {
+				log.Println("No CSV files found.")
+				os.Exit(exitNoMatch)
+			}
+			fatalf("Error: %v", err)
+		}
+		os.Exit(code)
+	}
+
+	if opts.TUI {
+		if opts.NoColor {
+			color.NoColor = true
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), interruptSignals...)
+		defer stop()
+		if err := runTUI(ctx, opts); err != nil && ctx.Err() == nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if opts.NoColor || opts.OutFile != "" || opts.ToClipboard {
+		color.NoColor = true
+	}
+
+	// Ctrl-C で中断された場合も、それまでの結果でレポートを閉じる
+	ctx, stop := signal.NotifyContext(context.Background(), interruptSignals...)
+	defer stop()
+
+	if opts.Watch {
+		if err := runWatch(ctx, opts); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+	if opts.Schedule != "" {
+		if err := runSchedule(ctx, opts); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	var progress *progressPrinter
+	if opts.Progress {
+		progress = &progressPrinter{w: os.Stderr}
+		opts.OnProgress = progress.update
+	}
+
+	sum, err := writeReport(ctx, opts)
+	if progress != nil {
+		progress.finish()
+	}
+	if err != nil {
+		if errors.Is(err, chiicgrep.ErrNoCSVFiles) {
+			log.Println("No CSV files found.")
+			os.Exit(exitNoMatch)
+		}
+		if ctx.Err() != nil {
+			stop()
+			switch {
+			case !interruptedReport(sum, err) && opts.OutFile != "":
+				log.Printf("Interrupted: %s was not written.", opts.OutFile)
+			case !interruptedReport(sum, err):
+				log.Printf("Interrupted: the output contains partial results only.")
+			case opts.OutFile != "":
+				log.Printf("Interrupted after %d of %d files: %s contains partial results only.", sum.FilesScanned, sum.FilesTotal, opts.OutFile)
+			default:
+				log.Printf("Interrupted after %d of %d files: the output contains partial results only.", sum.FilesScanned, sum.FilesTotal)
+			}
+			os.Exit(exitInterrupted)
+		}
+		fatalf("Error: %v", err)
+	}
+
+	if !opts.FilesWithMatches && !opts.CountOnly && !quiet {
+		if jsonLogger != nil {
+			logSummary(jsonLogger, sum)
+		} else {
+			printSummary(summaryOutput, sum)
+		}
+	}
+	code := exitCode(sum)
+	if !deliverReport(opts, sum) {
+		code = exitError
+	}
+	if opts.AfterOpen && opts.OutFile != "" {
+		openOutput(opts.OutFile, opts.Browser)
+	}
+	stop()
+	os.Exit(code)
+}
+
+// deliverReport は -upload、-mail-to、-notify-webhook の指定に従って、出力したレポートを送ります。
+// いずれかの送信に失敗した場合は false を返します。
+func deliverReport(opts options, sum chiicgrep.Summary) (ok bool) {
+	ok = true
+	if opts.Upload != nil {
+		if err := uploadFile(*opts.Upload, opts.OutFile); err != nil {
+			log.Printf("Error: could not upload report: %v", err)
+			ok = false
+		} else {
+			infof("Uploaded the report to %s.\n", opts.Upload)
+		}
+	}
+	if opts.Mail.enabled() {
+		if err := sendReport(opts.Mail, opts.OutFile, opts.Format == "html", sum); err != nil {
+			log.Printf("Error: could not send report by mail: %v", err)
+			ok = false
+		} else {
+			infof("Sent the report to %s.\n", strings.Join(opts.Mail.To, ", "))
+		}
+	}
+	if opts.NotifyWebhook != "" {
+		output := opts.OutFile
+		if abs, err := filepath.Abs(output); output != "" && err == nil {
+			output = abs
+		}
+		if err := notifyWebhook(opts.NotifyWebhook, newWebhookPayload(sum, output)); err != nil {
+			log.Printf("Error: could not notify webhook: %v", err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// printSummary は処理結果の集計を1行で出力します。
+func printSummary(w io.Writer, sum chiicgrep.Summary) {
+	fmt.Fprintf(w, "Summary: %d files scanned, %d with matches, %d matching rows, %d read errors, %d missing-column warnings, elapsed %s\n",
+		sum.FilesScanned, sum.FilesWithMatches, sum.Matches, len(sum.Errors), sum.ColumnWarnings, sum.Elapsed.Round(time.Millisecond))
+	if n := len(sum.KeyConflicts); n > 0 {
+		fmt.Fprintf(w, "Unique key: %d duplicate values of %s\n", n, strings.Join(sum.UniqueKey, ","))
+	}
+	if n := sum.Violations(); n > 0 {
+		fmt.Fprintf(w, "Validation: %d violations of -validate rules\n", n)
+	}
+	if locked := sum.LockedFiles(); len(locked) > 0 {
+		fmt.Fprintf(w, "Locked files (close them and run again): %s\n", strings.Join(locked, ", "))
+	}
+}
+
+// exitCode は処理結果に対応する終了コードを返します。
+// 読み込みに失敗したファイルや必須列が欠けたファイル、検証ルールに違反した値、重複したキーがあれば、一致の有無にかかわらずエラーとします。
+func exitCode(sum chiicgrep.Summary) int {
+	switch {
+	case len(sum.Errors) > 0, len(sum.SchemaViolations) > 0, sum.Violations() > 0, len(sum.KeyConflicts) > 0:
+		return exitError
+	case sum.Matches > 0:
+		return exitMatch
+	}
+	return exitNoMatch
+}
+
+// writeReport は設定に従ってレポートを生成し、-out のファイルまたは標準出力（-to-clipboard の場合はクリップボード）に書き込みます。
+// 出力ファイルは一時ファイルに書き込み、最後まで書き込めた場合だけ置き換えます。失敗した場合は既存のファイルがそのまま残ります。
+// 中断された場合は、途中までの結果であることを示したレポートで置き換えます。
+func writeReport(ctx context.Context, opts options) (sum chiicgrep.Summary, err error) {
+	var outputWriter io.Writer = os.Stdout
+	var out *outputFile
+	var extras []*extraOutput
+	var state *chiicgrep.RecordState
+	defer func() {
+		// 中断された場合もレポートは閉じられているため、途中までの結果として残す
+		complete := err == nil || interruptedReport(sum, err)
+		if ferr := finishExtraOutputs(extras, complete); err == nil {
+			err = ferr
+		}
+		if out != nil {
+			if !complete {
+				out.Abort()
+			} else if cerr := out.Commit(); err == nil {
+				err = cerr
+			}
+		}
+		// 出力に失敗した場合や中断した場合は、次回も同じレコードを新規とするよう状態を更新しない
+		if state != nil && err == nil {
+			err = state.Save(opts.StateFile)
+		}
+	}()
+
+	if opts.StateFile != "" {
+		if state, err = chiicgrep.LoadRecordState(opts.StateFile); err != nil {
+			return sum, err
+		}
+		if state.Baseline() {
+			infof("No previous state in %s; this run is recorded as the baseline and no records are marked new.\n", opts.StateFile)
+		}
+	}
+
+	// -out が指定されている場合はファイルを作成
+	if opts.OutFile != "" {
+		out, err = createOutputFile(opts.OutFile, opts.KeepPrev)
+		if err != nil {
+			return sum, err
+		}
+		outputWriter = out
+	}
+
+	// -to-clipboard の場合は標準出力の代わりにクリップボードへ書き込む
+	var clip *bytes.Buffer
+	if opts.ToClipboard {
+		clip = &bytes.Buffer{}
+		if opts.OutFile != "" {
+			outputWriter = io.MultiWriter(outputWriter, clip)
+		} else {
+			outputWriter = clip
+		}
+	}
+
+	bw := newFlushingWriter(outputWriter)
+	var runErr error
+	if opts.FilesWithMatches || opts.CountOnly {
+		sum, runErr = writeCounts(ctx, opts, bw)
+	} else {
+		renderer, err := newRenderer(opts, bw)
+		if err != nil {
+			return sum, err
+		}
+		// 2つ目以降の -out には同じレコードをそれぞれの形式で書き込む
+		if len(opts.ExtraOutputs) > 0 {
+			renderers := chiicgrep.MultiRenderer{renderer}
+			for _, out := range opts.ExtraOutputs {
+				w, err := createExtraOutput(out, opts.KeepPrev)
+				if err != nil {
+					return sum, err
+				}
+				extras = append(extras, w)
+				o := opts
+				o.Format, _ = outputFormat(out)
+				r, err := newRenderer(o, w)
+				if err != nil {
+					return sum, err
+				}
+				renderers = append(renderers, r)
+			}
+			renderer = renderers
+		}
+		if opts.NewOut != "" {
+			w, err := createExtraOutput(opts.NewOut, opts.KeepPrev)
+			if err != nil {
+				return sum, err
+			}
+			extras = append(extras, w)
+			o := opts
+			o.Format, _ = outputFormat(opts.NewOut)
+			r, err := newRenderer(o, w)
+			if err != nil {
+				return sum, err
+			}
+			renderer = chiicgrep.MultiRenderer{renderer, chiicgrep.NewFilterRenderer(r, func(rec chiicgrep.Record) bool { return rec.New })}
+		}
+		// タグごとのファイルは、そのタグのレコードが最初に見つかった時点で作成する
+		if opts.SplitByTag {
+			split := chiicgrep.NewTagSplitRenderer(func(tag string) (chiicgrep.Renderer, error) {
+				w, err := createExtraOutput(splitOutputPath(opts.OutFile, tag), opts.KeepPrev)
+				if err != nil {
+					return nil, err
+				}
+				extras = append(extras, w)
+				return newRenderer(opts, w)
+			})
+			renderer = chiicgrep.MultiRenderer{renderer, split}
+		}
+		// 出力したレコードだけを記録するよう、すべての出力の手前で状態を照合する
+		if state != nil {
+			renderer = state.Renderer(renderer)
+		}
+		p := chiicgrep.NewProcessor(opts.Config, renderer)
+		runErr = p.Run(ctx)
+		sum = p.Summary()
+	}
+	// 中断された場合もフッターまで書き出す
+	if err := bw.Flush(); err != nil && (runErr == nil || sum.Interrupted) {
+		return sum, fmt.Errorf("failed to write to output: %w", err)
+	}
+	if clip != nil && !errors.Is(runErr, chiicgrep.ErrNoCSVFiles) {
+		if err := copyToClipboard(clip.Bytes(), opts.Format == "html"); err != nil {
+			if runErr == nil {
+				runErr = fmt.Errorf("could not copy to clipboard: %w", err)
+			}
+		} else {
+			infof("Copied the output to the clipboard.\n")
+		}
+	}
+	return sum, runErr
+}
+
+// interruptedReport は writeReport が中断により途中までの結果でレポートを閉じたかを返します。
+func interruptedReport(sum chiicgrep.Summary, err error) bool {
+	return sum.Interrupted && errors.Is(err, context.Canceled)
+}
+
+// extraOutput は2つ目以降の -out のファイルです。
+type extraOutput struct {
+	*flushingWriter
+	file *outputFile
+}
+
+// createExtraOutput は2つ目以降の -out のファイルを作成します。
+func createExtraOutput(path string, keepPrev bool) (*extraOutput, error) {
+	f, err := createOutputFile(path, keepPrev)
+	if err != nil {
+		return nil, err
+	}
+	return &extraOutput{flushingWriter: newFlushingWriter(f), file: f}, nil
+}
+
+// finishExtraOutputs は commit が true の場合はバッファを書き出して outputs を置き換え、false の場合は破棄します。
+// エラーがあっても残りのファイルを処理し、最初のエラーを返します。
+func finishExtraOutputs(outputs []*extraOutput, commit bool) error {
+	var first error
+	for _, o := range outputs {
+		if !commit {
+			o.file.Abort()
+			continue
+		}
+		err := o.Flush()
+		if err != nil {
+			o.file.Abort()
+			err = fmt.Errorf("failed to write to output %s: %w", o.file.path, err)
+		} else {
+			err = o.file.Commit()
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// writeCounts は -l または -c の結果を w に出力します。
+// -l ではファイルごとに最初の一致が見つかった時点で残りの行を読み飛ばします。
+func writeCounts(ctx context.Context, opts options, w io.Writer) (chiicgrep.Summary, error) {
+	var sum chiicgrep.Summary
+	cfg := opts.Config
+	if opts.FilesWithMatches {
+		cfg.MaxPerFile = 1
+	}
+	counts, err := chiicgrep.CountMatches(ctx, cfg)
+	if err != nil {
+		return sum, err
+	}
+	for _, c := range counts {
+		sum.Matches += c.Matches
+		if c.Err != nil {
+			sum.Errors = append(sum.Errors, chiicgrep.FileError{File: c.File, Err: c.Err})
+		}
+		if len(c.MissingColumns) > 0 {
+			sum.SchemaViolations = append(sum.SchemaViolations, chiicgrep.SchemaViolation{File: c.File, Missing: c.MissingColumns})
+		}
+		if opts.FilesWithMatches {
+			if c.Matches > 0 {
+				fmt.Fprintln(w, c.File)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "%s:%d\n", c.File, c.Matches)
+	}
+	return sum, nil
+}
+
+// openOutput は出力ファイルを既定のアプリケーション、または browser のコマンドで開きます。
+func openOutput(path, browser string) {
+	// ★対策1: ファイルを開く前に、パスを絶対パスに変換する
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		log.Printf("Error: could not determine absolute path for %s: %v", path, err)
+		return
+	}
+
+	infof("Processing complete. Opening %s...\n", absPath)
+	if err := openFile(absPath, browser); err != nil {
+		log.Printf("Error: could not open output file %s: %v", absPath, err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// findColumnSamples は -find-col で列ごとに表示する値の例の数です。
+const findColumnSamples = 3
+
+// runFindColumns は -find-col の処理です。パターンに一致する列を持つファイルと、その列の値の例を w に出力します。
+// 一致する列が1つでもあれば exitMatch、なければ exitNoMatch を返します。
+func runFindColumns(ctx context.Context, opts options, w io.Writer) (int, error) {
+	pattern, err := chiicgrep.ParseColumnPattern(opts.FindColumn)
+	if err != nil {
+		return exitError, err
+	}
+	files, err := chiicgrep.FindColumns(ctx, opts.Config, pattern, findColumnSamples)
+	if err != nil {
+		return exitError, err
+	}
+	if writeFoundColumns(w, files) == 0 {
+		return exitNoMatch, nil
+	}
+	return exitMatch, nil
+}
+
+// writeFoundColumns は一致する列があったファイルごとに、列名と位置、値の例を出力し、一致する列があったファイルの数を返します。
+func writeFoundColumns(w io.Writer, files []chiicgrep.FileColumns) int {
+	found := 0
+	for _, f := range files {
+		if f.Err != nil {
+			fmt.Fprintf(w, "%s\n  error: %v\n", f.File, f.Err)
+			continue
+		}
+		if len(f.Columns) == 0 {
+			continue
+		}
+		found++
+		fmt.Fprintln(w, f.File)
+		for _, c := range f.Columns {
+			fmt.Fprintf(w, "  %s (column %d)", c.Name, c.Index+1)
+			if len(c.Samples) > 0 {
+				fmt.Fprintf(w, ": %s", strings.Join(c.Samples, ", "))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintf(w, "Matching columns found in %d of %d files\n", found, len(files))
+	return found
+}
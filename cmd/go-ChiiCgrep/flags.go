@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// stringList は複数回指定できる文字列フラグです。
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+// Set は値を追加します。
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// colMapUsage は -col-map の説明です。extract、stats、diff、inspect で共通です。
+const colMapUsage = "Rename columns of files matching a pattern, e.g. \"systemA/**:emp_no=社員番号,name=氏名\"; the pattern is relative to the input (repeatable)."
+
+// parseColumnMaps は -col-map の値を解析します。不正な値がある場合は終了します。
+func parseColumnMaps(specs stringList) []chiicgrep.ColumnMap {
+	var maps []chiicgrep.ColumnMap
+	for _, s := range specs {
+		m, err := chiicgrep.ParseColumnMap(s)
+		if err != nil {
+			fatalf("Error: -col-map: %v", err)
+		}
+		maps = append(maps, m)
+	}
+	return maps
+}
+
+// colAliasUsage は -col-alias の説明です。extract、stats で共通です。
+const colAliasUsage = "Treat several header spellings as the same column, e.g. \"氏名=名前=NAME\"; each file uses whichever it has (repeatable)."
+
+// parseColumnAliases は -col-alias の値を解析します。不正な値がある場合は終了します。
+func parseColumnAliases(specs stringList) [][]string {
+	var aliases [][]string
+	for _, s := range specs {
+		names, err := chiicgrep.ParseColumnAlias(s)
+		if err != nil {
+			fatalf("Error: -col-alias: %v", err)
+		}
+		aliases = append(aliases, names)
+	}
+	return aliases
+}
+
+// fontUsage は -font の説明です。extract、serve で共通です。
+const fontUsage = "Font name applied to values in the HTML report, or a .woff2/.woff/.ttf/.otf file embedded in the report so it renders where the font is not installed."
+
+// fontWarnSize はこれより大きいフォントファイルを -font に指定した場合に警告するサイズ（バイト）です。
+// 埋め込みはbase64のため、レポートはファイルの約4/3倍大きくなります。
+const fontWarnSize = 2 << 20
+
+// checkFont は -font がフォントファイルのパスの場合に、ファイルを読み込めるかを確認します。
+// ファイルが fontWarnSize より大きい場合は、レポートが大きくなることを警告します。
+func checkFont(font string) {
+	if !chiicgrep.IsFontFile(font) {
+		return
+	}
+	info, err := os.Stat(font)
+	if err != nil {
+		fatalf("Error: -font: %v", err)
+	}
+	if info.Size() > fontWarnSize {
+		warnf("-font: %s is %.1f MB and adds about %.1f MB to every HTML report; consider a subset .woff2 with only the characters you need",
+			font, float64(info.Size())/(1<<20), float64(info.Size())*4/3/(1<<20))
+	}
+}
+
+// logFlags は各サブコマンドに共通のログに関するフラグの値を保持します。
+type logFlags struct {
+	quiet     bool
+	verbose   bool
+	format    string
+	file      string
+	maxSizeMB int
+}
+
+// register はログに関するフラグを fs に登録します。
+func (l *logFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&l.quiet, "quiet", false, "Suppress warnings and status messages; only errors are printed (for scripts and pipes).")
+	fs.BoolVar(&l.verbose, "verbose", false, "Log discovered and skipped files, resolved column indexes and rule bindings.")
+	fs.StringVar(&l.format, "log-format", "text", "Format of log messages on stderr: text or json (one JSON object per event).")
+	fs.StringVar(&l.file, "log-file", "", "Also append warnings, errors and the summary to this file (rotated by size).")
+	fs.IntVar(&l.maxSizeMB, "log-max-size", 10, "Size in MB at which -log-file is rotated; up to 3 old files are kept.")
+}
+
+// apply はログの出力の程度と形式を設定します。fs.Parse と設定ファイルの反映の後に呼び出してください。
+func (l *logFlags) apply() error {
+	if l.quiet && l.verbose {
+		return errors.New("-quiet and -verbose cannot be used together")
+	}
+	quiet = l.quiet
+	if l.format != "text" && l.format != "json" {
+		return fmt.Errorf("-log-format must be text or json, got %q", l.format)
+	}
+	var out io.Writer = os.Stderr
+	if l.file != "" {
+		if l.maxSizeMB <= 0 {
+			return errors.New("-log-max-size must be positive")
+		}
+		f, err := openRotatingFile(l.file, int64(l.maxSizeMB)<<20)
+		if err != nil {
+			return err
+		}
+		if l.format == "text" {
+			// コンソールを閉じた後でも追えるよう、ファイルには日時を付ける
+			out = io.MultiWriter(os.Stderr, timestampWriter{w: f})
+		} else {
+			out = io.MultiWriter(os.Stderr, f)
+		}
+	}
+	summaryOutput = out
+	if l.format == "json" {
+		jsonLogger = newJSONLogger(out)
+		chiicgrep.SetLogger(jsonLogger)
+		log.SetOutput(logLineWriter{logger: jsonLogger})
+	} else {
+		log.SetOutput(out)
+	}
+	switch {
+	case l.quiet:
+		chiicgrep.SetLogLevel(chiicgrep.LogQuiet)
+	case l.verbose:
+		chiicgrep.SetLogLevel(chiicgrep.LogVerbose)
+	}
+	return nil
+}
+
+// flagAliases はフラグの別名から本来のフラグ名への対応です。
+// -c は従来どおり件数だけを出力するフラグのままとし、-cols の短い別名には sort -k と同様に -k を使います。
+// -C は grep -C と同じく -context の別名です。
+var flagAliases = map[string]string{
+	"C":         "context",
+	"i":         "in",
+	"k":         "cols",
+	"o":         "out",
+	"recursive": "r",
+}
+
+// registerAliases は fs に登録済みのフラグについて、flagAliases の別名を登録します。
+// 別名は本来のフラグと値を共有します。
+func registerAliases(fs *flag.FlagSet) {
+	for alias, name := range flagAliases {
+		if f := fs.Lookup(name); f != nil {
+			fs.Var(f.Value, alias, "Alias for -"+name+".")
+		}
+	}
+}
+
+// explicitFlags はコマンドラインで指定されたフラグの名前を返します。別名で指定された場合は本来の名前も含めます。
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+		if name, ok := flagAliases[f.Name]; ok {
+			explicit[name] = true
+		}
+	})
+	return explicit
+}
+
+// flagGroup は使い方の表示でまとめて示すフラグの分類です。
+type flagGroup struct {
+	title string
+	names []string
+}
+
+// printFlagGroups は fs のフラグを groups の分類ごとに、別名を添えて w に出力します。
+// どの分類にも含まれないフラグは最後に "Other" としてまとめます。
+func printFlagGroups(w io.Writer, fs *flag.FlagSet, groups []flagGroup) {
+	aliases := make(map[string][]string)
+	for alias, name := range flagAliases {
+		if fs.Lookup(alias) != nil {
+			aliases[name] = append(aliases[name], alias)
+		}
+	}
+	listed := make(map[string]bool)
+	for _, g := range groups {
+		for _, name := range g.names {
+			listed[name] = true
+		}
+	}
+	var other []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, isAlias := flagAliases[f.Name]; !isAlias && !listed[f.Name] {
+			other = append(other, f.Name)
+		}
+	})
+	if len(other) > 0 {
+		groups = append(groups, flagGroup{title: "Other", names: other})
+	}
+
+	for _, g := range groups {
+		fmt.Fprintf(w, "\n%s:\n", g.title)
+		for _, name := range g.names {
+			f := fs.Lookup(name)
+			if f == nil {
+				continue
+			}
+			names := "-" + name
+			for _, alias := range aliases[name] {
+				names += ", -" + alias
+			}
+			typeName, usage := flag.UnquoteUsage(f)
+			if typeName != "" {
+				names += " " + typeName
+			}
+			fmt.Fprintf(w, "  %s\n    \t%s", names, strings.ReplaceAll(usage, "\n", "\n    \t"))
+			switch f.DefValue {
+			case "", "0", "false":
+			default:
+				if typeName == "string" {
+					fmt.Fprintf(w, " (default %q)", f.DefValue)
+				} else {
+					fmt.Fprintf(w, " (default %v)", f.DefValue)
+				}
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// secretFlags は値に認証用のトークンを含むことがあるため、レポートに値を記載しないフラグです。
+// その他のフラグの値も、URLの場合は redactURL で認証情報を伏せます。
+var secretFlags = map[string]bool{
+	"notify-webhook": true,
+}
+
+// maskedValue はレポートに記載する代わりに表示する値です。
+const maskedValue = "***"
+
+// redactURL は s がURLの場合、ユーザー情報とクエリ文字列を伏せたものを返します。
+// 署名付きURLの署名やトークンはクエリ文字列に、Basic認証のパスワードはユーザー情報に含まれるためです。
+// URLでない値はそのまま返します。
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.User == nil && u.RawQuery == "") {
+		return s
+	}
+	u.User = nil
+	if u.RawQuery != "" {
+		u.RawQuery = maskedValue
+	}
+	return u.String()
+}
+
+// commandLine はレポートに記載するコマンドラインを返します。空白などを含む引数は引用符で囲み、
+// secretFlags の値は伏せ、その他のURLの値は redactURL で認証情報を伏せます。
+func commandLine(args []string) string {
+	parts := make([]string, len(args))
+	maskNext := false
+	for i, arg := range args {
+		switch {
+		case maskNext:
+			arg, maskNext = maskedValue, false
+		case strings.HasPrefix(arg, "-"):
+			name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			switch {
+			case secretFlags[name] && hasValue:
+				arg = arg[:strings.Index(arg, "=")+1] + maskedValue
+			case secretFlags[name]:
+				maskNext = true
+			case hasValue:
+				arg = arg[:strings.Index(arg, "=")+1] + redactURL(value)
+			}
+		default:
+			arg = redactURL(arg)
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
+
+// effectiveOptions は fs のフラグのうち、コマンドライン、環境変数、設定ファイルのいずれかで既定値から変更されたものの
+// 名前と値を名前順で返します。別名は除き、secretFlags の値は伏せ、その他のURLの値は redactURL で認証情報を伏せます。
+func effectiveOptions(fs *flag.FlagSet) [][2]string {
+	var options [][2]string
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, isAlias := flagAliases[f.Name]; isAlias {
+			return
+		}
+		value := f.Value.String()
+		if value == f.DefValue {
+			return
+		}
+		if secretFlags[f.Name] {
+			value = maskedValue
+		} else {
+			value = redactURL(value)
+		}
+		options = append(options, [2]string{f.Name, value})
+	})
+	return options
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+	"golang.org/x/text/width"
+)
+
+// inspectOptions は inspect サブコマンドの設定を保持します。
+type inspectOptions struct {
+	chiicgrep.Config
+	// Samples は列ごとに表示する値の例の数です。
+	Samples int
+	OutFile string
+	Format  string
+	// NoMkdir は -out のフォルダがない場合に作成せず、エラーとします（-no-mkdir）。
+	NoMkdir bool
+	// Force は既存の出力ファイルを確認せずに上書きします（-force）。
+	Force bool
+	// KeepPrev は置き換える前の出力ファイルを残します（-keep-prev）。
+	KeepPrev bool
+}
+
+// parseInspectFlags は inspect サブコマンドの引数を解析します。
+func parseInspectFlags(args []string) inspectOptions {
+	fs, finish := defineInspectFlags()
+	fs.Parse(args)
+	return finish()
+}
+
+// defineInspectFlags は inspect サブコマンドのフラグを登録した FlagSet と、fs.Parse の後に呼び出して
+// オプションを組み立てる関数を返します。
+func defineInspectFlags() (*flag.FlagSet, func() inspectOptions) {
+	var opts inspectOptions
+	var colMaps stringList
+	var conf configFlags
+	var logging logFlags
+
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.StringVar(&opts.InputPath, "in", "", "Path to the CSV file or directory (\"-\" reads CSV from standard input).")
+	fs.BoolVar(&opts.Recursive, "r", false, "Search for CSV files recursively in subdirectories.")
+	fs.BoolVar(&opts.NoIgnore, "no-ignore", false, "Process all CSV files, ignoring exclusions in "+chiicgrep.IgnoreFileName+" files.")
+	fs.BoolVar(&opts.TrimCells, "trim-cells", false, "Strip leading/trailing whitespace (including full-width spaces) from headers and values.")
+	fs.BoolVar(&opts.LazyQuotes, "lazy-quotes", false, "Tolerate stray or unterminated quotes in CSV fields.")
+	fs.Var(&colMaps, "col-map", colMapUsage)
+	fs.IntVar(&opts.Samples, "samples", 3, "Number of distinct sample values shown per column.")
+	fs.StringVar(&opts.OutFile, "out", "", "Path to the output file (optional).")
+	fs.BoolVar(&opts.NoMkdir, "no-mkdir", false, "Fail instead of creating missing directories in the -out path.")
+	fs.BoolVar(&opts.Force, "force", false, "Overwrite an existing -out file without asking (otherwise asks on a terminal and refuses elsewhere).")
+	fs.BoolVar(&opts.KeepPrev, "keep-prev", false, "Keep the previous -out file as <name>.prev.<ext> (e.g. report.prev.html) when replacing it.")
+	fs.StringVar(&opts.Format, "format", "", "Output format: html, json or text (default: html with -out, text otherwise).")
+	conf.register(fs)
+	logging.register(fs)
+	registerAliases(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s inspect -in <path> [options]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Reports the inferred type, distinct count, empty rate, min/max and sample values of each column.")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+
+	return fs, func() inspectOptions {
+
+		if err := conf.apply(fs); err != nil {
+			fatalf("Error: %v", err)
+		}
+		opts.ColumnMaps = parseColumnMaps(colMaps)
+		if err := logging.apply(); err != nil {
+			fatalf("Error: %v", err)
+		}
+		conf.warnUnknownKeys()
+		if opts.InputPath == "" {
+			fs.Usage()
+			os.Exit(exitError)
+		}
+		if opts.Samples < 0 {
+			fatalf("Error: -samples must not be negative")
+		}
+		if opts.InputPath == "-" {
+			opts.Source = &chiicgrep.ReaderSource{Name: "stdin", Reader: os.Stdin}
+		}
+		if opts.Format == "" {
+			opts.Format = "text"
+			if opts.OutFile != "" {
+				opts.Format = "html"
+			}
+		}
+		switch opts.Format {
+		case "html", "json", "text":
+		default:
+			fatalf("Error: unknown output format %q", opts.Format)
+		}
+		if opts.OutFile != "" {
+			out, err := expandOutputPath(opts.OutFile, opts.InputPath, time.Now())
+			if err != nil {
+				fatalf("Error: -out: %v", err)
+			}
+			opts.OutFile = out
+			if err := checkOverwrite([]string{opts.OutFile}, opts.Force, opts.Source == nil); err != nil {
+				fatalf("Error: %v", err)
+			}
+			if err := prepareOutputDirs([]string{opts.OutFile}, !opts.NoMkdir); err != nil {
+				fatalf("Error: %v", err)
+			}
+		}
+		return opts
+	}
+}
+
+// runInspect は inspect サブコマンドを実行します。
+// 読み込めなかったファイルがあれば exitError、それ以外は exitMatch で終了します。
+func runInspect(args []string) {
+	opts := parseInspectFlags(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), interruptSignals...)
+	defer stop()
+
+	files, err := chiicgrep.InspectFiles(ctx, opts.Config, opts.Samples)
+	if err != nil {
+		if ctx.Err() != nil {
+			stop()
+			log.Println("Interrupted: nothing was written.")
+			os.Exit(exitInterrupted)
+		}
+		if errors.Is(err, chiicgrep.ErrNoCSVFiles) {
+			log.Println("No CSV files found.")
+			os.Exit(exitNoMatch)
+		}
+		fatalf("Error: %v", err)
+	}
+	if err := writeInspect(opts, files); err != nil {
+		fatalf("Error: %v", err)
+	}
+	for _, f := range files {
+		if f.Err != nil {
+			os.Exit(exitError)
+		}
+	}
+}
+
+// writeInspect は列ごとの概要を指定された形式で -out のファイルまたは標準出力に書き込みます。
+func writeInspect(opts inspectOptions, files []chiicgrep.FileProfile) (err error) {
+	var w io.Writer = os.Stdout
+	if opts.OutFile != "" {
+		f, ferr := createOutputFile(opts.OutFile, opts.KeepPrev)
+		if ferr != nil {
+			return ferr
+		}
+		// 最後まで書き込めた場合だけ既存のファイルを置き換える
+		defer func() {
+			if err != nil {
+				f.Abort()
+			} else {
+				err = f.Commit()
+			}
+		}()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+
+	switch opts.Format {
+	case "html":
+		err = writeInspectHTML(bw, files)
+	case "json":
+		err = writeInspectJSON(bw, files)
+	default:
+		err = writeInspectText(bw, files)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	return nil
+}
+
+// formatDistinct は異なる値の数を表示用の文字列にします。数えるのをやめた場合は "100000+" のように表示します。
+func formatDistinct(c chiicgrep.ColumnProfile) string {
+	if c.DistinctOver {
+		return fmt.Sprintf("%d+", c.Distinct)
+	}
+	return fmt.Sprint(c.Distinct)
+}
+
+// displayWidth は端末での s の表示幅を返します。全角文字は2文字分として数えます。
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		switch width.LookupRune(r).Kind() {
+		case width.EastAsianWide, width.EastAsianFullwidth:
+			n += 2
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// writeInspectText はファイルごとに、列の概要を1行ずつ出力します。日本語の列名や値があっても揃うよう、表示幅で桁を合わせます。
+func writeInspectText(w io.Writer, files []chiicgrep.FileProfile) error {
+	for _, f := range files {
+		fmt.Fprintf(w, "%s (%d rows)\n", f.File, f.Rows)
+		if f.Err != nil {
+			fmt.Fprintf(w, "  error: %v\n", f.Err)
+		}
+		if len(f.Columns) == 0 {
+			continue
+		}
+		rows := [][]string{{"column", "type", "distinct", "empty", "min", "max", "samples"}}
+		for _, c := range f.Columns {
+			rows = append(rows, []string{c.Name, string(c.Type), formatDistinct(c), fmt.Sprintf("%.1f%%", c.NullRate(f.Rows)*100),
+				c.Min, c.Max, strings.Join(c.Samples, ", ")})
+		}
+		widths := make([]int, len(rows[0]))
+		for _, row := range rows {
+			for i, v := range row {
+				widths[i] = max(widths[i], displayWidth(v))
+			}
+		}
+		for _, row := range rows {
+			line := " "
+			for i, v := range row {
+				line += " " + v
+				if i < len(row)-1 {
+					line += strings.Repeat(" ", widths[i]-displayWidth(v)+1)
+				}
+			}
+			if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeInspectJSON は列ごとの概要をJSONとして出力します。
+func writeInspectJSON(w io.Writer, files []chiicgrep.FileProfile) error {
+	type column struct {
+		Name         string   `json:"name"`
+		Type         string   `json:"type"`
+		Distinct     int      `json:"distinct"`
+		DistinctOver bool     `json:"distinct_over,omitempty"`
+		Empty        int      `json:"empty"`
+		NullRate     float64  `json:"null_rate"`
+		Min          string   `json:"min,omitempty"`
+		Max          string   `json:"max,omitempty"`
+		Samples      []string `json:"samples"`
+	}
+	type file struct {
+		File    string   `json:"file"`
+		Rows    int      `json:"rows"`
+		Columns []column `json:"columns"`
+		Error   string   `json:"error,omitempty"`
+	}
+	out := make([]file, len(files))
+	for i, f := range files {
+		out[i] = file{File: f.File, Rows: f.Rows, Columns: make([]column, len(f.Columns))}
+		if f.Err != nil {
+			out[i].Error = f.Err.Error()
+		}
+		for j, c := range f.Columns {
+			samples := c.Samples
+			if samples == nil {
+				samples = []string{}
+			}
+			out[i].Columns[j] = column{Name: c.Name, Type: string(c.Type), Distinct: c.Distinct, DistinctOver: c.DistinctOver,
+				Empty: c.Empty, NullRate: c.NullRate(f.Rows), Min: c.Min, Max: c.Max, Samples: samples}
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(out)
+}
+
+var inspectTemplate = template.Must(template.New("inspect").Funcs(template.FuncMap{
+	"distinct": formatDistinct,
+	"nullRate": func(c chiicgrep.ColumnProfile, rows int) string { return fmt.Sprintf("%.1f%%", c.NullRate(rows)*100) },
+	"join":     strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="UTF-8">
+<title>列の概要</title>
+<style>
+body { font-family: "Meiryo UI", "Hiragino Sans", sans-serif; margin: 1.5em; color: #222; background: #fafafa; }
+h1 { font-size: 1.4em; border-bottom: 2px solid #0097a7; padding-bottom: 0.2em; }
+h2 { font-size: 1.1em; background: #e0f7fa; padding: 0.3em 0.6em; border-left: 4px solid #0097a7; word-break: break-all; }
+h2 .rows { font-weight: normal; font-size: 0.85em; color: #555; margin-left: 0.5em; }
+table { border-collapse: collapse; background: #fff; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.8em; vertical-align: top; }
+th { background: #e0f7fa; color: #00838f; text-align: left; }
+td.number { text-align: right; }
+td.type { font-family: monospace; }
+tr.empty td { color: #999; }
+.samples { color: #2e7d32; }
+.error { color: #c62828; font-family: monospace; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>列の概要</h1>
+{{range .}}<h2>{{.File}}<span class="rows">{{.Rows}}行</span></h2>
+{{if .Err}}<div class="error">{{.Err}}</div>
+{{end}}{{if .Columns}}<table>
+<thead><tr><th>列</th><th>型</th><th>異なる値</th><th>空欄</th><th>最小</th><th>最大</th><th>値の例</th></tr></thead>
+<tbody>
+{{$rows := .Rows}}{{range .Columns}}<tr{{if eq .Type "empty"}} class="empty"{{end}}><td>{{.Name}}</td><td class="type">{{.Type}}</td><td class="number">{{distinct .}}</td><td class="number">{{nullRate . $rows}}</td><td>{{.Min}}</td><td>{{.Max}}</td><td class="samples">{{join .Samples ", "}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// writeInspectHTML は列ごとの概要を、ファイルごとのHTMLの表として出力します。
+func writeInspectHTML(w io.Writer, files []chiicgrep.FileProfile) error {
+	return inspectTemplate.Execute(w, files)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// summaryOutput は処理結果の集計を出力する先です。-log-file の場合はログファイルにも出力します。
+var summaryOutput io.Writer = os.Stderr
+
+// jsonLogger は -log-format json の場合にログを出力するロガーです。テキストで出力する場合は nil です。
+var jsonLogger *slog.Logger
+
+// newJSONLogger は1件のイベントを1行のJSONオブジェクトとして w に出力するロガーを作成します。
+// 各オブジェクトは time、level、message と、イベントに応じて file、line、kind、error を持ちます。
+func newJSONLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.MessageKey {
+				a.Key = "message"
+			}
+			return a
+		},
+	}))
+}
+
+// logLineWriter は標準のロガーに出力されたメッセージを、1行ずつ slog のイベントに変換します。
+// "Error: "、"Warning: "、"Debug: " の接頭辞からレベルを判定し、接頭辞を除いたものをメッセージとします。
+type logLineWriter struct {
+	logger *slog.Logger
+}
+
+func (w logLineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		level, msg := slog.LevelInfo, line
+		for _, prefix := range []struct {
+			text  string
+			level slog.Level
+		}{
+			{"Error: ", slog.LevelError},
+			{"Warning: ", slog.LevelWarn},
+			{"Debug: ", slog.LevelDebug},
+		} {
+			if rest, ok := strings.CutPrefix(line, prefix.text); ok {
+				level, msg = prefix.level, rest
+				break
+			}
+		}
+		w.logger.Log(context.Background(), level, msg)
+	}
+	return len(p), nil
+}
+
+// logSummary は処理結果の集計を1件のイベントとして出力します。
+func logSummary(l *slog.Logger, sum chiicgrep.Summary) {
+	l.Info("summary",
+		slog.Int("files_scanned", sum.FilesScanned),
+		slog.Int("files_with_matches", sum.FilesWithMatches),
+		slog.Int64("rows_scanned", sum.RowsScanned),
+		slog.Int("matches", sum.Matches),
+		slog.Int("read_errors", len(sum.Errors)),
+		slog.Int("locked_files", len(sum.LockedFiles())),
+		slog.Int("missing_column_warnings", sum.ColumnWarnings),
+		slog.Int("schema_violations", len(sum.SchemaViolations)),
+		slog.Float64("elapsed_seconds", sum.Elapsed.Seconds()),
+	)
+}
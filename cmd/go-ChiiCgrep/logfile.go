@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// logFileBackups はローテーションで残す古いログファイルの数です（run.log.1 から run.log.3 まで）。
+const logFileBackups = 3
+
+// rotatingFile はサイズが上限を超えるとローテーションするログファイルです。
+// 上限を超える書き込みの前に path を path.1 に、path.1 を path.2 に…と名前を変え、新しいファイルに書き込みます。
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	f       *os.File
+	size    int64
+}
+
+// openRotatingFile は path のログファイルを追記用に開きます。既に上限を超えている場合はローテーションします。
+func openRotatingFile(path string, maxSize int64) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	if r.size >= maxSize {
+		if err := r.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// open は r.path を追記用に開きます。
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// rotate は現在のファイルを閉じて古いファイルの名前を順に変え、新しいファイルを開きます。
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	for i := logFileBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return fmt.Errorf("could not rotate log file: %w", err)
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// timestampWriter は書き込みの先頭に日時を付けて w に書き込みます。
+// 標準のロガーは1件のメッセージを1回の Write で書き込むため、メッセージごとに日時が付きます。
+type timestampWriter struct {
+	w io.Writer
+}
+
+func (t timestampWriter) Write(p []byte) (int, error) {
+	stamp := time.Now().Format("2006-01-02 15:04:05 ")
+	if _, err := t.w.Write(append([]byte(stamp), p...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
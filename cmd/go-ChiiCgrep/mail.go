@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// mailOptions は処理の完了後にレポートをメールで送る設定です。
+type mailOptions struct {
+	To     []string
+	From   string
+	Server string
+	// Attach はレポートを本文に埋め込む代わりに添付ファイルとして送ります。
+	Attach bool
+}
+
+// enabled はメールの送信が指定されているかを返します。
+func (m mailOptions) enabled() bool {
+	return len(m.To) > 0
+}
+
+// validate は送信に必要な指定がそろっているかを確認します。
+func (m mailOptions) validate(outFile string) error {
+	switch {
+	case m.From == "":
+		return errors.New("-mail-to requires -mail-from")
+	case m.Server == "":
+		return errors.New("-mail-to requires -smtp-server")
+	case outFile == "":
+		return errors.New("-mail-to requires -out")
+	case isCompressed(outFile) && !m.Attach:
+		return errors.New("a compressed report can only be sent with -mail-attach")
+	}
+	if _, _, err := net.SplitHostPort(m.Server); err != nil {
+		return fmt.Errorf("-smtp-server must be host:port: %v", err)
+	}
+	return nil
+}
+
+// sendReport はレポートのファイル path をメールで送ります。件名には処理結果の件数を含めます。
+// 環境変数 CHIICGREP_SMTP_USER と CHIICGREP_SMTP_PASSWORD が設定されている場合は PLAIN 認証を行います。
+func sendReport(m mailOptions, path string, isHTML bool, sum chiicgrep.Summary) error {
+	report, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read report %s: %w", path, err)
+	}
+	msg := buildMail(m, mailSubject(sum), filepath.Base(path), report, isHTML, time.Now())
+
+	var auth smtp.Auth
+	if user := os.Getenv("CHIICGREP_SMTP_USER"); user != "" {
+		host, _, _ := net.SplitHostPort(m.Server)
+		auth = smtp.PlainAuth("", user, os.Getenv("CHIICGREP_SMTP_PASSWORD"), host)
+	}
+	return smtp.SendMail(m.Server, auth, m.From, m.To, msg)
+}
+
+// mailSubject は処理結果の件数を含むメールの件名を返します。
+func mailSubject(sum chiicgrep.Summary) string {
+	subject := fmt.Sprintf("ChiiCgrep report: %d matching rows in %d of %d files", sum.Matches, sum.FilesWithMatches, sum.FilesScanned)
+	if n := len(sum.Errors) + len(sum.SchemaViolations); n > 0 {
+		subject += fmt.Sprintf(", %d errors", n)
+	}
+	return subject
+}
+
+// buildMail はレポートを本文または添付ファイルとするメールのメッセージを組み立てます。
+// 日本語を含むため、件名とファイル名はMIMEエンコードし、本文はBase64でエンコードします。
+func buildMail(m mailOptions, subject, name string, report []byte, isHTML bool, date time.Time) []byte {
+	contentType := "text/plain; charset=UTF-8"
+	switch {
+	case isCompressed(name):
+		contentType = "application/gzip"
+	case isHTML:
+		contentType = "text/html; charset=UTF-8"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if !m.Attach {
+		fmt.Fprintf(&b, "Content-Type: %s\r\n", contentType)
+		b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64Lines(&b, report)
+		return b.Bytes()
+	}
+
+	boundary := fmt.Sprintf("chiicgrep-%d", date.UnixNano())
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64Lines(&b, []byte(subject+"\r\n"))
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	fmt.Fprintf(&b, "Content-Type: %s\r\n", contentType)
+	b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	fmt.Fprintf(&b, "Content-Disposition: attachment; filename=\"%s\"\r\n\r\n", mime.QEncoding.Encode("UTF-8", name))
+	writeBase64Lines(&b, report)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes()
+}
+
+// writeBase64Lines は data をBase64でエンコードし、76文字ごとに改行して b に書き込みます。
+func writeBase64Lines(b *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"unicode"
+)
+
+// 終了コード。grep と同様に、一致の有無とエラーを区別します。
+const (
+	exitMatch   = 0 // 1件以上のレコードが一致した
+	exitNoMatch = 1 // 一致するレコードがなかった
+	exitError   = 2 // エラーが発生した
+	// exitInterrupted は Ctrl-C などのシグナルで中断されたことを示します。レポートには途中までの結果が含まれます。
+	exitInterrupted = 3
+)
+
+// interruptSignals は処理を中断するシグナルです。Ctrl-C のほか、サービスの停止などで送られる SIGTERM も対象とします。
+var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// fatalf はエラーメッセージを出力し、終了コード exitError で終了します。
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(exitError)
+}
+
+// quiet は -quiet が指定されたことを示します。警告と処理状況のメッセージを出力しません。
+var quiet bool
+
+// warnf は -quiet でない場合に警告を出力します。
+func warnf(format string, args ...any) {
+	if !quiet {
+		log.Printf("Warning: "+format, args...)
+	}
+}
+
+// infof は -quiet でない場合に処理状況のメッセージを標準エラー出力に出力します。
+func infof(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// command はサブコマンドを表します。
+type command struct {
+	name        string
+	description string
+	run         func(args []string)
+}
+
+// commands は利用可能なサブコマンドの一覧です。
+var commands = []command{
+	{name: "extract", description: "Extract matching rows from CSV files into a report (default).", run: runExtract},
+	{name: "stats", description: "Count matching rows per distinct value of a column.", run: runStats},
+	{name: "diff", description: "List rows added, removed and changed between two CSV inputs.", run: runDiff},
+	{name: "inspect", description: "Report the inferred type and value summary of each column.", run: runInspect},
+	{name: "serve", description: "Host a web UI for building reports interactively.", run: runServe},
+}
+
+// usage はサブコマンドの一覧を含む全体の使い方を出力します。
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "Run '%s <command> -h' for the options of each command.\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "If the command is omitted, extract is assumed.")
+	fmt.Fprintf(os.Stderr, "Run '%s -version' to print the version and build information.\n", os.Args[0])
+}
+
+// openFile は指定されたファイルまたはURLを開きます。
+// browser が空の場合はOSの既定のアプリケーションで、それ以外は browser のコマンドで開きます。
+func openFile(path, browser string) error {
+	cmd := openCommand(runtime.GOOS, browser, path)
+	if strings.TrimSpace(browser) == "" {
+		return cmd.Run()
+	}
+	// ブラウザは閉じられるまで終了しないため、終了を待たない
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// openCommand は goos で path を開くコマンドを組み立てます。
+// browser には "firefox --new-window" のように引数を含めて指定できます。
+func openCommand(goos, browser, path string) *exec.Cmd {
+	if args := splitCommandLine(browser); len(args) > 0 {
+		return exec.Command(args[0], append(args[1:], path)...)
+	}
+	switch goos {
+	case "windows":
+		// `start` はパスにスペースが含まれていても正しく動作する。最初の "" はウィンドウタイトル
+		return exec.Command("cmd", "/c", "start", "", path)
+	case "darwin":
+		return exec.Command("open", path)
+	default:
+		return exec.Command("xdg-open", path)
+	}
+}
+
+// splitCommandLine はコマンドラインを空白で区切ります。"C:\Program Files\..." のように
+// 二重引用符で囲んだ部分は、空白を含めて1つの引数として扱います。
+func splitCommandLine(s string) []string {
+	var args []string
+	var b strings.Builder
+	quoted, inArg := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && unicode.IsSpace(r):
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		default:
+			b.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return args
+}
+
+func main() {
+	log.SetFlags(0)
+
+	args := os.Args[1:]
+	// サブコマンドが省略された場合は、従来どおり extract として扱う
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runExtract(args)
+		return
+	}
+
+	switch args[0] {
+	case "help":
+		usage()
+		return
+	case "version":
+		fmt.Println(versionString())
+		return
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			c.run(args[1:])
+			return
+		}
+	}
+	log.Printf("Error: unknown command '%s'", args[0])
+	usage()
+	os.Exit(exitError)
+}
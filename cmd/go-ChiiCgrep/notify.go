@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// webhookTimeout は -notify-webhook の送信を待つ時間です。
+const webhookTimeout = 10 * time.Second
+
+// webhookPayload は -notify-webhook で送信するJSONです。
+// Slack や Teams の Incoming Webhook は text をメッセージとして表示し、その他のキーは無視します。
+type webhookPayload struct {
+	Text             string         `json:"text"`
+	FilesScanned     int            `json:"files_scanned"`
+	FilesWithMatches int            `json:"files_with_matches"`
+	Matches          int            `json:"matches"`
+	Output           string         `json:"output,omitempty"`
+	Errors           []webhookError `json:"errors"`
+}
+
+// webhookError は webhookPayload に含める、ファイルごとのエラーです。
+type webhookError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// newWebhookPayload は処理結果から送信する内容を作成します。output は出力ファイルのパスで、ない場合は空です。
+func newWebhookPayload(sum chiicgrep.Summary, output string) webhookPayload {
+	p := webhookPayload{
+		FilesScanned:     sum.FilesScanned,
+		FilesWithMatches: sum.FilesWithMatches,
+		Matches:          sum.Matches,
+		Output:           output,
+		Errors:           []webhookError{},
+	}
+	for _, e := range sum.Errors {
+		p.Errors = append(p.Errors, webhookError{File: e.File, Error: e.Err.Error()})
+	}
+	for _, v := range sum.SchemaViolations {
+		p.Errors = append(p.Errors, webhookError{File: v.File, Error: "missing required columns: " + strings.Join(v.Missing, ", ")})
+	}
+
+	text := fmt.Sprintf("ChiiCgrep: %d matching rows in %d of %d files", p.Matches, p.FilesWithMatches, p.FilesScanned)
+	if len(p.Errors) > 0 {
+		text += fmt.Sprintf(", %d errors", len(p.Errors))
+	}
+	if output != "" {
+		text += "\nOutput: " + output
+	}
+	p.Text = text
+	return p
+}
+
+// notifyWebhook は処理結果を url にJSONでPOSTします。2xx 以外の応答はエラーとします。
+func notifyWebhook(url string, payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// outputBufferSize は出力のバッファサイズです。
+	outputBufferSize = 256 * 1024
+	// outputFlushInterval はバッファの内容を定期的に書き出す間隔です。
+	outputFlushInterval = time.Second
+)
+
+// flushingWriter は書き込みを bufio.Writer でまとめてシステムコールを減らしつつ、
+// 一定時間ごとに内容を書き出して、パイプやファイルの読み手が途中経過を確認できるようにします。
+type flushingWriter struct {
+	*bufio.Writer
+	interval  time.Duration
+	lastFlush time.Time
+}
+
+// newFlushingWriter は w への書き込みをバッファする flushingWriter を作成します。
+func newFlushingWriter(w io.Writer) *flushingWriter {
+	return &flushingWriter{
+		Writer:    bufio.NewWriterSize(w, outputBufferSize),
+		interval:  outputFlushInterval,
+		lastFlush: time.Now(),
+	}
+}
+
+// Write は p をバッファに書き込み、前回の書き出しから interval 以上経過していればバッファを書き出します。
+func (w *flushingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if now := time.Now(); now.Sub(w.lastFlush) >= w.interval {
+		w.lastFlush = now
+		return n, w.Flush()
+	}
+	return n, nil
+}
+
+// WriteString は s をバッファに書き込みます。書き出しの判定は Write と同じです。
+func (w *flushingWriter) WriteString(s string) (int, error) {
+	n, err := w.Writer.WriteString(s)
+	if err != nil {
+		return n, err
+	}
+	if now := time.Now(); now.Sub(w.lastFlush) >= w.interval {
+		w.lastFlush = now
+		return n, w.Flush()
+	}
+	return n, nil
+}
+
+// outputFile は出力ファイルと同じフォルダの一時ファイルに書き込み、Commit で本来の名前に置き換えます。
+// 処理が途中で失敗したり中断されたりしても、フッターまで書き込まれていない不完全なレポートが残らず、既存のファイルもそのまま残ります。
+// path が .gz で終わる場合は gzip で圧縮して書き込みます。
+type outputFile struct {
+	file *os.File
+	gz   *gzip.Writer
+	path string
+	// keepPrev は置き換える前のファイルを prevPath の名前で残します（-keep-prev）。
+	keepPrev bool
+	done     bool
+}
+
+// createOutputFile は path に書き込むための outputFile を作成します。
+func createOutputFile(path string, keepPrev bool) (*outputFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("could not create output file %s: %w", path, err)
+	}
+	// 一時ファイルは所有者しか読めない権限で作成されるため、os.Create で作成した場合と揃える
+	f.Chmod(0o644)
+	o := &outputFile{file: f, path: path, keepPrev: keepPrev}
+	if isCompressed(path) {
+		o.gz = gzip.NewWriter(f)
+		o.gz.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return o, nil
+}
+
+// Write は p を一時ファイルに書き込みます。
+func (f *outputFile) Write(p []byte) (int, error) {
+	if f.gz != nil {
+		return f.gz.Write(p)
+	}
+	return f.file.Write(p)
+}
+
+// Commit は一時ファイルを閉じ、path の名前に置き換えます。
+func (f *outputFile) Commit() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			f.file.Close()
+			os.Remove(f.file.Name())
+			return fmt.Errorf("failed to write to output %s: %w", f.path, err)
+		}
+	}
+	if err := f.file.Close(); err != nil {
+		os.Remove(f.file.Name())
+		return fmt.Errorf("could not close output file %s: %w", f.path, err)
+	}
+	if f.keepPrev {
+		if err := os.Rename(f.path, prevPath(f.path)); err != nil && !os.IsNotExist(err) {
+			os.Remove(f.file.Name())
+			return fmt.Errorf("could not keep previous output %s: %w", f.path, err)
+		}
+	}
+	if err := os.Rename(f.file.Name(), f.path); err != nil {
+		os.Remove(f.file.Name())
+		return fmt.Errorf("could not write output file %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// Abort は一時ファイルを閉じて削除します。path のファイルは変更しません。Commit の後に呼び出した場合は何もしません。
+func (f *outputFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
+
+// isCompressed は path が gzip で圧縮して出力するファイルかを返します。
+func isCompressed(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".gz")
+}
+
+// uncompressedExt は path の拡張子を返します。.gz で終わる場合はその前の拡張子です（report.html.gz の場合は .html）。
+func uncompressedExt(path string) string {
+	if isCompressed(path) {
+		path = path[:len(path)-len(".gz")]
+	}
+	return filepath.Ext(path)
+}
+
+// prevPath は -keep-prev で残す前回の出力ファイルの名前を返します。report.html の場合は report.prev.html、
+// report.html.gz の場合は report.prev.html.gz です。
+func prevPath(path string) string {
+	ext := uncompressedExt(path)
+	if isCompressed(path) {
+		ext += path[len(path)-len(".gz"):]
+	}
+	return strings.TrimSuffix(path, ext) + ".prev" + ext
+}
+
+// unsafeFileChars はファイル名に使えない文字を置き換えます。
+var unsafeFileChars = strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_")
+
+// splitOutputPath は -split-by-tag でタグ tag のレコードを書き込むファイルのパスを返します。
+// -out と同じフォルダに、タグ名と -out と同じ拡張子で作成します（report.html と important の場合は important.html）。
+func splitOutputPath(out, tag string) string {
+	ext := uncompressedExt(out)
+	if isCompressed(out) {
+		ext += out[len(out)-len(".gz"):]
+	}
+	return filepath.Join(filepath.Dir(out), unsafeFileChars.Replace(tag)+ext)
+}
+
+// checkOverwrite は paths のうち既に存在するファイルを上書きしてよいかを確認します。
+// force（-force）の場合は常に上書きします。prompt が true で標準入力と標準エラー出力が端末の場合は確認を求め、
+// それ以外の場合は既存のファイルがあればエラーを返します。
+func checkOverwrite(paths []string, force, prompt bool) error {
+	if force {
+		return nil
+	}
+	var in *bufio.Reader
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		if !prompt || !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+			return fmt.Errorf("%s already exists (use -force to overwrite it)", path)
+		}
+		if in == nil {
+			in = bufio.NewReader(os.Stdin)
+		}
+		fmt.Fprintf(os.Stderr, "%s already exists. Overwrite? [y/N]: ", path)
+		answer, err := in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return fmt.Errorf("%s already exists; not overwritten", path)
+		}
+	}
+	return nil
+}
+
+// prepareOutputDirs は paths を作成するフォルダを用意します。mkdir（-no-mkdir を指定していない場合）が true なら
+// 存在しないフォルダを作成し、false ならフォルダがなければエラーを返します。
+// 入力の検索や読み込みに時間をかけた後で出力ファイルを作成できずに失敗することのないよう、処理を始める前に呼び出します。
+func prepareOutputDirs(paths []string, mkdir bool) error {
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if mkdir {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return fmt.Errorf("could not create output directory %s: %w", dir, err)
+			}
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("output directory %s does not exist (omit -no-mkdir to create it)", dir)
+		}
+	}
+	return nil
+}
+
+// isTerminal は f が端末かどうかを返します。
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// outputPlaceholder は -out のファイル名のプレースホルダーです。
+var outputPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// expandOutputPath は -out のファイル名のプレースホルダーを置き換えます。
+// 定期的な実行でも実行ごとに異なり、名前順に並べると日時順になるファイル名を作れます。
+//
+//	{date}       実行日（2026-10-17）
+//	{time}       実行時刻（15-04-05）
+//	{input-base} -in のファイル名（拡張子を除く）またはフォルダ名
+func expandOutputPath(path, input string, now time.Time) (string, error) {
+	var err error
+	expanded := outputPlaceholder.ReplaceAllStringFunc(path, func(m string) string {
+		switch name := m[1 : len(m)-1]; name {
+		case "date":
+			return now.Format("2006-01-02")
+		case "time":
+			return now.Format("15-04-05")
+		case "input-base":
+			return inputBase(input)
+		default:
+			if err == nil {
+				err = fmt.Errorf("unknown placeholder %s in %s (use {date}, {time} or {input-base})", m, path)
+			}
+			return m
+		}
+	})
+	return expanded, err
+}
+
+// inputBase は {input-base} に使う入力の名前を返します。
+func inputBase(input string) string {
+	if input == "-" {
+		return "stdin"
+	}
+	if abs, err := filepath.Abs(input); err == nil {
+		input = abs
+	}
+	base := filepath.Base(input)
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		return base
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// progressPrinter は進捗を標準エラー出力の1行に上書き表示し、完了したファイルごとの処理時間を出力します。
+type progressPrinter struct {
+	w       io.Writer
+	lastLen int
+}
+
+// update は進捗表示を更新します。chiicgrep.Config.OnProgress に渡して使用します。
+func (p *progressPrinter) update(pr chiicgrep.Progress) {
+	if pr.File != "" {
+		p.clear()
+		fmt.Fprintf(p.w, "%s (%s)\n", pr.File, pr.FileElapsed.Round(time.Millisecond))
+	}
+
+	eta := "--"
+	if pr.FilesDone > 0 && pr.FilesDone < pr.FilesTotal {
+		remaining := time.Duration(float64(pr.Elapsed) / float64(pr.FilesDone) * float64(pr.FilesTotal-pr.FilesDone))
+		eta = remaining.Round(time.Second).String()
+	} else if pr.FilesDone == pr.FilesTotal {
+		eta = "0s"
+	}
+	line := fmt.Sprintf("[%d/%d files] %d rows scanned, %d matches, elapsed %s, ETA %s",
+		pr.FilesDone, pr.FilesTotal, pr.RowsScanned, pr.Matches, pr.Elapsed.Round(time.Second), eta)
+	p.print(line)
+}
+
+// finish は進捗表示の行を確定させます。
+func (p *progressPrinter) finish() {
+	if p.lastLen > 0 {
+		fmt.Fprintln(p.w)
+		p.lastLen = 0
+	}
+}
+
+// print は現在の行を line で上書きします。前回より短い場合は残りを空白で消します。
+func (p *progressPrinter) print(line string) {
+	n := utf8.RuneCountInString(line)
+	pad := ""
+	if p.lastLen > n {
+		pad = strings.Repeat(" ", p.lastLen-n)
+	}
+	fmt.Fprintf(p.w, "\r%s%s", line, pad)
+	p.lastLen = n
+}
+
+// clear は現在の進捗行を消去します。
+func (p *progressPrinter) clear() {
+	if p.lastLen > 0 {
+		fmt.Fprintf(p.w, "\r%s\r", strings.Repeat(" ", p.lastLen))
+		p.lastLen = 0
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// cronSchedule は -schedule で指定された、分 時 日 月 曜日 の5つのフィールドからなるcron式です。
+// 各フィールドは *、数値、範囲（1-5）、リスト（1,15）、間隔（*/15、9-17/2）を組み合わせて指定できます。
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// 日と曜日の両方が * 以外の場合は、cronと同様にどちらかに一致すれば実行します。
+	domStar, dowStar bool
+}
+
+// cronShortcuts はよく使う間隔の略記です。
+var cronShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseCron はcron式を解析します。
+func parseCron(expr string) (*cronSchedule, error) {
+	if s, ok := cronShortcuts[strings.TrimSpace(expr)]; ok {
+		expr = s
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields (minute hour day month weekday)", expr)
+	}
+	var c cronSchedule
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: minute: %v", expr, err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: hour: %v", expr, err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day: %v", expr, err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: month: %v", expr, err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: weekday: %v", expr, err)
+	}
+	// 7 は 0 と同じ日曜日
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*"
+	c.dowStar = fields[4] == "*"
+	if c.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never runs", expr)
+	}
+	return &c, nil
+}
+
+// parseCronField はcron式の1つのフィールドを、一致する値のビット集合として解析します。
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// next は t より後で、スケジュールに一致する最初の時刻（分単位）を返します。5年以内に一致する時刻がない場合はゼロ値を返します。
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches は t の日付が日と曜日のフィールドに一致するかを返します。
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// scheduleStatus は -status-addr で返す、スケジュール実行の状態です。
+type scheduleStatus struct {
+	mu       sync.Mutex
+	Schedule string        `json:"schedule"`
+	Running  bool          `json:"running"`
+	NextRun  time.Time     `json:"next_run"`
+	Runs     int           `json:"runs"`
+	LastRun  *scheduledRun `json:"last_run,omitempty"`
+}
+
+// scheduledRun は1回のスケジュール実行の結果です。
+type scheduledRun struct {
+	Start        time.Time `json:"start"`
+	DurationMS   int64     `json:"duration_ms"`
+	Output       string    `json:"output"`
+	FilesScanned int       `json:"files_scanned"`
+	Matches      int       `json:"matches"`
+	Errors       int       `json:"errors"`
+	ExitCode     int       `json:"exit_code"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// ServeHTTP は状態をJSONで返します。
+func (s *scheduleStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// update は状態を排他的に更新します。
+func (s *scheduleStatus) update(f func(*scheduleStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s)
+}
+
+// runSchedule は -schedule のcron式に従ってレポートを繰り返し生成します。ctx がキャンセルされるまで戻りません。
+// -out のプレースホルダーは実行ごとに展開し、生成後は -upload、-mail-to、-notify-webhook の指定に従ってレポートを送ります。
+func runSchedule(ctx context.Context, opts options) error {
+	sched, err := parseCron(opts.Schedule)
+	if err != nil {
+		return err
+	}
+	status := &scheduleStatus{Schedule: opts.Schedule}
+	if opts.StatusAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(opts.StatusAddr, status); err != nil {
+				log.Printf("Error: status server: %v", err)
+			}
+		}()
+	}
+
+	for {
+		next := sched.next(time.Now())
+		status.update(func(s *scheduleStatus) { s.NextRun = next })
+		infof("Next run at %s. Press Ctrl-C to stop.\n", next.Format("2006-01-02 15:04"))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		status.update(func(s *scheduleStatus) { s.Running = true })
+		run := runScheduled(ctx, opts)
+		status.update(func(s *scheduleStatus) {
+			s.Running = false
+			s.Runs++
+			s.LastRun = &run
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// runScheduled はスケジュールによる1回分のレポートを生成して送ります。
+func runScheduled(ctx context.Context, opts options) scheduledRun {
+	run := scheduledRun{Start: time.Now()}
+	fail := func(err error) scheduledRun {
+		log.Printf("Error: %v", err)
+		run.DurationMS = time.Since(run.Start).Milliseconds()
+		run.ExitCode = exitError
+		run.Error = err.Error()
+		return run
+	}
+
+	var outputs []string
+	for _, tmpl := range opts.OutTemplates {
+		out, err := expandOutputPath(tmpl, opts.InputPath, run.Start)
+		if err != nil {
+			return fail(err)
+		}
+		outputs = append(outputs, out)
+	}
+	opts.OutFile, opts.ExtraOutputs = outputs[0], outputs[1:]
+	if opts.NewOutTemplate != "" {
+		out, err := expandOutputPath(opts.NewOutTemplate, opts.InputPath, run.Start)
+		if err != nil {
+			return fail(err)
+		}
+		opts.NewOut = out
+		outputs = append(outputs, out)
+	}
+	run.Output = opts.OutFile
+	if err := prepareOutputDirs(outputs, !opts.NoMkdir); err != nil {
+		return fail(err)
+	}
+	if opts.UploadSpec != "" {
+		t, err := parseUploadTarget(opts.UploadSpec, filepath.Base(opts.OutFile))
+		if err != nil {
+			return fail(err)
+		}
+		opts.Upload = &t
+	}
+
+	sum, err := writeReport(ctx, opts)
+	run.DurationMS = time.Since(run.Start).Milliseconds()
+	run.FilesScanned, run.Matches = sum.FilesScanned, sum.Matches
+	run.Errors = len(sum.Errors) + len(sum.SchemaViolations)
+	switch {
+	case errors.Is(err, chiicgrep.ErrNoCSVFiles):
+		log.Println("No CSV files found.")
+		run.ExitCode = exitNoMatch
+		return run
+	case interruptedReport(sum, err):
+		run.ExitCode = exitInterrupted
+		run.Error = "interrupted"
+		return run
+	case err != nil:
+		return fail(err)
+	}
+	if !quiet {
+		if jsonLogger != nil {
+			logSummary(jsonLogger, sum)
+		} else {
+			printSummary(summaryOutput, sum)
+		}
+	}
+	run.ExitCode = exitCode(sum)
+	if !deliverReport(opts, sum) {
+		run.ExitCode = exitError
+		run.Error = "could not deliver the report"
+	}
+	return run
+}
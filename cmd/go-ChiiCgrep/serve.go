@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// serveOptions は serve サブコマンドの設定を保持します。
+type serveOptions struct {
+	InputPath      string
+	Recursive      bool
+	Addr           string
+	Font           string
+	HighlightRules []chiicgrep.Condition
+}
+
+// parseServeFlags は serve サブコマンドの引数を解析します。
+func parseServeFlags(args []string) serveOptions {
+	fs, finish := defineServeFlags()
+	fs.Parse(args)
+	return finish()
+}
+
+// defineServeFlags は serve サブコマンドのフラグを登録した FlagSet と、fs.Parse の後に呼び出して
+// オプションを組み立てる関数を返します。
+func defineServeFlags() (*flag.FlagSet, func() serveOptions) {
+	var opts serveOptions
+	var highlightRules stringList
+	var conf configFlags
+	var logging logFlags
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&opts.InputPath, "in", "", "Path to the CSV file or directory to browse.")
+	fs.BoolVar(&opts.Recursive, "r", false, "Search for CSV files recursively in subdirectories.")
+	fs.StringVar(&opts.Addr, "addr", "localhost:8080", "Address to listen on; only this computer can connect by default (use e.g. \":8080\" to accept connections from other computers).")
+	fs.StringVar(&opts.Font, "font", "", fontUsage)
+	fs.Var(&highlightRules, "highlight-if", "Highlight rule offered in the UI, e.g. \"ステータス=保留\" (repeatable).")
+	conf.register(fs)
+	logging.register(fs)
+	registerAliases(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve -in <path> [-addr localhost:8080] [options]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Hosts a web UI for building reports interactively.")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+
+	return fs, func() serveOptions {
+
+		if err := conf.apply(fs); err != nil {
+			fatalf("Error: %v", err)
+		}
+		if err := logging.apply(); err != nil {
+			fatalf("Error: %v", err)
+		}
+		conf.warnUnknownKeys()
+		if opts.InputPath == "" {
+			fs.Usage()
+			os.Exit(exitError)
+		}
+		rules, err := chiicgrep.ParseConditions(highlightRules)
+		if err != nil {
+			fatalf("Error: -highlight-if: %v", err)
+		}
+		opts.HighlightRules = rules
+		checkFont(opts.Font)
+		return opts
+	}
+}
+
+// runServe は serve サブコマンドを実行します。
+func runServe(args []string) {
+	opts := parseServeFlags(args)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", opts.handleIndex)
+	mux.HandleFunc("GET /report", opts.handleReport)
+
+	infof("Serving %s on %s\n", opts.InputPath, opts.Addr)
+	if err := http.ListenAndServe(opts.Addr, mux); err != nil {
+		fatalf("Error: %v", err)
+	}
+}
+
+// config は入力に関する設定から chiicgrep.Config を構成します。
+func (o serveOptions) config() chiicgrep.Config {
+	return chiicgrep.Config{InputPath: o.InputPath, Recursive: o.Recursive}
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="UTF-8">
+<title>ChiiCgrep</title>
+<style>
+body { font-family: "Meiryo UI", "Hiragino Sans", sans-serif; margin: 0; display: flex; height: 100vh; }
+form { width: 22em; padding: 1em; overflow-y: auto; background: #e0f7fa; border-right: 2px solid #0097a7; box-sizing: border-box; }
+fieldset { border: 1px solid #0097a7; margin-bottom: 1em; }
+label { display: block; }
+input[type=text], textarea { width: 100%; box-sizing: border-box; }
+iframe { flex: 1; border: none; height: 100%; }
+.files { color: #555; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<form id="query" action="report" target="report">
+<div class="files">{{.Path}}: {{len .Files}} file(s)</div>
+<fieldset><legend>検索文字列</legend>
+<input type="text" name="target">
+</fieldset>
+<fieldset><legend>列</legend>
+{{range .Headers}}<label><input type="checkbox" name="cols" value="{{.}}"> {{.}}</label>
+{{else}}<div>ヘッダーが見つかりません</div>
+{{end}}</fieldset>
+<fieldset><legend>強調表示</legend>
+{{range .Rules}}<label><input type="checkbox" name="rule" value="{{.}}" checked> {{.}}</label>
+{{end}}<textarea name="rule" rows="3" placeholder="列名=値 (1行に1つ)"></textarea>
+</fieldset>
+</form>
+<iframe name="report"></iframe>
+<script>
+const form = document.getElementById("query");
+let timer;
+function refresh() {
+  clearTimeout(timer);
+  timer = setTimeout(() => form.submit(), 300);
+}
+form.addEventListener("input", refresh);
+form.addEventListener("change", refresh);
+</script>
+</body>
+</html>
+`))
+
+// handleIndex は列や検索条件を選択するUIを返します。
+func (o serveOptions) handleIndex(w http.ResponseWriter, r *http.Request) {
+	files, err := chiicgrep.ReadHeaders(r.Context(), o.config())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Path    string
+		Files   []chiicgrep.FileHeaders
+		Headers []string
+		Rules   []chiicgrep.Condition
+	}{o.InputPath, files, chiicgrep.UniqueHeaders(files), o.HighlightRules}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, data); err != nil {
+		log.Printf("Error: could not render index: %v", err)
+	}
+}
+
+// handleReport はクエリパラメータで指定された条件でレポートを生成して返します。
+func (o serveOptions) handleReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	cfg := o.config()
+	for _, name := range q["cols"] {
+		cfg.Columns = append(cfg.Columns, chiicgrep.Column{Name: name, Label: name})
+	}
+	cfg.SearchTarget = q.Get("target")
+
+	var exprs []string
+	for _, v := range q["rule"] {
+		for _, line := range strings.Split(v, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				exprs = append(exprs, line)
+			}
+		}
+	}
+	rules, err := chiicgrep.ParseConditions(exprs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg.HighlightRules = rules
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if len(cfg.Columns) == 0 {
+		fmt.Fprintln(w, "<p>列を選択してください。</p>")
+		return
+	}
+	renderer := chiicgrep.NewHTMLRenderer(w, chiicgrep.HTMLOptions{Font: o.Font, Generator: versionString()})
+	if err := chiicgrep.NewProcessor(cfg, renderer).Run(r.Context()); err != nil {
+		if errors.Is(err, chiicgrep.ErrNoCSVFiles) {
+			fmt.Fprintln(w, "<p>CSVファイルが見つかりません。</p>")
+			return
+		}
+		log.Printf("Error: %v", err)
+	}
+}
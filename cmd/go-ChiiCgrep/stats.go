@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// statsOptions は stats サブコマンドの設定を保持します。
+type statsOptions struct {
+	chiicgrep.Config
+	// GroupBy は値の組み合わせごとに行数を数える列です。2列以上の場合、HTMLの表には1列目の値ごとの小計の行が入ります。
+	GroupBy []string
+	// Sum はグループごとに数値を合計する列です。
+	Sum     string
+	OutFile string
+	Format  string
+	// NoMkdir は -out のフォルダがない場合に作成せず、エラーとします（-no-mkdir）。
+	NoMkdir bool
+	// Force は既存の出力ファイルを確認せずに上書きします（-force）。
+	Force bool
+	// KeepPrev は置き換える前の出力ファイルを残します（-keep-prev）。
+	KeepPrev bool
+}
+
+// parseStatsFlags は stats サブコマンドの引数を解析します。
+func parseStatsFlags(args []string) statsOptions {
+	fs, finish := defineStatsFlags()
+	fs.Parse(args)
+	return finish()
+}
+
+// defineStatsFlags は stats サブコマンドのフラグを登録した FlagSet と、fs.Parse の後に呼び出して
+// オプションを組み立てる関数を返します。
+func defineStatsFlags() (*flag.FlagSet, func() statsOptions) {
+	var opts statsOptions
+	var groupBy string
+	var colMaps, colAliases stringList
+	var conf configFlags
+	var logging logFlags
+
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	fs.StringVar(&opts.InputPath, "in", "", "Path to the CSV file or directory (\"-\" reads CSV from standard input).")
+	fs.StringVar(&groupBy, "group-by", "", "Comma-separated columns whose distinct values (or combinations of values) are counted; with two or more, the HTML table adds a subtotal row per value of the first column.")
+	fs.StringVar(&opts.Sum, "sum", "", "Column whose numbers are summed per group, with subtotals and a grand total.")
+	fs.StringVar(&opts.SearchTarget, "target", "", "Only count rows containing this string.")
+	fs.BoolVar(&opts.Recursive, "r", false, "Search for CSV files recursively in subdirectories.")
+	fs.BoolVar(&opts.NoIgnore, "no-ignore", false, "Process all CSV files, ignoring exclusions in "+chiicgrep.IgnoreFileName+" files.")
+	fs.BoolVar(&opts.TrimCells, "trim-cells", false, "Strip leading/trailing whitespace (including full-width spaces) from headers and values.")
+	fs.Var(&colMaps, "col-map", colMapUsage)
+	fs.Var(&colAliases, "col-alias", colAliasUsage)
+	fs.StringVar(&opts.OutFile, "out", "", "Path to the output file (optional).")
+	fs.BoolVar(&opts.NoMkdir, "no-mkdir", false, "Fail instead of creating missing directories in the -out path.")
+	fs.BoolVar(&opts.Force, "force", false, "Overwrite an existing -out file without asking (otherwise asks on a terminal and refuses elsewhere).")
+	fs.BoolVar(&opts.KeepPrev, "keep-prev", false, "Keep the previous -out file as <name>.prev.<ext> (e.g. report.prev.html) when replacing it.")
+	fs.StringVar(&opts.Format, "format", "", "Output format: html, csv, json or text (default: html with -out, text otherwise).")
+	fs.IntVar(&opts.Retry, "retry", 0, "Number of times to retry opening a file locked by another process (e.g. open in Excel).")
+	fs.DurationVar(&opts.RetryWait, "retry-wait", time.Second, "Wait before the first retry of a locked file; doubled on each retry.")
+	fs.IntVar(&opts.Jobs, "jobs", runtime.NumCPU(), "Number of files to process concurrently.")
+	conf.register(fs)
+	logging.register(fs)
+	registerAliases(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s stats -in <path> -group-by <col1,col2> [-sum <column>] [options]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Counts matching rows per distinct value of one or more columns.")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+
+	return fs, func() statsOptions {
+
+		if err := conf.apply(fs); err != nil {
+			fatalf("Error: %v", err)
+		}
+		opts.ColumnMaps = parseColumnMaps(colMaps)
+		opts.ColumnAliases = parseColumnAliases(colAliases)
+		if err := logging.apply(); err != nil {
+			fatalf("Error: %v", err)
+		}
+		conf.warnUnknownKeys()
+		if opts.InputPath == "" || groupBy == "" {
+			fs.Usage()
+			os.Exit(exitError)
+		}
+		opts.GroupBy = strings.Split(groupBy, ",")
+		if opts.InputPath == "-" {
+			opts.Source = &chiicgrep.ReaderSource{Name: "stdin", Reader: os.Stdin}
+		}
+		if opts.Format == "" {
+			opts.Format = "text"
+			if opts.OutFile != "" {
+				opts.Format = "html"
+			}
+		}
+		switch opts.Format {
+		case "html", "csv", "json", "text":
+		default:
+			fatalf("Error: unknown output format %q", opts.Format)
+		}
+		if opts.OutFile != "" {
+			out, err := expandOutputPath(opts.OutFile, opts.InputPath, time.Now())
+			if err != nil {
+				fatalf("Error: -out: %v", err)
+			}
+			opts.OutFile = out
+			if err := checkOverwrite([]string{opts.OutFile}, opts.Force, opts.Source == nil); err != nil {
+				fatalf("Error: %v", err)
+			}
+			if err := prepareOutputDirs([]string{opts.OutFile}, !opts.NoMkdir); err != nil {
+				fatalf("Error: %v", err)
+			}
+		}
+		return opts
+	}
+}
+
+// runStats は stats サブコマンドを実行します。
+func runStats(args []string) {
+	opts := parseStatsFlags(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), interruptSignals...)
+	defer stop()
+
+	groups, err := chiicgrep.GroupValues(ctx, opts.Config, opts.GroupBy, opts.Sum)
+	if err != nil {
+		if ctx.Err() != nil {
+			stop()
+			log.Println("Interrupted: nothing was written.")
+			os.Exit(exitInterrupted)
+		}
+		if errors.Is(err, chiicgrep.ErrNoCSVFiles) {
+			log.Println("No CSV files found.")
+			os.Exit(exitNoMatch)
+		}
+		fatalf("Error: %v", err)
+	}
+	if err := writeStats(opts, groups); err != nil {
+		fatalf("Error: %v", err)
+	}
+	if len(groups) == 0 {
+		os.Exit(exitNoMatch)
+	}
+}
+
+// writeStats はグループごとの行数を指定された形式で -out のファイルまたは標準出力に書き込みます。
+func writeStats(opts statsOptions, groups []chiicgrep.GroupCount) (err error) {
+	var w io.Writer = os.Stdout
+	if opts.OutFile != "" {
+		f, ferr := createOutputFile(opts.OutFile, opts.KeepPrev)
+		if ferr != nil {
+			return ferr
+		}
+		// 最後まで書き込めた場合だけ既存のファイルを置き換える
+		defer func() {
+			if err != nil {
+				f.Abort()
+			} else {
+				err = f.Commit()
+			}
+		}()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+
+	switch opts.Format {
+	case "html":
+		err = writeStatsHTML(bw, opts, groups)
+	case "csv":
+		err = writeStatsCSV(bw, opts, groups)
+	case "json":
+		err = writeStatsJSON(bw, opts, groups)
+	default:
+		for _, g := range groups {
+			fmt.Fprintf(bw, "%d\t%s", g.Count, strings.Join(g.Values, "\t"))
+			if opts.Sum != "" {
+				fmt.Fprintf(bw, "\t%s", g.Sum.Format(chiicgrep.AggSum))
+			}
+			fmt.Fprintln(bw)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	return nil
+}
+
+// sumLabel は -sum の列の見出しを返します。
+func sumLabel(column string) string {
+	return column + "の合計"
+}
+
+// writeStatsCSV は "列名,...,件数[,合計]" のヘッダーに続けてグループごとの行数を出力します。
+func writeStatsCSV(w io.Writer, opts statsOptions, groups []chiicgrep.GroupCount) error {
+	cw := csv.NewWriter(w)
+	header := append(slices.Clone(opts.GroupBy), "件数")
+	if opts.Sum != "" {
+		header = append(header, sumLabel(opts.Sum))
+	}
+	cw.Write(header)
+	for _, g := range groups {
+		row := append(slices.Clone(g.Values), fmt.Sprint(g.Count))
+		if opts.Sum != "" {
+			row = append(row, g.Sum.Format(chiicgrep.AggSum))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeStatsJSON はグループごとの行数をJSONとして出力します。
+// 1列の場合、value はその値です。2列以上の場合は values に列ごとの値が入り、value は値を " / " でつないだものです。
+func writeStatsJSON(w io.Writer, opts statsOptions, groups []chiicgrep.GroupCount) error {
+	type item struct {
+		Value  string   `json:"value"`
+		Values []string `json:"values,omitempty"`
+		Count  int      `json:"count"`
+		Sum    *float64 `json:"sum,omitempty"`
+	}
+	out := struct {
+		Column string `json:"column"`
+		Sum    string `json:"sum_column,omitempty"`
+		Values []item `json:"values"`
+	}{Column: strings.Join(opts.GroupBy, ","), Sum: opts.Sum, Values: make([]item, len(groups))}
+	for i, g := range groups {
+		it := item{Value: strings.Join(g.Values, " / "), Count: g.Count}
+		if len(g.Values) > 1 {
+			it.Values = g.Values
+		}
+		if opts.Sum != "" {
+			sum := g.Sum.Sum
+			it.Sum = &sum
+		}
+		out.Values[i] = it
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(out)
+}
+
+var statsTemplate = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="UTF-8">
+<title>{{.Title}} の集計</title>
+<style>
+body { font-family: "Meiryo UI", "Hiragino Sans", sans-serif; margin: 1.5em; color: #222; background: #fafafa; }
+h1 { font-size: 1.4em; border-bottom: 2px solid #0097a7; padding-bottom: 0.2em; }
+table { border-collapse: collapse; background: #fff; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.8em; }
+th { background: #e0f7fa; color: #00838f; }
+td.count { text-align: right; }
+.chart { display: block; margin-bottom: 1em; }
+tr.subtotal td { background: #f1f8e9; font-weight: bold; border-bottom: 2px solid #aaa; }
+tfoot td { font-weight: bold; border-top: 2px solid #0097a7; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} の集計</h1>
+{{.Chart}}<table>
+<thead><tr>{{range .Columns}}<th>{{.}}</th>{{end}}<th>件数</th>{{if .Sum}}<th>{{.Sum}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}{{if .Subtotal}}<tr class="subtotal"><td colspan="{{$.Span}}">{{index .Values 0}} 小計</td>{{else}}<tr>{{range .Values}}<td>{{.}}</td>{{end}}{{end}}<td class="count">{{.Count}}</td>{{if $.Sum}}<td class="count">{{.SumText}}</td>{{end}}</tr>
+{{end}}</tbody>
+<tfoot><tr><td colspan="{{.Span}}">合計</td><td class="count">{{.Total.Count}}</td>{{if .Sum}}<td class="count">{{.Total.SumText}}</td>{{end}}</tr></tfoot>
+</table>
+</body>
+</html>
+`))
+
+// statsRow は stats のHTMLの表の1行です。Subtotal の場合は1列目の値ごとの小計です。
+type statsRow struct {
+	Values   []string
+	Count    int
+	SumText  string
+	Subtotal bool
+}
+
+// writeStatsHTML はグループごとの行数を、上位の値の円グラフとHTMLの表として出力します。
+// 2列以上でグループにした場合は、1列目の値が変わるごとに小計の行を入れ、最後に総計の行を出力します。
+func writeStatsHTML(w io.Writer, opts statsOptions, groups []chiicgrep.GroupCount) error {
+	var rows []statsRow
+	var total, subtotal chiicgrep.AggregateValues
+	totalCount, subCount := 0, 0
+	nested := len(opts.GroupBy) > 1
+	// 円グラフは1列目の値ごとの行数で描く
+	var firsts []chiicgrep.ValueCount
+	flush := func(first string) {
+		rows = append(rows, statsRow{Values: []string{first}, Count: subCount, SumText: subtotal.Format(chiicgrep.AggSum), Subtotal: true})
+	}
+	for i, g := range groups {
+		if i > 0 && g.Values[0] != groups[i-1].Values[0] {
+			if nested {
+				flush(groups[i-1].Values[0])
+			}
+			subtotal, subCount = chiicgrep.AggregateValues{}, 0
+		}
+		if subCount == 0 {
+			firsts = append(firsts, chiicgrep.ValueCount{Value: g.Values[0]})
+		}
+		firsts[len(firsts)-1].Count += g.Count
+		rows = append(rows, statsRow{Values: g.Values, Count: g.Count, SumText: g.Sum.Format(chiicgrep.AggSum)})
+		subCount += g.Count
+		subtotal.Merge(g.Sum)
+		totalCount += g.Count
+		total.Merge(g.Sum)
+	}
+	if nested && len(groups) > 0 {
+		flush(groups[len(groups)-1].Values[0])
+	}
+	sum := ""
+	if opts.Sum != "" {
+		sum = sumLabel(opts.Sum)
+	}
+	return statsTemplate.Execute(w, struct {
+		Title   string
+		Columns []string
+		Sum     string
+		Span    int
+		Rows    []statsRow
+		Total   statsRow
+		Chart   template.HTML
+	}{strings.Join(opts.GroupBy, "・"), opts.GroupBy, sum, len(opts.GroupBy), rows,
+		statsRow{Count: totalCount, SumText: total.Format(chiicgrep.AggSum)}, template.HTML(chiicgrep.PieChart(firsts, 0))})
+}
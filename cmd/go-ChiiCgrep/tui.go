@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// previewLimit はプレビューに表示するレコードの最大件数です。
+const previewLimit = 20
+
+// errPreviewFull はプレビューの表示件数に達したことを示します。
+var errPreviewFull = errors.New("preview limit reached")
+
+var (
+	menuColor   = color.New(color.FgYellow, color.Bold).SprintFunc()
+	numberColor = color.New(color.FgMagenta).SprintFunc()
+)
+
+// tui は対話モードの状態を保持します。
+type tui struct {
+	opts    options
+	in      *bufio.Scanner
+	out     io.Writer
+	files   []chiicgrep.FileHeaders
+	headers []string
+}
+
+// runTUI はファイル一覧と検出したヘッダーを表示し、列・検索文字列・強調表示規則を
+// 対話的に選びながら一致レコードをプレビューする対話モードを実行します。
+func runTUI(ctx context.Context, opts options) error {
+	t := &tui{opts: opts, in: bufio.NewScanner(os.Stdin), out: os.Stdout}
+
+	files, err := chiicgrep.ReadHeaders(ctx, opts.Config)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return chiicgrep.ErrNoCSVFiles
+	}
+	t.files = files
+	t.headers = chiicgrep.UniqueHeaders(files)
+
+	t.showFiles()
+	if len(t.opts.Columns) == 0 {
+		t.chooseColumns()
+	}
+	for {
+		t.preview(ctx)
+		fmt.Fprintf(t.out, "\n%s [c]列 [t]検索文字列 [h]強調表示 [l]ファイル一覧 [e]HTMLに出力 [q]終了\n", menuColor("操作:"))
+		cmd, ok := t.prompt("> ")
+		if !ok {
+			return nil
+		}
+		switch cmd {
+		case "c":
+			t.chooseColumns()
+		case "t":
+			if target, ok := t.prompt("検索文字列（空欄で解除）: "); ok {
+				t.opts.SearchTarget = target
+			}
+		case "h":
+			t.editHighlightRules()
+		case "l":
+			t.showFiles()
+		case "e":
+			t.export(ctx)
+		case "q":
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// prompt はメッセージを表示して1行読み込みます。入力が終了した場合は false を返します。
+func (t *tui) prompt(msg string) (string, bool) {
+	fmt.Fprint(t.out, msg)
+	if !t.in.Scan() {
+		return "", false
+	}
+	return strings.TrimSpace(t.in.Text()), true
+}
+
+// showFiles は検出したファイルとその列数を表示します。
+func (t *tui) showFiles() {
+	fmt.Fprintf(t.out, "%s %d\n", menuColor("ファイル:"), len(t.files))
+	for i, f := range t.files {
+		fmt.Fprintf(t.out, "  %s %s (%d列)\n", numberColor(i+1), f.File, len(f.Headers))
+	}
+}
+
+// chooseColumns は検出したヘッダーを番号付きで表示し、抽出する列を選ばせます。
+func (t *tui) chooseColumns() {
+	fmt.Fprintln(t.out, menuColor("列:"))
+	for i, h := range t.headers {
+		fmt.Fprintf(t.out, "  %s %s\n", numberColor(i+1), h)
+	}
+	for {
+		line, ok := t.prompt("抽出する列（番号または列名をカンマ区切り）: ")
+		if !ok || line == "" {
+			return
+		}
+		columns, err := t.parseColumnSelection(line)
+		if err != nil {
+			fmt.Fprintf(t.out, "%v\n", err)
+			continue
+		}
+		t.opts.Columns = columns
+		return
+	}
+}
+
+// parseColumnSelection は番号または列名（"列名:表示名" も可）のカンマ区切りを解析します。
+func (t *tui) parseColumnSelection(line string) ([]chiicgrep.Column, error) {
+	var columns []chiicgrep.Column
+	for _, col := range chiicgrep.ParseColumns(line) {
+		col.Name = strings.TrimSpace(col.Name)
+		if n, err := strconv.Atoi(col.Name); err == nil {
+			if n < 1 || n > len(t.headers) {
+				return nil, fmt.Errorf("列番号 %d は範囲外です", n)
+			}
+			if col.Label == col.Name {
+				col.Label = t.headers[n-1]
+			}
+			col.Name = t.headers[n-1]
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// editHighlightRules は強調表示規則の追加・削除を行います。
+func (t *tui) editHighlightRules() {
+	for i, r := range t.opts.HighlightRules {
+		fmt.Fprintf(t.out, "  %s %s\n", numberColor(i+1), r)
+	}
+	line, ok := t.prompt("追加する規則（例: ステータス=保留）、または削除する番号: ")
+	if !ok || line == "" {
+		return
+	}
+	if n, err := strconv.Atoi(line); err == nil {
+		if n >= 1 && n <= len(t.opts.HighlightRules) {
+			t.opts.HighlightRules = append(t.opts.HighlightRules[:n-1], t.opts.HighlightRules[n:]...)
+		}
+		return
+	}
+	rule, err := chiicgrep.ParseCondition(line)
+	if err != nil {
+		fmt.Fprintf(t.out, "%v\n", err)
+		return
+	}
+	t.opts.HighlightRules = append(t.opts.HighlightRules, rule)
+}
+
+// preview は現在の条件で一致するレコードを先頭から previewLimit 件まで表示します。
+func (t *tui) preview(ctx context.Context) {
+	if len(t.opts.Columns) == 0 {
+		fmt.Fprintln(t.out, "列が選択されていません。")
+		return
+	}
+	renderer := chiicgrep.NewTextRenderer(t.out, chiicgrep.TextOptions{Tags: t.opts.TagDefs})
+	count := 0
+	err := chiicgrep.Process(ctx, t.opts.Config, func(rec chiicgrep.Record) error {
+		if count == previewLimit {
+			return errPreviewFull
+		}
+		count++
+		return renderer.Render(rec)
+	})
+	switch {
+	case errors.Is(err, errPreviewFull):
+		fmt.Fprintf(t.out, "（先頭 %d 件のみ表示しています）\n", previewLimit)
+	case err != nil:
+		fmt.Fprintf(t.out, "Error: %v\n", err)
+	case count == 0:
+		fmt.Fprintln(t.out, "一致するレコードはありません。")
+	}
+}
+
+// export は現在の条件でHTMLレポートを出力します。
+func (t *tui) export(ctx context.Context) {
+	path, ok := t.prompt("出力先のHTMLファイル: ")
+	if !ok || path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err == nil && !t.opts.Force {
+		answer, ok := t.prompt(path + " は既に存在します。上書きしますか？ [y/N]: ")
+		if !ok || (strings.ToLower(answer) != "y" && strings.ToLower(answer) != "yes") {
+			return
+		}
+	}
+	if err := prepareOutputDirs([]string{path}, !t.opts.NoMkdir); err != nil {
+		fmt.Fprintf(t.out, "Error: %v\n", err)
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(t.out, "Error: could not create output file %s: %v\n", path, err)
+		return
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	renderer := chiicgrep.NewHTMLRenderer(bw, chiicgrep.HTMLOptions{Font: t.opts.Font, Tags: t.opts.TagDefs, Generator: versionString()})
+	if err := chiicgrep.NewProcessor(t.opts.Config, renderer).Run(ctx); err != nil {
+		fmt.Fprintf(t.out, "Error: %v\n", err)
+		return
+	}
+	if err := bw.Flush(); err != nil {
+		fmt.Fprintf(t.out, "Error: failed to write to output: %v\n", err)
+		return
+	}
+	fmt.Fprintf(t.out, "%s に出力しました。\n", path)
+}
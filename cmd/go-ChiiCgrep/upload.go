@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// uploadTimeout は -upload の送信を待つ時間です。
+const uploadTimeout = 5 * time.Minute
+
+// uploadTarget は -upload で指定されたアップロード先です。
+type uploadTarget struct {
+	// S3 の場合は Bucket と Key、HTTP の場合は URL を使います。
+	Bucket string
+	Key    string
+	URL    string
+}
+
+// parseUploadTarget は "s3://bucket/prefix/" または "https://..." 形式のアップロード先を解析します。
+// 末尾が "/" の場合は、出力ファイル名 name を加えたものをアップロード先とします。
+func parseUploadTarget(s, name string) (uploadTarget, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return uploadTarget{}, fmt.Errorf("invalid upload target %q: %v", s, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" {
+			return uploadTarget{}, fmt.Errorf("invalid upload target %q: missing bucket name", s)
+		}
+		key := strings.TrimPrefix(u.Path, "/")
+		if key == "" || strings.HasSuffix(key, "/") {
+			key += name
+		}
+		return uploadTarget{Bucket: u.Host, Key: key}, nil
+	case "http", "https":
+		if strings.HasSuffix(u.Path, "/") {
+			u.Path += name
+		}
+		return uploadTarget{URL: u.String()}, nil
+	}
+	return uploadTarget{}, fmt.Errorf("invalid upload target %q: expected s3:// or http(s):// URL", redactURL(s))
+}
+
+// String はアップロード先を表示用に返します。
+// 署名付きURLの署名やBasic認証のパスワードがログに残らないよう、URLのユーザー情報とクエリ文字列は伏せます。
+func (t uploadTarget) String() string {
+	if t.URL != "" {
+		return redactURL(t.URL)
+	}
+	return "s3://" + t.Bucket + "/" + t.Key
+}
+
+// uploadFile はファイル path を t にアップロードします。
+func uploadFile(t uploadTarget, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+	var req *http.Request
+	if t.URL != "" {
+		req, err = http.NewRequest(http.MethodPut, t.URL, bytes.NewReader(data))
+	} else {
+		req, err = newS3PutRequest(t, data, time.Now())
+	}
+	if err != nil {
+		return err
+	}
+	if ct := mime.TypeByExtension(uncompressedExt(path)); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	// 圧縮したレポートはブラウザが展開して表示できるよう、元の形式と圧縮方式を示す
+	if isCompressed(path) {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	client := &http.Client{Timeout: uploadTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		// url.Error はリクエストのURLをそのまま含むため、表示用のものに置き換える
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = t.String()
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("upload to %s returned %s: %s", t, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// newS3PutRequest は data を S3 のオブジェクトとして書き込む、AWS署名バージョン4で署名したリクエストを作成します。
+// 認証情報とリージョンは AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、AWS_SESSION_TOKEN、AWS_REGION から読み込みます。
+// AWS_ENDPOINT_URL を設定すると、S3互換のストレージにパス形式のURLでアップロードします。
+func newS3PutRequest(t uploadTarget, data []byte, now time.Time) (*http.Request, error) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to upload to S3")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	path := "/" + awsURIEncode(t.Key)
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", t.Bucket, region)
+	if e := os.Getenv("AWS_ENDPOINT_URL"); e != "" {
+		endpoint = strings.TrimSuffix(e, "/")
+		path = "/" + awsURIEncode(t.Bucket) + path
+	}
+	req, err := http.NewRequest(http.MethodPut, endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	// awsURIEncode でエンコード済みのパスを、そのまま送信する
+	req.URL.RawPath = path
+
+	payloadHash := sha256Hex(data)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	headers := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, payloadHash, amzDate)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+		signed += ";x-amz-security-token"
+		headers += "x-amz-security-token:" + token + "\n"
+	}
+
+	canonical := strings.Join([]string{http.MethodPut, path, "", headers, signed, payloadHash}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonical))}, "\n")
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signed, signature))
+	return req, nil
+}
+
+// awsURIEncode は "/" を残して、英数字と "-._~" 以外の文字をパーセントエンコードします。
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', strings.IndexByte("-._~/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
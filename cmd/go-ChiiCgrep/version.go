@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// ビルド情報です。リリース時に次のように -ldflags で埋め込みます。
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 埋め込まれていない値は、可能な範囲で runtime/debug.ReadBuildInfo から補います。
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// buildInfo はビルド情報を返します。わからない値は "unknown" です。
+func buildInfo() (ver, rev, date string) {
+	ver, rev, date = version, commit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if ver == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			ver = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if rev == "" {
+					rev = s.Value
+					if len(rev) > 12 {
+						rev = rev[:12]
+					}
+				}
+			case "vcs.time":
+				if date == "" {
+					date = s.Value
+				}
+			case "vcs.modified":
+				if s.Value == "true" && commit == "" && rev != "" {
+					rev += "-dirty"
+				}
+			}
+		}
+	}
+	if ver == "" {
+		ver = "devel"
+	}
+	if rev == "" {
+		rev = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+	return ver, rev, date
+}
+
+// versionString は "go-ChiiCgrep v1.2.0 (commit abc1234, built 2024-01-01T00:00:00Z)" の形式でバージョンを返します。
+func versionString() string {
+	ver, rev, date := buildInfo()
+	return fmt.Sprintf("go-ChiiCgrep %s (commit %s, built %s)", ver, rev, date)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"go-ChiiCgrep/pkg/chiicgrep"
+)
+
+// watchDebounce は連続した変更通知をまとめて1回の再生成とするための待ち時間です。
+const watchDebounce = 500 * time.Millisecond
+
+// runWatch は入力を監視し、CSVファイルが追加・変更されるたびにレポートを再生成します。
+// ctx がキャンセルされるまで戻りません。
+func runWatch(ctx context.Context, opts options) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not start watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	info, err := os.Stat(opts.InputPath)
+	if err != nil {
+		return fmt.Errorf("could not stat path %s: %w", opts.InputPath, err)
+	}
+	// 単一ファイルの場合は、保存時に置き換えられても追跡できるよう親フォルダを監視する
+	watchFile := ""
+	if !info.IsDir() {
+		watchFile = filepath.Clean(opts.InputPath)
+		if err := watcher.Add(filepath.Dir(watchFile)); err != nil {
+			return fmt.Errorf("could not watch %s: %w", filepath.Dir(watchFile), err)
+		}
+	} else if err := addWatchDirs(watcher, opts.InputPath, opts.Recursive); err != nil {
+		return err
+	}
+	// 出力先が入力フォルダ内にある場合、出力したCSVファイルの変更で再生成を繰り返さないよう、
+	// 出力と -state のファイルは入力から除き、その変更も無視する
+	outputs := watchOutputs(opts)
+	opts.ExcludeFiles = outputs
+
+	var reload *liveReload
+	if opts.LiveReload != "" {
+		reload = newLiveReload(opts.OutFile)
+		go func() {
+			if err := http.ListenAndServe(opts.LiveReload, reload); err != nil {
+				log.Printf("Error: live reload server: %v", err)
+			}
+		}()
+	}
+
+	generate := func() {
+		start := time.Now()
+		_, err := writeReport(ctx, opts)
+		switch {
+		case errors.Is(err, chiicgrep.ErrNoCSVFiles):
+			log.Println("No CSV files found.")
+		case err != nil && ctx.Err() == nil:
+			log.Printf("Error: %v", err)
+		case err == nil:
+			infof("Report regenerated: %s (%s)\n", opts.OutFile, time.Since(start).Round(time.Millisecond))
+			reload.notify()
+		}
+	}
+
+	generate()
+	if opts.AfterOpen {
+		if reload != nil {
+			if err := openFile(reload.url(opts.LiveReload), opts.Browser); err != nil {
+				log.Printf("Error: could not open browser: %v", err)
+			}
+		} else {
+			openOutput(opts.OutFile, opts.Browser)
+		}
+	}
+	infof("Watching %s for changes. Press Ctrl-C to stop.\n", opts.InputPath)
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) && opts.Recursive && watchFile == "" {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					if err := addWatchDirs(watcher, ev.Name, true); err != nil {
+						warnf("%v", err)
+					}
+				}
+			}
+			if watchFile != "" && filepath.Clean(ev.Name) != watchFile {
+				continue
+			}
+			if !strings.HasSuffix(strings.ToLower(ev.Name), ".csv") || ev.Has(fsnotify.Chmod) {
+				continue
+			}
+			if abs, err := filepath.Abs(ev.Name); err == nil && slices.Contains(outputs, abs) {
+				continue
+			}
+			timer.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			warnf("watcher: %v", err)
+		case <-timer.C:
+			generate()
+		}
+	}
+}
+
+// watchOutputs は -watch の再生成で書き込むファイルの絶対パスを返します。
+// -out、-new-out、-split-by-tag のタグごとのファイル、-keep-prev で残すファイル、-state のファイルを含みます。
+func watchOutputs(opts options) []string {
+	var paths []string
+	if opts.OutFile != "" {
+		paths = append(paths, opts.OutFile)
+		paths = append(paths, opts.ExtraOutputs...)
+		if opts.SplitByTag {
+			for _, tag := range append(tagNames(opts.TagRules), chiicgrep.UntaggedTag) {
+				paths = append(paths, splitOutputPath(opts.OutFile, tag))
+			}
+		}
+	}
+	if opts.NewOut != "" {
+		paths = append(paths, opts.NewOut)
+	}
+	if opts.KeepPrev {
+		for _, p := range paths {
+			paths = append(paths, prevPath(p))
+		}
+	}
+	if opts.StateFile != "" {
+		paths = append(paths, opts.StateFile)
+	}
+	abs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if a, err := filepath.Abs(p); err == nil {
+			abs = append(abs, a)
+		}
+	}
+	return abs
+}
+
+// addWatchDirs は root を監視対象に追加します。recursive の場合はサブフォルダもすべて追加します。
+func addWatchDirs(watcher *fsnotify.Watcher, root string, recursive bool) error {
+	if !recursive {
+		if err := watcher.Add(root); err != nil {
+			return fmt.Errorf("could not watch %s: %w", root, err)
+		}
+		return nil
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				return fmt.Errorf("could not watch %s: %w", path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// liveReloadScript は配信するレポートに挿入され、再生成の通知を受けるとページを再読み込みします。
+const liveReloadScript = `<script>new EventSource("/events").onmessage = () => location.reload();</script>
+`
+
+// liveReload は生成されたレポートを配信し、再生成をブラウザに通知する小さなHTTPサーバーです。
+type liveReload struct {
+	path string
+
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+// newLiveReload は path のレポートを配信する liveReload を作成します。
+func newLiveReload(path string) *liveReload {
+	return &liveReload{path: path, clients: make(map[chan struct{}]bool)}
+}
+
+// url はブラウザで開くためのURLを返します。
+func (l *liveReload) url(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr + "/"
+}
+
+// ServeHTTP は / でレポートを、/events で再生成の通知（Server-Sent Events）を返します。
+func (l *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		data, err := os.ReadFile(l.path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if i := bytes.LastIndex(data, []byte("</body>")); i >= 0 {
+			data = append(data[:i:i], append([]byte(liveReloadScript), data[i:]...)...)
+		} else {
+			data = append(data, liveReloadScript...)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(data)
+	case "/events":
+		l.serveEvents(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveEvents はクライアントが切断するまで再生成の通知を送り続けます。
+func (l *liveReload) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	l.clients[ch] = true
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.clients, ch)
+		l.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// notify は接続中のすべてのブラウザに再読み込みを通知します。l が nil の場合は何もしません。
+func (l *liveReload) notify() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
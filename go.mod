@@ -2,7 +2,13 @@ module go-ChiiCgrep
 
 go 1.23.4
 
-require github.com/fatih/color v1.18.0
+require (
+	github.com/expr-lang/expr v1.17.8
+	github.com/fatih/color v1.18.0
+	github.com/fsnotify/fsnotify v1.8.0
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
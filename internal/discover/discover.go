@@ -0,0 +1,125 @@
+// Package discover は入力のフォルダから処理対象のCSVファイルを列挙します。
+// フォルダ内の除外ファイル（IgnoreFileName）で指定されたファイルとフォルダは対象から除きます。
+package discover
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Options は CSVFiles の列挙のしかたです。
+type Options struct {
+	// Recursive はサブフォルダの中のファイルも対象とします。
+	Recursive bool
+	// UseIgnore はフォルダ内の除外ファイル（IgnoreFileName）で指定されたファイルとフォルダを除きます。
+	UseIgnore bool
+	// Exclude は対象から除くファイルの絶対パスです。出力先が入力のフォルダ内にある場合に、
+	// 出力したファイルを入力として読み込まないために使います。
+	Exclude []string
+	// Debugf は見つけたファイルと対象外としたファイルを、理由とともに通知します。nil の場合は通知しません。
+	Debugf func(path, format string, args ...any)
+	// Warnf は処理できなかったフォルダ内の項目を通知します。nil の場合は通知しません。
+	Warnf func(path, format string, args ...any)
+}
+
+func (o Options) debugf(path, format string, args ...any) {
+	if o.Debugf != nil {
+		o.Debugf(path, format, args...)
+	}
+}
+
+func (o Options) warnf(path, format string, args ...any) {
+	if o.Warnf != nil {
+		o.Warnf(path, format, args...)
+	}
+}
+
+// excluded は path が Exclude のファイルかどうかを返します。
+func (o Options) excluded(path string) bool {
+	if len(o.Exclude) == 0 {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	return err == nil && slices.Contains(o.Exclude, abs)
+}
+
+// CSVFiles は指定されたパスからCSVファイルのリストを検索します。
+// root がファイルの場合は、拡張子が .csv であればそのファイルのみを返します。
+// ctx がキャンセルされると探索を中断します。
+func CSVFiles(ctx context.Context, root string, opts Options) ([]string, error) {
+	var files []string
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("could not stat path %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		if strings.HasSuffix(strings.ToLower(root), ".csv") {
+			return []string{root}, nil
+		}
+		return files, nil
+	}
+	var ignores *ignoreRules
+	if opts.UseIgnore {
+		ignores = newIgnoreRules(root, opts)
+	}
+	walkFunc := func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if ignores != nil && path != root {
+			if ignored, by := ignores.ignored(path, d.IsDir()); ignored {
+				opts.debugf(path, "Skipping %s: excluded by %s", path, by)
+				if d.IsDir() && opts.Recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if d.IsDir() {
+			if ignores != nil && opts.Recursive {
+				return ignores.enter(path)
+			}
+			return nil
+		}
+		if opts.excluded(path) {
+			opts.debugf(path, "Skipping %s: output file", path)
+		} else if strings.HasSuffix(strings.ToLower(d.Name()), ".csv") {
+			opts.debugf(path, "Found CSV file %s", path)
+			files = append(files, path)
+		} else {
+			opts.debugf(path, "Skipping %s: not a CSV file", path)
+		}
+		return nil
+	}
+	if opts.Recursive {
+		if err := filepath.WalkDir(root, walkFunc); err != nil {
+			return nil, fmt.Errorf("error walking directory %s: %w", root, err)
+		}
+	} else {
+		if ignores != nil {
+			if err := ignores.enter(root); err != nil {
+				return nil, err
+			}
+		}
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			return nil, fmt.Errorf("error reading directory %s: %w", root, err)
+		}
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := walkFunc(filepath.Join(root, entry.Name()), entry, nil); err != nil {
+				opts.warnf(filepath.Join(root, entry.Name()), "could not process entry %s: %v", entry.Name(), err)
+			}
+		}
+	}
+	return files, nil
+}
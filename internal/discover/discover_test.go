@@ -0,0 +1,147 @@
+package discover
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCSVFiles(t *testing.T) {
+	root := filepath.Join("testdata", "tree")
+	output, err := filepath.Abs(filepath.Join(root, "sub", "c.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		root string
+		opts Options
+		want []string
+	}{
+		{
+			name: "ファイルを指定した場合はそのファイルのみ",
+			root: filepath.Join(root, "a.csv"),
+			want: []string{"a.csv"},
+		},
+		{
+			name: "CSV以外のファイルを指定した場合は対象なし",
+			root: filepath.Join(root, "notes.txt"),
+			want: nil,
+		},
+		{
+			name: "サブフォルダは調べない",
+			root: root,
+			opts: Options{UseIgnore: true},
+			want: []string{"B.CSV", "a.csv"},
+		},
+		{
+			name: "除外ファイルを無視する",
+			root: root,
+			want: []string{"B.CSV", "a.csv", "temp.csv"},
+		},
+		{
+			name: "下の階層の除外ファイルが優先される",
+			root: root,
+			opts: Options{Recursive: true, UseIgnore: true},
+			want: []string{"B.CSV", "a.csv", "sub/c.csv", "sub/temp.csv"},
+		},
+		{
+			name: "出力先のファイルを除く",
+			root: root,
+			opts: Options{Recursive: true, UseIgnore: true, Exclude: []string{output}},
+			want: []string{"B.CSV", "a.csv", "sub/temp.csv"},
+		},
+		{
+			name: "サブフォルダも除外ファイルを無視して調べる",
+			root: root,
+			opts: Options{Recursive: true},
+			want: []string{"B.CSV", "a.csv", "old/x.csv", "sub/c.csv", "sub/draft.csv", "sub/temp.csv", "temp.csv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := CSVFiles(context.Background(), tt.root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range files {
+				rel, err := filepath.Rel(root, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rel == "." {
+					rel = filepath.Base(f)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CSVFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVFilesMissingRoot(t *testing.T) {
+	if _, err := CSVFiles(context.Background(), filepath.Join("testdata", "missing"), Options{}); err == nil {
+		t.Error("CSVFiles() with a missing root returned no error")
+	}
+}
+
+func TestCSVFilesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := CSVFiles(ctx, filepath.Join("testdata", "tree"), Options{Recursive: true}); err == nil {
+		t.Error("CSVFiles() with a canceled context returned no error")
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{pattern: "*.csv", rel: "a.csv", want: true},
+		{pattern: "*.csv", rel: "sub/a.csv", want: true},
+		{pattern: "a.csv", rel: "sub/b.csv", want: false},
+		{pattern: "/a.csv", rel: "a.csv", want: true},
+		{pattern: "/a.csv", rel: "sub/a.csv", want: false},
+		{pattern: "sub/*.csv", rel: "sub/a.csv", want: true},
+		{pattern: "sub/*.csv", rel: "sub/deep/a.csv", want: false},
+		{pattern: "systemA/**", rel: "systemA/2024/a.csv", want: true},
+		{pattern: "systemA/**", rel: "systemB/a.csv", want: false},
+		{pattern: "**/old/*.csv", rel: "x/y/old/a.csv", want: true},
+		{pattern: "systemA/", rel: "systemA", want: true},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestParseIgnorePattern(t *testing.T) {
+	tests := []struct {
+		line string
+		want ignorePattern
+		ok   bool
+	}{
+		{line: "", ok: false},
+		{line: "# コメント", ok: false},
+		{line: "/", ok: false},
+		{line: "temp.csv", want: ignorePattern{segments: []string{"**", "temp.csv"}}, ok: true},
+		{line: "old/", want: ignorePattern{segments: []string{"**", "old"}, dirOnly: true}, ok: true},
+		{line: "!keep.csv", want: ignorePattern{segments: []string{"**", "keep.csv"}, negate: true}, ok: true},
+		{line: `\#1.csv`, want: ignorePattern{segments: []string{"**", "#1.csv"}}, ok: true},
+		{line: "/data/*.csv \r", want: ignorePattern{segments: []string{"data", "*.csv"}}, ok: true},
+		{line: "\ufeffa.csv", want: ignorePattern{segments: []string{"**", "a.csv"}}, ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := parseIgnorePattern(tt.line)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseIgnorePattern(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package discover
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName は入力フォルダ内で処理から除外するファイルとフォルダを指定するファイルの名前です。
+// 書式は .gitignore と同じで、各行のパターンはそのファイルがあるフォルダからの相対パスと照合します。
+// 下の階層のファイルの指定は上の階層の指定より優先され、同じファイル内では後の行が優先されます。
+const IgnoreFileName = ".chiicgrepignore"
+
+// ignorePattern は除外ファイルの1行分のパターンです。
+type ignorePattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ignoreFile は1つのフォルダの除外ファイルです。
+type ignoreFile struct {
+	path     string
+	patterns []ignorePattern
+}
+
+// loadIgnoreFile は dir の除外ファイルを読み込みます。ファイルがない場合は nil を返します。
+func loadIgnoreFile(dir string) (*ignoreFile, error) {
+	name := filepath.Join(dir, IgnoreFileName)
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	defer f.Close()
+
+	ig := &ignoreFile{path: name}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if p, ok := parseIgnorePattern(sc.Text()); ok {
+			ig.patterns = append(ig.patterns, p)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return ig, nil
+}
+
+// parseIgnorePattern は除外ファイルの1行を解析します。空行とコメントの行の場合は false を返します。
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimPrefix(line, "\ufeff")
+	line = strings.TrimRight(line, " \t\r")
+	var p ignorePattern
+	switch {
+	case line == "", strings.HasPrefix(line, "#"):
+		return p, false
+	case strings.HasPrefix(line, "!"):
+		p.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\#`), strings.HasPrefix(line, `\!`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return p, false
+	}
+	// 途中に / を含まないパターンは、どの階層のファイル名とも照合する
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	p.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	return p, true
+}
+
+// match は除外ファイルのフォルダからの相対パス rel がパターンに一致するかを返します。
+func (p ignorePattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments はパスの要素 name がパターンの要素 pat に一致するかを返します。
+// ** は0個以上の階層に一致し、末尾の ** はその中のすべてに一致します。
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				return len(name) > 0
+			}
+			for i := range len(name) + 1 {
+				if matchSegments(pat, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pat[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// MatchPath はフォルダからの相対パス rel（区切りは /）が、除外ファイルと同じ書式のパターン pattern に一致するかを返します。
+// / を含まないパターンはどの階層のファイル名とも照合し、** は任意の階層に一致します。
+func MatchPath(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+// ignoreRules は入力フォルダ内の除外ファイルを、見つけたフォルダごとに保持します。
+type ignoreRules struct {
+	root  string
+	files map[string]*ignoreFile
+	opts  Options
+}
+
+// newIgnoreRules は root 以下の除外ファイルを扱う ignoreRules を作成します。
+func newIgnoreRules(root string, opts Options) *ignoreRules {
+	return &ignoreRules{root: filepath.Clean(root), files: make(map[string]*ignoreFile), opts: opts}
+}
+
+// enter はフォルダ dir の除外ファイルを読み込みます。dir の中を調べる前に呼び出します。
+func (ir *ignoreRules) enter(dir string) error {
+	ig, err := loadIgnoreFile(dir)
+	if err != nil {
+		return err
+	}
+	if ig != nil {
+		ir.opts.debugf(ig.path, "Loaded %d patterns from %s", len(ig.patterns), ig.path)
+		ir.files[filepath.Clean(dir)] = ig
+	}
+	return nil
+}
+
+// ignored は name が除外されるかと、除外を決めた除外ファイルを返します。
+// 親のフォルダから順に除外ファイルを調べ、最後に一致したパターンに従います。
+func (ir *ignoreRules) ignored(name string, isDir bool) (bool, string) {
+	var dirs []string
+	for dir := filepath.Dir(name); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == ir.root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	excluded, by := false, ""
+	for i := len(dirs) - 1; i >= 0; i-- {
+		ig := ir.files[dirs[i]]
+		if ig == nil {
+			continue
+		}
+		rel, err := filepath.Rel(dirs[i], name)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, p := range ig.patterns {
+			if p.match(rel, isDir) {
+				excluded, by = !p.negate, ig.path
+			}
+		}
+	}
+	return excluded, by
+}
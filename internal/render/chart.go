@@ -0,0 +1,88 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+// chartMaxSlices は円グラフに個別に描く値の最大数です。それ以降の値は "その他" にまとめます。
+const chartMaxSlices = 10
+
+// chartColors は円グラフの扇形に順に使う色です。
+var chartColors = []string{
+	"#0097a7", "#f57c00", "#7cb342", "#e53935", "#8e24aa",
+	"#fdd835", "#3949ab", "#d81b60", "#00897b", "#6d4c41",
+}
+
+// chartOthersColor は "その他" の扇形の色です。
+const chartOthersColor = "#bdbdbd"
+
+// Slice は円グラフの1つの扇形にする値の表示名と件数です。
+type Slice struct {
+	Label string
+	Count int
+}
+
+// PieChart は値ごとの件数 values と、values に含まれない値の件数 others を、凡例付きの円グラフとして
+// インラインSVGで返します。外部のスクリプトやフォントを読み込まないため、インターネットに接続できない環境でも表示できます。
+// values が chartMaxSlices 件を超える場合、超えた分は others に加えます。件数が1件もない場合は空文字列を返します。
+func PieChart(values []Slice, others int) string {
+	if len(values) > chartMaxSlices {
+		for _, v := range values[chartMaxSlices:] {
+			others += v.Count
+		}
+		values = values[:chartMaxSlices]
+	}
+	type slice struct {
+		label string
+		count int
+		color string
+	}
+	slices := make([]slice, 0, len(values)+1)
+	total := 0
+	for i, v := range values {
+		if v.Count > 0 {
+			slices = append(slices, slice{v.Label, v.Count, chartColors[i%len(chartColors)]})
+			total += v.Count
+		}
+	}
+	if others > 0 {
+		slices = append(slices, slice{"その他", others, chartOthersColor})
+		total += others
+	}
+	if total == 0 {
+		return ""
+	}
+
+	const r, cx, cy, legendX, lineHeight = 80.0, 90.0, 90.0, 190, 20
+	height := math.Max(2*cy, float64(len(slices)*lineHeight+10))
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg class=\"chart\" xmlns=\"http://www.w3.org/2000/svg\" width=\"480\" height=\"%.0f\" viewBox=\"0 0 480 %.0f\" role=\"img\">\n", height, height)
+	angle := -math.Pi / 2
+	for _, s := range slices {
+		title := fmt.Sprintf("<title>%s: %d件 (%.1f%%)</title>", html.EscapeString(s.label), s.count, float64(s.count)*100/float64(total))
+		if s.count == total {
+			fmt.Fprintf(&b, "<circle cx=\"%.0f\" cy=\"%.0f\" r=\"%.0f\" fill=\"%s\">%s</circle>\n", cx, cy, r, s.color, title)
+			continue
+		}
+		sweep := 2 * math.Pi * float64(s.count) / float64(total)
+		x1, y1 := cx+r*math.Cos(angle), cy+r*math.Sin(angle)
+		angle += sweep
+		x2, y2 := cx+r*math.Cos(angle), cy+r*math.Sin(angle)
+		large := 0
+		if sweep > math.Pi {
+			large = 1
+		}
+		fmt.Fprintf(&b, "<path d=\"M%.0f,%.0f L%.2f,%.2f A%.0f,%.0f 0 %d,1 %.2f,%.2f Z\" fill=\"%s\" stroke=\"#fff\">%s</path>\n",
+			cx, cy, x1, y1, r, r, large, x2, y2, s.color, title)
+	}
+	for i, s := range slices {
+		y := 10 + i*lineHeight
+		fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"12\" height=\"12\" fill=\"%s\"/>", legendX, y, s.color)
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" font-size=\"12\">%s (%d)</text>\n", legendX+18, y+11, html.EscapeString(s.label), s.count)
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
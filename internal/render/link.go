@@ -0,0 +1,47 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// linkPattern は値の中のURLとメールアドレスです。URLは全角文字や空白、引用符の手前までとします。
+var linkPattern = regexp.MustCompile(`https?://[!#-&(-;=?-~]+|[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+
+// linkTrailers はURLの末尾にあっても、文の区切りとみなしてリンクに含めない文字です。
+const linkTrailers = ".,;:!?)]}'"
+
+// Linkify は s をHTMLとしてエスケープし、URLとメールアドレスを <a> のリンクにして返します。
+// リンクは新しいタブで開き、開いたページから元のレポートを操作できないよう rel="noopener" を付けます。
+func Linkify(s string) string {
+	matches := linkPattern.FindAllStringIndex(s, -1)
+	if matches == nil {
+		return html.EscapeString(s)
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		text := s[start:end]
+		isURL := strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://")
+		if isURL {
+			trimmed := strings.TrimRight(text, linkTrailers)
+			// "(https://example.com/a_(b))" のような括弧を含むURLは、閉じ括弧を残す
+			for strings.Count(trimmed, "(") > strings.Count(trimmed, ")") && len(trimmed) < len(text) && text[len(trimmed)] == ')' {
+				trimmed += ")"
+			}
+			text = trimmed
+			end = start + len(text)
+		}
+		b.WriteString(html.EscapeString(s[last:start]))
+		href := text
+		if !isURL {
+			href = "mailto:" + text
+		}
+		b.WriteString(`<a href="` + html.EscapeString(href) + `" target="_blank" rel="noopener">` + html.EscapeString(text) + `</a>`)
+		last = end
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+	return b.String()
+}
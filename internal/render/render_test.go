@@ -0,0 +1,193 @@
+package render
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update は -update を指定してテストを実行した場合に、ゴールデンファイルを現在の出力で書き換えます。
+var update = flag.Bool("update", false, "update golden files")
+
+// checkGolden は got を testdata のゴールデンファイル name と比較します。
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run go test -update to regenerate)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestPieChart(t *testing.T) {
+	many := make([]Slice, 12)
+	for i := range many {
+		many[i] = Slice{Label: string(rune('A' + i)), Count: 12 - i}
+	}
+	tests := []struct {
+		name   string
+		values []Slice
+		others int
+		golden string
+	}{
+		{
+			name:   "値が1つだけの場合は円",
+			values: []Slice{{Label: "完了", Count: 3}},
+			golden: "piechart_single.golden.html",
+		},
+		{
+			name:   "扇形とその他",
+			values: []Slice{{Label: "完了", Count: 6}, {Label: "保留", Count: 3}, {Label: "<未定>", Count: 0}},
+			others: 1,
+			golden: "piechart_others.golden.html",
+		},
+		{
+			name:   "上限を超えた値はその他にまとめる",
+			values: many,
+			golden: "piechart_many.golden.html",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkGolden(t, tt.golden, PieChart(tt.values, tt.others))
+		})
+	}
+}
+
+func TestPieChartEmpty(t *testing.T) {
+	if got := PieChart([]Slice{{Label: "完了", Count: 0}}, 0); got != "" {
+		t.Errorf("PieChart() with no records = %q, want empty", got)
+	}
+}
+
+func TestLinkify(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "リンクなしはエスケープのみ",
+			in:   `<b>"重要"</b>`,
+			want: `&lt;b&gt;&#34;重要&#34;&lt;/b&gt;`,
+		},
+		{
+			name: "URL",
+			in:   "詳細は https://example.com/a?b=1&c=2 を参照",
+			want: `詳細は <a href="https://example.com/a?b=1&amp;c=2" target="_blank" rel="noopener">https://example.com/a?b=1&amp;c=2</a> を参照`,
+		},
+		{
+			name: "末尾の句読点はリンクに含めない",
+			in:   "https://example.com/.",
+			want: `<a href="https://example.com/" target="_blank" rel="noopener">https://example.com/</a>.`,
+		},
+		{
+			name: "括弧を含むURLは閉じ括弧を残す",
+			in:   "(https://example.com/a_(b))",
+			want: `(<a href="https://example.com/a_(b)" target="_blank" rel="noopener">https://example.com/a_(b)</a>)`,
+		},
+		{
+			name: "全角文字の手前までをURLとする",
+			in:   "https://example.com/x。次へ",
+			want: `<a href="https://example.com/x" target="_blank" rel="noopener">https://example.com/x</a>。次へ`,
+		},
+		{
+			name: "メールアドレス",
+			in:   "連絡先: taro.yamada@example.co.jp",
+			want: `連絡先: <a href="mailto:taro.yamada@example.co.jp" target="_blank" rel="noopener">taro.yamada@example.co.jp</a>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Linkify(tt.in); got != tt.want {
+				t.Errorf("Linkify(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name        string
+		v           string
+		max         int
+		wantPreview string
+		wantLength  int
+		wantOK      bool
+	}{
+		{name: "上限なし", v: "あいうえお", max: 0},
+		{name: "上限以内", v: "あいうえお", max: 5},
+		{name: "文字数で数える", v: "あいうえおか", max: 5, wantPreview: "あいうえお", wantLength: 6, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview, length, ok := Truncate(tt.v, tt.max)
+			if preview != tt.wantPreview || length != tt.wantLength || ok != tt.wantOK {
+				t.Errorf("Truncate(%q, %d) = %q, %d, %v, want %q, %d, %v", tt.v, tt.max, preview, length, ok, tt.wantPreview, tt.wantLength, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFontFamily(t *testing.T) {
+	tests := []struct {
+		font string
+		want string
+	}{
+		{font: "", want: "monospace"},
+		{font: "Meiryo UI", want: `"Meiryo UI", monospace`},
+		{font: `x"; } body { color: red`, want: `"x  body  color: red", monospace`},
+		{font: `"';{}<>\`, want: "monospace"},
+	}
+	for _, tt := range tests {
+		if got := FontFamily(tt.font); got != tt.want {
+			t.Errorf("FontFamily(%q) = %q, want %q", tt.font, got, tt.want)
+		}
+	}
+}
+
+// failingWriter は n バイトを書き込んだ後にエラーを返します。
+type failingWriter struct {
+	b strings.Builder
+	n int
+}
+
+var errWrite = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.b.Len()+len(p) > w.n {
+		return 0, errWrite
+	}
+	return w.b.Write(p)
+}
+
+func TestWriterKeepsFirstError(t *testing.T) {
+	fw := &failingWriter{n: 8}
+	w := Writer{W: fw}
+	w.WriteString("<table>")
+	w.Printf("<tr>%d</tr>", 1)
+	w.WriteString("x")
+	if !errors.Is(w.Err, errWrite) {
+		t.Fatalf("Err = %v, want %v", w.Err, errWrite)
+	}
+	if got := fw.b.String(); got != "<table>" {
+		t.Errorf("written = %q, want only the writes before the error", got)
+	}
+	w.W = &strings.Builder{}
+	w.WriteString("after")
+	if !errors.Is(w.Err, errWrite) {
+		t.Errorf("Err after replacing W = %v, want %v", w.Err, errWrite)
+	}
+}
@@ -0,0 +1,40 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Truncate は v が max 文字を超える場合に、先頭の max 文字と v の文字数を返します。
+// max が0以下の場合と、v が max 文字以内の場合は ok が false です。
+func Truncate(v string, max int) (preview string, length int, ok bool) {
+	if max <= 0 {
+		return "", 0, false
+	}
+	runes := []rune(v)
+	if len(runes) <= max {
+		return "", 0, false
+	}
+	return string(runes[:max]), len(runes), true
+}
+
+// FontFamily はフォント名をCSSの font-family 値に変換します。
+// スタイルシートを壊す文字は取り除かれます。
+func FontFamily(font string) string {
+	font = FontName(font)
+	if font == "" {
+		return "monospace"
+	}
+	return fmt.Sprintf("\"%s\", monospace", font)
+}
+
+// FontName はフォント名から、スタイルシートを壊す文字を取り除きます。
+func FontName(font string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '"', '\'', '\\', '<', '>', ';', '{', '}':
+			return -1
+		}
+		return r
+	}, font)
+}
@@ -0,0 +1,31 @@
+// Package render はレポートの出力に使う部品です。
+// 出力先への書き込み、値のエスケープとリンク、円グラフのSVG、フォント名の扱いなど、
+// レコードや集計の型に依存しない処理をまとめます。
+package render
+
+import (
+	"fmt"
+	"io"
+)
+
+// Writer は最初に発生した書き込みエラーを Err に保持し、以降の書き込みを行いません。
+// レコードごとに文字列を組み立てずに出力先へ直接書き込むために使用します。
+// 途中で W を差し替えても、保持したエラーは引き継がれます。
+type Writer struct {
+	W   io.Writer
+	Err error
+}
+
+// WriteString は str を書き込みます。
+func (s *Writer) WriteString(str string) {
+	if s.Err == nil {
+		_, s.Err = io.WriteString(s.W, str)
+	}
+}
+
+// Printf は format に従って書き込みます。
+func (s *Writer) Printf(format string, args ...any) {
+	if s.Err == nil {
+		_, s.Err = fmt.Fprintf(s.W, format, args...)
+	}
+}
@@ -0,0 +1,80 @@
+// Package scan はCSVデータの読み込みの共通処理です。
+// 抽出、集計、列の検索、結合用のファイルの読み込みなど、CSVを読むすべての処理で同じ解析オプションとヘッダーの扱いを使います。
+package scan
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+)
+
+// Options はCSVの解析オプションです。
+type Options struct {
+	// LazyQuotes は引用符の扱いを緩め、値の途中の引用符などをそのまま値として読み込みます。
+	LazyQuotes bool
+	// VariableFields は行ごとにフィールド数が異なることを許可します。
+	VariableFields bool
+}
+
+// NewReader は opts の解析オプションを反映した csv.Reader を作成します。
+func NewReader(rd io.Reader, opts Options) *csv.Reader {
+	reader := csv.NewReader(rd)
+	reader.LazyQuotes = opts.LazyQuotes
+	if opts.VariableFields {
+		reader.FieldsPerRecord = -1
+	}
+	return reader
+}
+
+// ReadHeader は reader の次の行をヘッダー行として読み込みます。
+// reader.ReuseRecord が true でも後の行で上書きされないよう、複製したものを返します。
+// clean が nil でない場合は、各列名に clean を適用します。入力が空の場合は io.EOF を返します。
+func ReadHeader(reader *csv.Reader, clean func(string) string) ([]string, error) {
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	headers = slices.Clone(headers)
+	if clean != nil {
+		for i, h := range headers {
+			headers[i] = clean(h)
+		}
+	}
+	return headers, nil
+}
+
+// HeaderIndex は列名から列の位置への対応を返します。
+// 同名のヘッダーが複数ある場合は最初の列を採用します。
+func HeaderIndex(headers []string) map[string]int {
+	index := make(map[string]int, len(headers))
+	for i, h := range headers {
+		if _, exists := index[h]; !exists {
+			index[h] = i
+		}
+	}
+	return index
+}
+
+// Pick は record のうち indices の位置の値を返します。位置が -1 の値と、record の範囲外の値は空文字列になります。
+// indices が空の場合は nil を返します。
+func Pick(record []string, indices []int) []string {
+	if len(indices) == 0 {
+		return nil
+	}
+	values := make([]string, len(indices))
+	for i, idx := range indices {
+		if idx >= 0 && idx < len(record) {
+			values[i] = record[idx]
+		}
+	}
+	return values
+}
+
+// Pad は record が n 列より短い場合に、不足する列を空文字列で補います。
+// フィールド数が可変の場合に、短い行でも列の位置で値を参照できるようにします。
+func Pad(record []string, n int) []string {
+	for len(record) < n {
+		record = append(record, "")
+	}
+	return record
+}
@@ -0,0 +1,217 @@
+package scan
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readFixture は testdata のCSVファイルを opts で読み込み、ヘッダーとデータ行を返します。
+func readFixture(t *testing.T, name string, opts Options, clean func(string) string) ([]string, [][]string, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	reader := NewReader(f, opts)
+	reader.ReuseRecord = true
+	headers, err := ReadHeader(reader, clean)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return headers, rows, nil
+		}
+		if err != nil {
+			return headers, rows, err
+		}
+		rows = append(rows, append([]string(nil), record...))
+	}
+}
+
+func TestReadFixtures(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		opts        Options
+		clean       func(string) string
+		wantHeaders []string
+		wantRows    [][]string
+		wantErr     error
+	}{
+		{
+			name:        "ヘッダーとデータ行",
+			file:        "basic.csv",
+			wantHeaders: []string{"氏名", "住所", "金額"},
+			wantRows:    [][]string{{"山田", "東京", "100"}, {"佐藤", "大阪", "250"}},
+		},
+		{
+			name:        "列名の空白を整える",
+			file:        "spaces.csv",
+			clean:       strings.TrimSpace,
+			wantHeaders: []string{"氏名", "住所", "金額"},
+			wantRows:    [][]string{{" 山田 ", "東京", "100"}},
+		},
+		{
+			name:        "空白を整えない場合は列名をそのまま使う",
+			file:        "spaces.csv",
+			wantHeaders: []string{" 氏名 ", "住所　 ", " 金額"},
+			wantRows:    [][]string{{" 山田 ", "東京", "100"}},
+		},
+		{
+			name:        "同名の列もそのまま読み込む",
+			file:        "duplicate_headers.csv",
+			wantHeaders: []string{"氏名", "備考", "氏名"},
+			wantRows:    [][]string{{"山田", "重要", "やまだ"}},
+		},
+		{
+			name:    "空のファイル",
+			file:    "empty.csv",
+			wantErr: io.EOF,
+		},
+		{
+			name:        "フィールド数が異なる行はエラー",
+			file:        "ragged.csv",
+			wantHeaders: []string{"氏名", "住所", "金額"},
+			wantErr:     csv.ErrFieldCount,
+		},
+		{
+			name:        "フィールド数が異なる行を許可する",
+			file:        "ragged.csv",
+			opts:        Options{VariableFields: true},
+			wantHeaders: []string{"氏名", "住所", "金額"},
+			wantRows:    [][]string{{"山田", "東京"}, {"佐藤", "大阪", "250"}},
+		},
+		{
+			name:        "値の途中の引用符はエラー",
+			file:        "lazy_quotes.csv",
+			wantHeaders: []string{"氏名", "備考"},
+			wantErr:     csv.ErrBareQuote,
+		},
+		{
+			name:        "値の途中の引用符を許可する",
+			file:        "lazy_quotes.csv",
+			opts:        Options{LazyQuotes: true},
+			wantHeaders: []string{"氏名", "備考"},
+			wantRows:    [][]string{{"山田", `10"インチ`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, rows, err := readFixture(t, tt.file, tt.opts, tt.clean)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(headers, tt.wantHeaders) {
+				t.Errorf("headers = %q, want %q", headers, tt.wantHeaders)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %q, want %q", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestReadHeaderKeepsHeadersWithReuseRecord(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "basic.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	reader := NewReader(f, Options{})
+	reader.ReuseRecord = true
+	headers, err := ReadHeader(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"氏名", "住所", "金額"}; !reflect.DeepEqual(headers, want) {
+		t.Errorf("headers after reading a row = %q, want %q", headers, want)
+	}
+}
+
+func TestHeaderIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    map[string]int
+	}{
+		{
+			name:    "列名から位置",
+			headers: []string{"氏名", "住所", "金額"},
+			want:    map[string]int{"氏名": 0, "住所": 1, "金額": 2},
+		},
+		{
+			name:    "同名の列は最初の列を採用する",
+			headers: []string{"氏名", "備考", "氏名"},
+			want:    map[string]int{"氏名": 0, "備考": 1},
+		},
+		{
+			name:    "ヘッダーなし",
+			headers: nil,
+			want:    map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HeaderIndex(tt.headers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HeaderIndex(%q) = %v, want %v", tt.headers, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPick(t *testing.T) {
+	record := []string{"山田", "東京", "100"}
+	tests := []struct {
+		name    string
+		indices []int
+		want    []string
+	}{
+		{name: "指定した順に並ぶ", indices: []int{2, 0}, want: []string{"100", "山田"}},
+		{name: "同じ位置を2回", indices: []int{1, 1}, want: []string{"東京", "東京"}},
+		{name: "-1 は空文字列", indices: []int{0, -1}, want: []string{"山田", ""}},
+		{name: "範囲外は空文字列", indices: []int{5}, want: []string{""}},
+		{name: "位置なし", indices: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Pick(record, tt.indices); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pick(%v) = %q, want %q", tt.indices, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPad(t *testing.T) {
+	tests := []struct {
+		name   string
+		record []string
+		n      int
+		want   []string
+	}{
+		{name: "不足する列を補う", record: []string{"山田"}, n: 3, want: []string{"山田", "", ""}},
+		{name: "足りている場合はそのまま", record: []string{"山田", "東京"}, n: 2, want: []string{"山田", "東京"}},
+		{name: "長い行は切り詰めない", record: []string{"山田", "東京", "100"}, n: 2, want: []string{"山田", "東京", "100"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Pad(tt.record, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pad(%q, %d) = %q, want %q", tt.record, tt.n, got, tt.want)
+			}
+		})
+	}
+}
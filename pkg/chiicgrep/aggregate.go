@@ -0,0 +1,207 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AggregateFunc は列の値に適用する集計関数です。
+type AggregateFunc string
+
+const (
+	// AggSum は数値の合計です。
+	AggSum AggregateFunc = "sum"
+	// AggAvg は数値の平均です。
+	AggAvg AggregateFunc = "avg"
+	// AggMin は数値の最小値です。
+	AggMin AggregateFunc = "min"
+	// AggMax は数値の最大値です。
+	AggMax AggregateFunc = "max"
+	// AggCount は数値として解釈できた値の件数です。
+	AggCount AggregateFunc = "count"
+)
+
+// Aggregate は出力したレコードについて列の数値を集計する指定です。
+type Aggregate struct {
+	Column string
+	Funcs  []AggregateFunc
+}
+
+// ParseAggregate は "列名:sum,avg,min,max" 形式の集計の指定を解析します。
+// 関数を省略した場合は sum を集計します。
+func ParseAggregate(s string) (Aggregate, error) {
+	column, funcs, found := strings.Cut(s, ":")
+	agg := Aggregate{Column: strings.TrimSpace(column)}
+	if agg.Column == "" {
+		return Aggregate{}, fmt.Errorf("invalid aggregate %q: missing column name", s)
+	}
+	if !found || strings.TrimSpace(funcs) == "" {
+		agg.Funcs = []AggregateFunc{AggSum}
+		return agg, nil
+	}
+	for _, name := range strings.Split(funcs, ",") {
+		f := AggregateFunc(strings.ToLower(strings.TrimSpace(name)))
+		switch f {
+		case AggSum, AggAvg, AggMin, AggMax, AggCount:
+			agg.Funcs = append(agg.Funcs, f)
+		default:
+			return Aggregate{}, fmt.Errorf("invalid aggregate %q: unknown function %q (expected sum, avg, min, max or count)", s, name)
+		}
+	}
+	return agg, nil
+}
+
+// String は ParseAggregate で解析できる形式で a を返します。
+func (a Aggregate) String() string {
+	funcs := make([]string, len(a.Funcs))
+	for i, f := range a.Funcs {
+		funcs[i] = string(f)
+	}
+	return a.Column + ":" + strings.Join(funcs, ",")
+}
+
+// AggregateValues は1つの列について集計した値です。
+type AggregateValues struct {
+	// Count は数値として解釈できた値の件数です。
+	Count int
+	Sum   float64
+	Min   float64
+	Max   float64
+	// Invalid は空でないが数値として解釈できなかった値の件数です。集計には含まれません。
+	Invalid int
+}
+
+// add は値 s を集計に加えます。空の値は無視します。
+func (v *AggregateValues) add(s string) {
+	if strings.TrimSpace(s) == "" {
+		return
+	}
+	f, ok := parseNumber(s)
+	if !ok {
+		v.Invalid++
+		return
+	}
+	if v.Count == 0 || f < v.Min {
+		v.Min = f
+	}
+	if v.Count == 0 || f > v.Max {
+		v.Max = f
+	}
+	v.Count++
+	v.Sum += f
+}
+
+// Merge は o の集計を v に加えます。
+func (v *AggregateValues) Merge(o AggregateValues) {
+	if o.Count > 0 {
+		if v.Count == 0 || o.Min < v.Min {
+			v.Min = o.Min
+		}
+		if v.Count == 0 || o.Max > v.Max {
+			v.Max = o.Max
+		}
+	}
+	v.Count += o.Count
+	v.Sum += o.Sum
+	v.Invalid += o.Invalid
+}
+
+// Value は関数 f の集計結果を返します。値が1件もない場合、sum と count 以外は false を返します。
+func (v AggregateValues) Value(f AggregateFunc) (float64, bool) {
+	switch f {
+	case AggSum:
+		return v.Sum, true
+	case AggCount:
+		return float64(v.Count), true
+	}
+	if v.Count == 0 {
+		return 0, false
+	}
+	switch f {
+	case AggAvg:
+		return v.Sum / float64(v.Count), true
+	case AggMin:
+		return v.Min, true
+	case AggMax:
+		return v.Max, true
+	}
+	return 0, false
+}
+
+// Format は関数 f の集計結果を小数第2位に丸めた文字列で返します。値がない場合は "-" を返します。
+func (v AggregateValues) Format(f AggregateFunc) string {
+	x, ok := v.Value(f)
+	if !ok {
+		return "-"
+	}
+	return strconv.FormatFloat(math.Round(x*100)/100, 'f', -1, 64)
+}
+
+// FileAggregate は1つのファイルの小計です。
+type FileAggregate struct {
+	File   string
+	Values AggregateValues
+}
+
+// AggregateResult は Config.Aggregates の1つの指定に対する集計結果です。
+type AggregateResult struct {
+	Aggregate
+	// Total は出力したすべてのレコードの集計です。
+	Total AggregateValues
+	// PerFile はファイルごとの小計です。空でない値があったファイルだけを入力の順序で含みます。
+	PerFile []FileAggregate
+}
+
+// aggregateState は1つの集計の途中経過です。
+type aggregateState struct {
+	total   AggregateValues
+	perFile map[string]*AggregateValues
+}
+
+// aggregate は Config.Aggregates が指定されている場合に、fn に渡すレコードの値を集計するよう fn を包みます。
+func (r *run) aggregate(fn func(Record) error) func(Record) error {
+	if len(r.cfg.Aggregates) == 0 {
+		return fn
+	}
+	r.aggregates = make([]aggregateState, len(r.cfg.Aggregates))
+	for i := range r.aggregates {
+		r.aggregates[i].perFile = make(map[string]*AggregateValues)
+	}
+	return func(rec Record) error {
+		r.mu.Lock()
+		for i, v := range rec.aggregateValues {
+			if strings.TrimSpace(v) == "" {
+				continue
+			}
+			st := &r.aggregates[i]
+			st.total.add(v)
+			fv, ok := st.perFile[rec.File]
+			if !ok {
+				fv = &AggregateValues{}
+				st.perFile[rec.File] = fv
+			}
+			fv.add(v)
+		}
+		r.mu.Unlock()
+		return fn(rec)
+	}
+}
+
+// aggregateResults は集計結果を返します。r.mu を保持した状態で呼び出します。
+func (r *run) aggregateResults() []AggregateResult {
+	if len(r.aggregates) == 0 {
+		return nil
+	}
+	results := make([]AggregateResult, len(r.aggregates))
+	for i, st := range r.aggregates {
+		results[i] = AggregateResult{Aggregate: r.cfg.Aggregates[i], Total: st.total}
+		for _, f := range r.files {
+			if fv, ok := st.perFile[f]; ok {
+				results[i].PerFile = append(results[i].PerFile, FileAggregate{File: f, Values: *fv})
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,110 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go-ChiiCgrep/internal/discover"
+)
+
+// ColumnMap はファイルごとの列名の読み替えです。システムごとに同じ列の名前が異なる場合（"emp_no" と "社員番号" など）に、
+// Pattern に一致するファイルの列名を Columns に従って読み替え、1つの列の指定ですべてのファイルを処理できるようにします。
+type ColumnMap struct {
+	// Pattern は入力のフォルダからの相対パスと照合するパターンです。書式は除外ファイル（IgnoreFileName）と同じで、
+	// / を含まないパターンはどの階層のファイル名とも照合します。
+	Pattern string
+	// Columns はファイルでの列名から、読み替えた列名への対応です。
+	Columns map[string]string
+}
+
+// ParseColumnMap は "パターン:列名=読み替える列名,..." 形式の読み替えを解析します。
+func ParseColumnMap(s string) (ColumnMap, error) {
+	pattern, pairs, found := strings.Cut(s, ":")
+	m := ColumnMap{Pattern: strings.TrimSpace(pattern), Columns: make(map[string]string)}
+	if !found || m.Pattern == "" {
+		return ColumnMap{}, fmt.Errorf("invalid column map %q: expected <pattern>:<column>=<name>[,...]", s)
+	}
+	for _, pair := range strings.Split(pairs, ",") {
+		from, to, found := strings.Cut(pair, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !found || from == "" || to == "" {
+			return ColumnMap{}, fmt.Errorf("invalid column map %q: expected <column>=<name>, got %q", s, pair)
+		}
+		if _, dup := m.Columns[from]; dup {
+			return ColumnMap{}, fmt.Errorf("invalid column map %q: column '%s' is mapped twice", s, from)
+		}
+		m.Columns[from] = to
+	}
+	return m, nil
+}
+
+// matchFile は入力 root の中のファイル name が Pattern に一致するかを返します。
+// root がファイルの場合や name が root の外にある場合は、ファイル名だけを照合します。
+func (m ColumnMap) matchFile(root, name string) bool {
+	rel, err := filepath.Rel(root, name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(name)
+	}
+	return discover.MatchPath(filepath.ToSlash(m.Pattern), filepath.ToSlash(rel))
+}
+
+// mapHeaders は cfg.ColumnMaps のうちファイル name に一致する読み替えを headers に適用します。
+// 同じ列に一致する読み替えが複数ある場合は、先に指定したものを使います。
+func (cfg Config) mapHeaders(name string, headers []string) {
+	if len(cfg.ColumnMaps) == 0 {
+		return
+	}
+	mapped := make([]bool, len(headers))
+	for _, m := range cfg.ColumnMaps {
+		if !m.matchFile(cfg.InputPath, name) {
+			continue
+		}
+		for i, h := range headers {
+			if to, ok := m.Columns[h]; ok && !mapped[i] {
+				debugf(name, "Column '%s' mapped to '%s' in %s", h, to, name)
+				headers[i] = to
+				mapped[i] = true
+			}
+		}
+	}
+}
+
+// ParseColumnAlias は "氏名=名前=NAME" 形式の列名の別名の組を解析します。
+func ParseColumnAlias(s string) ([]string, error) {
+	var names []string
+	for _, n := range strings.Split(s, "=") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			return nil, fmt.Errorf("invalid column alias %q: column names must not be empty", s)
+		}
+		names = append(names, n)
+	}
+	if len(names) < 2 {
+		return nil, fmt.Errorf("invalid column alias %q: expected <name>=<name>[=...]", s)
+	}
+	return names, nil
+}
+
+// addAliases は cfg.ColumnAliases の組ごとに、ファイルにある最初の列名の位置を、ファイルにない別名にも割り当てます。
+// これにより、列の指定や条件ではどの別名を使ってもファイルにある列を参照できます。
+func (cfg Config) addAliases(headerMap map[string]int, name string) {
+	for _, names := range cfg.ColumnAliases {
+		idx, found := -1, ""
+		for _, n := range names {
+			if i, ok := headerMap[n]; ok {
+				idx, found = i, n
+				break
+			}
+		}
+		if idx < 0 {
+			continue
+		}
+		for _, n := range names {
+			if _, ok := headerMap[n]; !ok {
+				debugf(name, "Column alias '%s' resolved to '%s' in %s", n, found, name)
+				headerMap[n] = idx
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// conditionOps は条件式で使用できる演算子です。
+// "<=" と "<" のように同じ位置で一致した場合に長い演算子が優先されるよう、長いものを先に並べています。
+var conditionOps = []string{"!=", "!~", "<=", ">=", "=", "~", "<", ">"}
+
+// Condition は1つの列の値に対する条件です。
+//
+//	列名=値    値が完全に一致する
+//	列名!=値   値が一致しない
+//	列名~値    値を含む
+//	列名!~値   値を含まない
+//	列名<値, 列名<=値, 列名>値, 列名>=値
+//	           両辺が数値なら数値として、日付なら日付として、それ以外は文字列として比較する
+//
+// 値を [列名] のように角括弧で囲むと、同じ行のその列の値と比較します（例: 予定終了日<[実績終了日]、請求額!=[入金額]）。
+// 角括弧で囲まない値は、ファイルに同じ名前の列があっても常に文字どおりの値として比較します。
+type Condition struct {
+	Column string
+	Op     string
+	// Value は比較する値です。ValueColumn の場合は比較する列の名前です。
+	Value       string
+	ValueColumn bool
+}
+
+// ParseCondition は "列名 演算子 値" 形式の文字列を解析します。
+func ParseCondition(s string) (Condition, error) {
+	pos, op := -1, ""
+	for _, candidate := range conditionOps {
+		if i := strings.Index(s, candidate); i > 0 && (pos < 0 || i < pos) {
+			pos, op = i, candidate
+		}
+	}
+	if pos < 0 {
+		return Condition{}, fmt.Errorf("invalid condition %q: expected <column><op><value> with op one of %s", s, strings.Join(conditionOps, " "))
+	}
+	c := Condition{
+		Column: strings.TrimSpace(s[:pos]),
+		Op:     op,
+		Value:  strings.TrimSpace(s[pos+len(op):]),
+	}
+	if len(c.Value) > 2 && strings.HasPrefix(c.Value, "[") && strings.HasSuffix(c.Value, "]") {
+		c.Value = strings.TrimSpace(c.Value[1 : len(c.Value)-1])
+		c.ValueColumn = true
+	}
+	return c, nil
+}
+
+// String は条件を ParseCondition で解析できる形式で返します。
+func (c Condition) String() string {
+	if c.ValueColumn {
+		return c.Column + c.Op + "[" + c.Value + "]"
+	}
+	return c.Column + c.Op + c.Value
+}
+
+// Match は値 v が条件を満たすかを判定します。
+func (c Condition) Match(v string) bool {
+	return c.compare(v, c.Value)
+}
+
+// compare は v と w が条件の演算子を満たすかを判定します。
+func (c Condition) compare(v, w string) bool {
+	switch c.Op {
+	case "=":
+		return v == w
+	case "!=":
+		return v != w
+	case "~":
+		return strings.Contains(v, w)
+	case "!~":
+		return !strings.Contains(v, w)
+	}
+	cmp := compareValues(v, w)
+	switch c.Op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// compareValues は a と b を比較します。両方が数値として解釈できる場合は数値として、
+// 両方が日付として解釈できる場合は日付として比較します（"2024/1/5" と "2024/01/10" など）。
+func compareValues(a, b string) int {
+	fa, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	fb, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	if ta, ok := parseDate(a); ok {
+		if tb, ok := parseDate(b); ok {
+			return ta.Compare(tb)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// ParseConditions は複数の条件式をまとめて解析します。
+func ParseConditions(exprs []string) ([]Condition, error) {
+	conds := make([]Condition, 0, len(exprs))
+	for _, e := range exprs {
+		c, err := ParseCondition(e)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
+
+// boundCondition は列名をファイルごとのインデックスに解決した Condition です。
+type boundCondition struct {
+	Condition
+	index int
+	// other は Condition.ValueColumn の場合の、比較する列のインデックスです。値と比較する場合は -1 です。
+	other int
+}
+
+// bindConditions は conds の列をヘッダー上のインデックスに解決します。
+// 列が見つからない条件は警告を出したうえで除外されます。kind は警告メッセージ用の条件の種類です。
+func bindConditions(conds []Condition, headerMap map[string]int, name, kind string) []boundCondition {
+	bound := make([]boundCondition, 0, len(conds))
+	for _, c := range conds {
+		idx, ok := headerMap[c.Column]
+		if !ok {
+			warnf(LogKindMissingColumn, name, "Column '%s' used in %s not found in %s", c.Column, kind, name)
+			continue
+		}
+		other := -1
+		if c.ValueColumn {
+			i, ok := headerMap[c.Value]
+			if !ok {
+				warnf(LogKindMissingColumn, name, "Column '%s' used in %s not found in %s", c.Value, kind, name)
+				continue
+			}
+			other = i
+			debugf(name, "%s %s compares column index %d with column index %d in %s", kind, c, idx, other, name)
+		} else {
+			debugf(name, "%s %s bound to column index %d in %s", kind, c, idx, name)
+		}
+		bound = append(bound, boundCondition{Condition: c, index: idx, other: other})
+	}
+	return bound
+}
+
+// match は行 record が条件を満たすかを判定します。
+func (b boundCondition) match(record []string) bool {
+	if b.index >= len(record) {
+		return false
+	}
+	if b.other < 0 {
+		return b.Match(record[b.index])
+	}
+	if b.other >= len(record) {
+		return false
+	}
+	return b.compare(record[b.index], record[b.other])
+}
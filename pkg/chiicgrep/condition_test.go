@@ -0,0 +1,45 @@
+package chiicgrep
+
+import "testing"
+
+func TestConditionColumnReference(t *testing.T) {
+	headerMap := map[string]int{"状態": 0, "完了": 1, "請求額": 2, "入金額": 3}
+	record := []string{"完了", "済", "100", "90"}
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		// 値と同じ名前の列があっても、角括弧で囲まない値は文字どおりに比較する
+		{"状態=完了", true},
+		{"状態=[完了]", false},
+		{"請求額!=[入金額]", true},
+		{"請求額>[入金額]", true},
+		{"請求額=[ 入金額 ]", false},
+		{"状態~[]", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			c, err := ParseCondition(tt.cond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bound := bindConditions([]Condition{c}, headerMap, "test.csv", "filter")
+			if len(bound) != 1 {
+				t.Fatalf("bindConditions(%s) bound %d conditions, want 1", c, len(bound))
+			}
+			if got := bound[0].match(record); got != tt.want {
+				t.Errorf("%s matched %v, want %v", c, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionMissingReferencedColumn(t *testing.T) {
+	c, err := ParseCondition("請求額!=[入金額]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound := bindConditions([]Condition{c}, map[string]int{"請求額": 0}, "test.csv", "filter"); len(bound) != 0 {
+		t.Errorf("bindConditions bound %d conditions, want 0 when the referenced column is missing", len(bound))
+	}
+}
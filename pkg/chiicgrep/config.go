@@ -0,0 +1,177 @@
+// Package chiicgrep はCSVファイルから条件に一致する行を抽出し、
+// 指定された列をレポートとして出力するためのライブラリです。
+package chiicgrep
+
+import (
+	"strings"
+	"time"
+)
+
+// Column は抽出対象の列を表します。
+// Name はCSVヘッダー上の列名、Label は出力時に表示する名前です。
+type Column struct {
+	Name  string
+	Label string
+}
+
+// Config は抽出処理の設定を保持します。
+type Config struct {
+	InputPath    string
+	Columns      []Column
+	SearchTarget string
+	Recursive    bool
+	// NoIgnore は入力フォルダ内の除外ファイル（IgnoreFileName）を無視し、すべてのCSVファイルを対象とします。
+	NoIgnore bool
+	// ExcludeFiles は入力から除くファイルの絶対パスです。出力先が入力フォルダ内にある場合に、
+	// 出力したファイルを次の実行で入力として読み込まないために指定します。
+	ExcludeFiles []string
+	// DedupFiles は内容が同じファイルを入力の順序で最初の1つだけ処理し、残りを省略します。
+	// 省略したファイルは処理したファイルのレコードの Record.DuplicateFiles に記録します。FileSource の場合だけ有効です。
+	DedupFiles bool
+	// ColumnMaps はファイルごとの列名の読み替えです。列の指定や条件は、読み替えた後の列名で照合します。
+	ColumnMaps []ColumnMap
+	// ColumnAliases は同じ列を表す列名の組です。組のいずれかの名前で、各ファイルにある列を参照できます。
+	// ファイルにない名前で参照した場合は、組のうちファイルにある最初の名前の列を使います。
+	ColumnAliases [][]string
+
+	// RequiredColumns はすべてのファイルに存在しなければならない列です。
+	// 欠けているファイルは Summary.SchemaViolations に記録されますが、処理は継続されます。
+	RequiredColumns []string
+
+	// Filters は行を絞り込む条件です。すべての条件を満たす行だけが一致します。
+	// 条件の列がないファイルは、警告を出して読み飛ばします。
+	Filters []Condition
+	// ValueLists は列の値をリストのファイルにある値で絞り込む指定です。列がないファイルは、警告を出して読み飛ばします。
+	ValueLists []ValueList
+	// Where は行を絞り込む条件式です。SearchTarget と両方を指定した場合は、両方を満たす行が一致します。
+	Where *WhereExpr
+
+	// HighlightRules は条件を満たした行の該当セルを強調表示する規則です。
+	HighlightRules []Condition
+
+	// TagRules はファイルにタグを付ける規則です。付いたタグは Record.Tags に設定されます。
+	TagRules []TagRule
+
+	// OnlyTags が指定されている場合、TagRules によりこれらのタグのいずれかが付くファイルだけを処理します。
+	// 対象外のファイルは開かれません。
+	OnlyTags []string
+
+	// RowTagRules は条件を満たしたレコードにタグを付ける規則です。付いたタグは Record.RowTags に設定されます。
+	RowTagRules []RowTagRule
+
+	// Sort が指定されている場合、一致したすべてのレコードをこのキーの順に並べ替えてから出力します。
+	// メモリ上には SortBuffer 件までのレコードを保持し、それを超えるレコードは一時ファイルに書き出して並べ替えます。
+	Sort []SortKey
+	// SortBuffer は Sort の際にメモリ上に保持するレコードの数です。0 の場合は DefaultSortBuffer です。
+	SortBuffer int
+	// TempDir は Sort の際に一時ファイルを作成するフォルダです。空の場合は OS の既定の一時フォルダを使います。
+	TempDir string
+
+	// Timeline が指定されている場合、レコードをこの列の日付の順に並べ替え、日付を Record.Date に設定します。
+	// Sort も指定されている場合、同じ日時のレコードは Sort の順に並びます。
+	Timeline string
+
+	// Context が正の場合、一致した行ごとに前後の Context 行も Record.Context を設定したレコードとして出力します。
+	// 前後の行が重なる場合、同じ行は1回だけ出力します。Sort または Timeline を指定した場合は無視されます。
+	Context int
+
+	// Dedup が true の場合、出力する列の値がすべて同じレコードは最初の1件だけを出力します。
+	// DedupBy が指定されている場合は、出力する列の代わりにこれらの列の値で重複を判定します。
+	Dedup   bool
+	DedupBy []string
+
+	// Aggregates は出力したレコードについて数値を集計する列です。結果は Summary.Aggregates に設定されます。
+	Aggregates []Aggregate
+	// TopValues は出力したレコードについて出現回数の多い値を数える列です。結果は Summary.TopValues に設定されます。
+	TopValues []TopValues
+	// Pivot が指定されている場合、出力したレコードをクロス集計します。結果は Summary.Pivot に設定されます。
+	Pivot *Pivot
+	// Validations は出力したレコードの値を検証するルールです。違反した値は Field.Violations に、
+	// ルールごとの違反の数は Summary.Validations に設定されます。
+	Validations []ValidationRule
+	// UniqueKey は実行全体で値が重複してはならない列です。複数の列の場合は値の組み合わせで判定します。
+	// 重複したレコードには Record.DuplicateOf が、重複した値の一覧は Summary.KeyConflicts に設定されます。
+	UniqueKey []string
+
+	// Join が指定されている場合、参照用のファイルからキー列の値が一致する行の列を各行に加えます。
+	// 加えた列は入力ファイルの列と同様に、抽出、検索、強調表示などに使えます。
+	Join *Join
+
+	// Hooks は1行ごとの処理に割り込むフックです。組み込みの Replacements の後に、指定した順に呼び出されます。
+	Hooks []RecordHook
+
+	// Replacements は照合と出力の前に、列の値に適用する正規表現の置換です。指定した順に適用されます。
+	Replacements []Replacement
+
+	// ValueMaps はコード値を表示用の名前に置き換える列です。照合には元のコードを使い、出力する値と集計には
+	// 置き換えた名前を使います。対応表にないコードはそのまま出力します。元のコードは Field.Code に設定されます。
+	ValueMaps []ValueMap
+
+	// Masks はレポートに書き込む前に値を伏せる列です。検索、強調表示、タグ付けの照合には元の値を使い、
+	// 出力する値と、並べ替え、重複の判定、集計には伏せた値を使います。
+	Masks []Mask
+	// MaskKey が空でない場合、MaskHash はこの値を鍵とするHMAC-SHA256でハッシュ値を求めます。
+	// 鍵を知らない人が、よくある値のハッシュ値と突き合わせて元の値を推測することを防ぎます。
+	MaskKey string
+
+	// MaxResults は出力するレコード数の上限です。0の場合は無制限です。
+	MaxResults int
+	// MaxPerFile はファイルごとに出力するレコード数の上限です。0の場合は無制限です。
+	MaxPerFile int
+	// Sample が設定されている場合は、一致したレコードのうちファイルごとに一部だけを出力します。
+	Sample *Sample
+
+	// Geo が指定されている場合、一致したレコードの緯度と経度を Record.Location に設定し、HTMLレポートに地図を出力します。
+	Geo *GeoColumns
+	// Gantt が指定されている場合、一致したレコードの期間を Record.Span に設定し、HTMLレポートに工程表を出力します。
+	Gantt *GanttColumns
+
+	// TrimCells はヘッダーと値の前後の空白（全角スペースを含む）を、照合と出力の前に取り除きます。
+	TrimCells bool
+	// CollapseSpaces は値の途中に連続する空白を1つの半角スペースにまとめます。TrimCells も適用されます。
+	CollapseSpaces bool
+
+	// Normalize は検索文字列や強調表示規則と照合する前に、両辺の値へ適用するUnicode正規化です。
+	Normalize Normalization
+
+	// LazyQuotes は引用符の扱いを緩め、フィールド途中の " や閉じられていない引用符を許容します（csv.Reader.LazyQuotes）。
+	LazyQuotes bool
+	// AllowVariableFields は行ごとにフィールド数が異なるCSVを許容します。
+	// ヘッダーより短い行は、不足する列を空文字列として扱います。
+	AllowVariableFields bool
+
+	// Strict が true の場合、いずれかのファイルで読み込みエラー（CSVの解析エラーを含む）が発生した時点で
+	// 処理を中止し、ErrStrict を返します。false の場合はエラーを記録して次のファイルへ進みます。
+	Strict bool
+
+	// Retry はほかのプロセス（Excelなど）がロックしているファイルを開けなかった場合に、開き直す回数です。
+	// 0 の場合は開き直さず、ロックされたファイルは読み込みエラーとして記録します。
+	Retry int
+	// RetryWait は最初に開き直すまでの待ち時間です。開き直すたびに倍にします。0 以下の場合は1秒です。
+	RetryWait time.Duration
+
+	// Jobs は並行して処理するファイル数の上限です。1以下の場合は1ファイルずつ順に処理します。
+	Jobs int
+
+	// OnProgress が設定されている場合、処理の進捗が定期的に通知されます。
+	// 並行処理中も同時に複数呼び出されることはありません。
+	OnProgress func(Progress)
+
+	// Source は入力の取得元です。nil の場合は InputPath と Recursive から FileSource を使用します。
+	Source Source
+}
+
+// ParseColumns は -cols 形式の文字列を解析します。
+// 各要素は "列名" または "列名:表示名" の形式で、指定順と重複はそのまま保持されます。
+func ParseColumns(s string) []Column {
+	parts := strings.Split(s, ",")
+	columns := make([]Column, 0, len(parts))
+	for _, p := range parts {
+		name, label, found := strings.Cut(p, ":")
+		if !found || label == "" {
+			label = name
+		}
+		columns = append(columns, Column{Name: name, Label: label})
+	}
+	return columns
+}
@@ -0,0 +1,42 @@
+package chiicgrep
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Column
+	}{
+		{
+			name: "指定した順序を保つ",
+			in:   "住所,氏名,備考",
+			want: []Column{{Name: "住所", Label: "住所"}, {Name: "氏名", Label: "氏名"}, {Name: "備考", Label: "備考"}},
+		},
+		{
+			name: "表示名を指定する",
+			in:   "氏名:名前,住所",
+			want: []Column{{Name: "氏名", Label: "名前"}, {Name: "住所", Label: "住所"}},
+		},
+		{
+			name: "同じ列を異なる表示名で2回指定する",
+			in:   "金額:税抜,備考,金額:税込",
+			want: []Column{{Name: "金額", Label: "税抜"}, {Name: "備考", Label: "備考"}, {Name: "金額", Label: "税込"}},
+		},
+		{
+			name: "表示名が空の場合は列名を使う",
+			in:   "氏名:,住所",
+			want: []Column{{Name: "氏名", Label: "氏名"}, {Name: "住所", Label: "住所"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseColumns(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseColumns(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
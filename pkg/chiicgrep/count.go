@@ -0,0 +1,50 @@
+package chiicgrep
+
+import (
+	"context"
+	"sync"
+)
+
+// FileCount は1ファイル分の一致件数です。
+type FileCount struct {
+	File    string
+	Matches int
+	// Err はファイルの読み込みに失敗した場合のエラーです。Matches はそれまでの件数です。
+	Err error
+	// MissingColumns は Config.RequiredColumns のうち、このファイルに存在しなかった列です。
+	MissingColumns []string
+}
+
+// CountMatches は cfg の入力に含まれる各ファイルについて、条件に一致した行数を数えます。
+// 結果は一致件数が0のファイルも含め、入力の順序で返されます。レコードは組み立てられますが保持されません。
+func CountMatches(ctx context.Context, cfg Config) ([]FileCount, error) {
+	r, err := newRun(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	counts := make(map[string]int, len(r.files))
+	err = r.processFiles(ctx, func(rec Record) error {
+		if rec.Context {
+			return nil
+		}
+		mu.Lock()
+		counts[rec.File]++
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make(map[string]error, len(r.fileErrors))
+	for _, fe := range r.fileErrors {
+		errs[fe.File] = fe.Err
+	}
+	result := make([]FileCount, len(r.files))
+	for i, f := range r.files {
+		result[i] = FileCount{File: f, Matches: counts[f], Err: errs[f], MissingColumns: r.missingColumns[f]}
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// DashboardPanel はダッシュボード形式のHTMLレポートに並べるパネルの種類です。
+type DashboardPanel string
+
+const (
+	// PanelSummary は処理したファイルや一致した行の数などの集計です。
+	PanelSummary DashboardPanel = "summary"
+	// PanelRecords は一致したレコードの一覧です。
+	PanelRecords DashboardPanel = "records"
+	// PanelTop は Config.TopValues の頻出値の表と円グラフです。
+	PanelTop DashboardPanel = "top"
+	// PanelAggregates は Config.Aggregates の集計値です。
+	PanelAggregates DashboardPanel = "aggregates"
+	// PanelPivot は Config.Pivot のクロス集計です。
+	PanelPivot DashboardPanel = "pivot"
+	// PanelLegend はタグの凡例です。
+	PanelLegend DashboardPanel = "legend"
+	// PanelMap は Config.Geo の地図です。
+	PanelMap DashboardPanel = "map"
+	// PanelGantt は Config.Gantt の工程表です。
+	PanelGantt DashboardPanel = "gantt"
+	// PanelValidation は Config.Validations の違反の一覧です。
+	PanelValidation DashboardPanel = "validation"
+	// PanelKeyConflicts は Config.UniqueKey の重複の一覧です。
+	PanelKeyConflicts DashboardPanel = "unique-key"
+)
+
+// dashboardPanelTitles はパネルの種類ごとの見出しです。並びは ParseDashboard のエラーメッセージに使います。
+var dashboardPanelTitles = []struct {
+	panel DashboardPanel
+	title string
+}{
+	{PanelSummary, "集計"},
+	{PanelRecords, "レコード"},
+	{PanelTop, "頻出値"},
+	{PanelAggregates, "集計値"},
+	{PanelPivot, "クロス集計"},
+	{PanelLegend, "タグ"},
+	{PanelMap, "地図"},
+	{PanelGantt, "工程表"},
+	{PanelValidation, "検証"},
+	{PanelKeyConflicts, "キーの重複"},
+}
+
+// ParseDashboard はカンマ区切りのパネルの並びを解析します。
+func ParseDashboard(s string) ([]DashboardPanel, error) {
+	var panels []DashboardPanel
+	for _, part := range strings.Split(s, ",") {
+		p := DashboardPanel(strings.ToLower(strings.TrimSpace(part)))
+		if p.title() == "" {
+			names := make([]string, len(dashboardPanelTitles))
+			for i, t := range dashboardPanelTitles {
+				names[i] = string(t.panel)
+			}
+			return nil, fmt.Errorf("unknown dashboard panel %q (expected %s)", part, strings.Join(names, ", "))
+		}
+		if slices.Contains(panels, p) {
+			return nil, fmt.Errorf("dashboard panel %q is listed twice", p)
+		}
+		panels = append(panels, p)
+	}
+	return panels, nil
+}
+
+// title はパネルの見出しを返します。不明なパネルの場合は空文字列を返します。
+func (p DashboardPanel) title() string {
+	for _, t := range dashboardPanelTitles {
+		if t.panel == p {
+			return t.title
+		}
+	}
+	return ""
+}
+
+// writeDashboard は HTMLOptions.Dashboard のパネルを、指定された順に格子状に並べて出力します。
+// レコードの一覧は Render の間に r.records に書き込んだものを、records のパネルに出力します。
+func (r *HTMLRenderer) writeDashboard(sw *render.Writer, sum Summary) {
+	sw.WriteString("<div class=\"dashboard\">\n")
+	for _, p := range r.opts.Dashboard {
+		sw.Printf("<section class=\"panel panel-%s\">\n<h2>%s</h2>\n", p, p.title())
+		empty := false
+		switch p {
+		case PanelSummary:
+			writeSummaryTable(sw, sum)
+		case PanelRecords:
+			empty = r.records.Len() == 0
+			sw.WriteString(r.records.String())
+		case PanelTop:
+			empty = len(sum.TopValues) == 0
+			for _, top := range sum.TopValues {
+				writeTopValuesTable(sw, top)
+			}
+		case PanelAggregates:
+			empty = len(sum.Aggregates) == 0
+			for _, agg := range sum.Aggregates {
+				writeAggregateTable(sw, agg)
+			}
+		case PanelPivot:
+			empty = sum.Pivot == nil
+			if sum.Pivot != nil {
+				writePivotTable(sw, sum.Pivot)
+			}
+		case PanelLegend:
+			empty = len(r.legend.counts) == 0
+			r.legend.write(sw)
+		case PanelMap:
+			empty = sum.Geo == nil
+			r.geo.write(sw, sum)
+		case PanelGantt:
+			empty = sum.Gantt == nil
+			r.gantt.write(sw, sum)
+		case PanelValidation:
+			empty = len(sum.Validations) == 0
+			writeValidationTable(sw, sum.Validations)
+		case PanelKeyConflicts:
+			empty = len(sum.KeyConflicts) == 0
+			writeKeyConflictTable(sw, sum.UniqueKey, sum.KeyConflicts)
+		}
+		if empty {
+			sw.WriteString("<div class=\"chart-note\">表示するデータはありません。</div>\n")
+		}
+		sw.WriteString("</section>\n")
+	}
+	sw.WriteString("</div>\n")
+}
@@ -0,0 +1,68 @@
+package chiicgrep
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+)
+
+// dedupeFiles は内容が同じファイルのうち入力の順序で最初の1つだけを残し、残したファイルと、
+// 残したファイルごとの省略したファイルを返します。
+// 大きさが同じファイルの間でだけ内容を比較するため、大きさの異なるファイルは読み込みません。
+// 大きさや内容を読み込めないファイルは省略せずに残し、処理の際に読み込みエラーとします。
+func dedupeFiles(ctx context.Context, files []string) ([]string, map[string][]string, error) {
+	sizes := make(map[int64]int, len(files))
+	fileSizes := make([]int64, len(files))
+	for i, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			fileSizes[i] = -1
+			continue
+		}
+		fileSizes[i] = info.Size()
+		sizes[info.Size()]++
+	}
+
+	kept := make([]string, 0, len(files))
+	duplicates := make(map[string][]string)
+	firstByHash := make(map[[sha256.Size]byte]string)
+	for i, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if fileSizes[i] < 0 || sizes[fileSizes[i]] < 2 {
+			kept = append(kept, f)
+			continue
+		}
+		key, err := hashFile(f)
+		if err != nil {
+			kept = append(kept, f)
+			continue
+		}
+		if first, ok := firstByHash[key]; ok {
+			debugf(f, "Skipping %s: same content as %s", f, first)
+			duplicates[first] = append(duplicates[first], f)
+			continue
+		}
+		firstByHash[key] = f
+		kept = append(kept, f)
+	}
+	return kept, duplicates, nil
+}
+
+// hashFile はファイルの内容のSHA-256のハッシュ値を返します。
+func hashFile(name string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(name)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,151 @@
+package chiicgrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// DiffRow は比較した入力の1行です。Values は DiffResult.Columns と同じ順序で並びます。
+type DiffRow struct {
+	Key    string
+	File   string
+	Line   int
+	Values []string
+}
+
+// DiffChange は両方の入力にあり、比較する列の値が異なった行です。
+type DiffChange struct {
+	Old DiffRow
+	New DiffRow
+	// Changed は DiffResult.Columns のうち値が異なった列を示します。
+	Changed []bool
+}
+
+// DiffResult は2つの入力をキー列で対応付けて比較した結果です。
+type DiffResult struct {
+	Key     string
+	Columns []Column
+	// Added は新しい入力にだけある行です。新しい入力の順序で並びます。
+	Added []DiffRow
+	// Removed は古い入力にだけある行です。古い入力の順序で並びます。
+	Removed []DiffRow
+	// Changed は値が異なった行です。新しい入力の順序で並びます。
+	Changed []DiffChange
+	// Unchanged は値が同じだった行の数です。
+	Unchanged int
+}
+
+// HasDifferences は追加、削除、変更された行が1つでもあるかを返します。
+func (d DiffResult) HasDifferences() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+// Diff は oldCfg と newCfg の入力の行を列 key の値で対応付け、追加、削除、変更された行を求めます。
+// 比較するのは oldCfg.Columns の列で、空の場合はいずれかの入力にあるキー以外のすべての列です。
+// newCfg.Columns は無視されます。キーが同じ行が複数ある場合は最初の行を使い、警告を出します。
+func Diff(ctx context.Context, oldCfg, newCfg Config, key string) (DiffResult, error) {
+	columns := oldCfg.Columns
+	if len(columns) == 0 {
+		var headers []FileHeaders
+		for _, cfg := range []Config{oldCfg, newCfg} {
+			h, err := ReadHeaders(ctx, cfg)
+			if err != nil {
+				return DiffResult{}, diffInputError(cfg, err)
+			}
+			headers = append(headers, h...)
+		}
+		for _, h := range UniqueHeaders(headers) {
+			if h != key {
+				columns = append(columns, Column{Name: h, Label: h})
+			}
+		}
+	}
+	res := DiffResult{Key: key, Columns: columns}
+
+	oldRows, err := collectDiffRows(ctx, oldCfg, key, columns)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	newRows, err := collectDiffRows(ctx, newCfg, key, columns)
+	if err != nil {
+		return DiffResult{}, err
+	}
+
+	oldByKey := make(map[string]DiffRow, len(oldRows))
+	for _, row := range oldRows {
+		oldByKey[row.Key] = row
+	}
+	seen := make(map[string]bool, len(newRows))
+	for _, row := range newRows {
+		seen[row.Key] = true
+		old, ok := oldByKey[row.Key]
+		if !ok {
+			res.Added = append(res.Added, row)
+			continue
+		}
+		changed := make([]bool, len(columns))
+		different := false
+		for i := range columns {
+			if old.Values[i] != row.Values[i] {
+				changed[i] = true
+				different = true
+			}
+		}
+		if different {
+			res.Changed = append(res.Changed, DiffChange{Old: old, New: row, Changed: changed})
+		} else {
+			res.Unchanged++
+		}
+	}
+	for _, row := range oldRows {
+		if !seen[row.Key] {
+			res.Removed = append(res.Removed, row)
+		}
+	}
+	return res, nil
+}
+
+// collectDiffRows は cfg の入力から一致した行を読み込み、キー列の値が重複しない行を入力の順序で返します。
+// キー列がないファイルの行は含まれません。
+func collectDiffRows(ctx context.Context, cfg Config, key string, columns []Column) ([]DiffRow, error) {
+	cfg.Columns = append([]Column{{Name: key, Label: key}}, columns...)
+	var rows []DiffRow
+	seen := make(map[string]bool)
+	duplicates := 0
+	err := Process(ctx, cfg, func(rec Record) error {
+		keyIdx := slices.IndexFunc(rec.Fields, func(f Field) bool { return f.Column.Name == key })
+		if keyIdx < 0 {
+			return nil
+		}
+		row := DiffRow{Key: rec.Fields[keyIdx].Value, File: rec.File, Line: rec.Line, Values: make([]string, len(columns))}
+		if seen[row.Key] {
+			duplicates++
+			return nil
+		}
+		seen[row.Key] = true
+		for _, f := range rec.Fields {
+			if i := slices.IndexFunc(columns, func(c Column) bool { return c.Name == f.Column.Name }); i >= 0 {
+				row.Values[i] = f.Value
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, diffInputError(cfg, err)
+	}
+	if duplicates > 0 {
+		warnf(LogKindDuplicateKey, cfg.InputPath, "%d rows with duplicate keys in %s were ignored", duplicates, cfg.InputPath)
+	}
+	return rows, nil
+}
+
+// diffInputError は入力に CSV ファイルがなかった場合に、どちらの入力かわかるよう err にパスを加えます。
+func diffInputError(cfg Config, err error) error {
+	if errors.Is(err, ErrNoCSVFiles) {
+		return fmt.Errorf("%s: %w", cfg.InputPath, err)
+	}
+	return err
+}
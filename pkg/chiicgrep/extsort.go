@@ -0,0 +1,301 @@
+package chiicgrep
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// DefaultSortBuffer は Config.SortBuffer が 0 の場合に、並べ替えの際にメモリに保持するレコードの数です。
+const DefaultSortBuffer = 500000
+
+// recordSorter は Config.Sort の順にレコードを並べ替えます。
+// 保持するレコードが limit 件に達するたびに、並べ替えて一時ファイルに書き出し、最後にすべてを併合するため、
+// レコードの数によらずメモリの使用量は limit 件分に収まります。
+type recordSorter struct {
+	keys      []SortKey
+	limit     int
+	dir       string
+	records   []Record
+	detectors []sortTypeDetector
+	chunks    []*sortChunk
+}
+
+// sortChunk は並べ替えて一時ファイルに書き出したレコードです。
+type sortChunk struct {
+	file  *os.File
+	count int
+	// types は書き出す際に並べ替えに使った比較方法です。
+	types []SortType
+}
+
+// spilledRecord は一時ファイルに書き出す Record です。gob で読み書きできるよう、Record のすべての項目を公開しています。
+type spilledRecord struct {
+	File            string
+	Line            int
+	Fields          []Field
+	Highlighted     bool
+	Tags            []string
+	RowTags         []string
+	DuplicateFiles  []string
+	Context         bool
+	New             bool
+	Date            string
+	Location        *GeoPoint
+	Span            *GanttSpan
+	Invalid         bool
+	DuplicateOf     *KeyLocation
+	Violations      []recordViolation
+	SortValues      []string
+	DedupKey        string
+	UniqueKey       string
+	AggregateValues []string
+	TopValues       []string
+	PivotValues     []string
+}
+
+// newRecordSorter は keys の順に並べ替える recordSorter を作成します。一時ファイルは dir（空の場合は既定の一時フォルダ）に作成します。
+func newRecordSorter(keys []SortKey, limit int, dir string) *recordSorter {
+	if limit <= 0 {
+		limit = DefaultSortBuffer
+	}
+	s := &recordSorter{keys: keys, limit: limit, dir: dir, detectors: make([]sortTypeDetector, len(keys))}
+	for i := range s.detectors {
+		s.detectors[i] = newSortTypeDetector()
+	}
+	return s
+}
+
+// add はレコードを加えます。
+func (s *recordSorter) add(rec Record) error {
+	for i, v := range rec.sortValues {
+		s.detectors[i].add(v)
+	}
+	s.records = append(s.records, rec)
+	if len(s.records) >= s.limit {
+		return s.spill()
+	}
+	return nil
+}
+
+// spill は保持しているレコードを並べ替えて一時ファイルに書き出します。
+// SortAuto のキーの比較方法は後のレコードで変わることがあるため、使った比較方法を記録しておきます。
+func (s *recordSorter) spill() error {
+	types := sortTypes(s.keys, s.detectors)
+	sortRecordsBy(s.records, s.keys, types)
+	f, err := os.CreateTemp(s.dir, "chiicgrep-sort-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for sorting: %w", err)
+	}
+	c := &sortChunk{file: f, types: types}
+	s.chunks = append(s.chunks, c)
+	if err := c.write(s.records); err != nil {
+		return err
+	}
+	debugf(f.Name(), "Wrote %d sorted records to %s", c.count, f.Name())
+	clear(s.records)
+	s.records = s.records[:0]
+	return nil
+}
+
+// write は records を一時ファイルの先頭から書き込みます。
+func (c *sortChunk) write(records []Record) error {
+	if _, err := c.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to write temporary file for sorting: %w", err)
+	}
+	if err := c.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write temporary file for sorting: %w", err)
+	}
+	w := bufio.NewWriter(c.file)
+	enc := gob.NewEncoder(w)
+	for _, rec := range records {
+		sr := spilledRecord{
+			File: rec.File, Line: rec.Line, Fields: rec.Fields, Highlighted: rec.Highlighted,
+			Tags: rec.Tags, RowTags: rec.RowTags, DuplicateFiles: rec.DuplicateFiles,
+			Context: rec.Context, New: rec.New, Date: rec.Date, Location: rec.Location, Span: rec.Span,
+			Invalid: rec.Invalid, DuplicateOf: rec.DuplicateOf, Violations: rec.violations, SortValues: rec.sortValues, DedupKey: rec.dedupKey, UniqueKey: rec.uniqueKey, AggregateValues: rec.aggregateValues,
+			TopValues: rec.topValues, PivotValues: rec.pivotValues,
+		}
+		if err := enc.Encode(&sr); err != nil {
+			return fmt.Errorf("failed to write temporary file for sorting: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write temporary file for sorting: %w", err)
+	}
+	c.count = len(records)
+	return nil
+}
+
+// reader は一時ファイルのレコードを先頭から1件ずつ返す関数を作成します。レコードがなくなると io.EOF を返します。
+func (c *sortChunk) reader() (func() (Record, error), error) {
+	if _, err := c.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read temporary file for sorting: %w", err)
+	}
+	dec := gob.NewDecoder(bufio.NewReader(c.file))
+	remaining := c.count
+	return func() (Record, error) {
+		if remaining == 0 {
+			return Record{}, io.EOF
+		}
+		var sr spilledRecord
+		if err := dec.Decode(&sr); err != nil {
+			return Record{}, fmt.Errorf("failed to read temporary file for sorting: %w", err)
+		}
+		remaining--
+		return Record{
+			File: sr.File, Line: sr.Line, Fields: sr.Fields, Highlighted: sr.Highlighted,
+			Tags: sr.Tags, RowTags: sr.RowTags, DuplicateFiles: sr.DuplicateFiles,
+			Context: sr.Context, New: sr.New, Date: sr.Date, Location: sr.Location, Span: sr.Span,
+			Invalid: sr.Invalid, DuplicateOf: sr.DuplicateOf, violations: sr.Violations, sortValues: sr.SortValues, dedupKey: sr.DedupKey, uniqueKey: sr.UniqueKey, aggregateValues: sr.AggregateValues,
+			topValues: sr.TopValues, pivotValues: sr.PivotValues,
+		}, nil
+	}, nil
+}
+
+// resort は一時ファイルのレコードを比較方法 types で並べ替え直します。
+func (c *sortChunk) resort(keys []SortKey, types []SortType) error {
+	next, err := c.reader()
+	if err != nil {
+		return err
+	}
+	records := make([]Record, 0, c.count)
+	for {
+		rec, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		records = append(records, rec)
+	}
+	sortRecordsBy(records, keys, types)
+	if err := c.write(records); err != nil {
+		return err
+	}
+	c.types = types
+	return nil
+}
+
+// each は加えたすべてのレコードを並べ替えた順に fn に渡します。
+// 同じ順位のレコードは加えた順に渡すため、sortRecords と同じ結果になります。
+func (s *recordSorter) each(fn func(Record) error) error {
+	types := sortTypes(s.keys, s.detectors)
+	sortRecordsBy(s.records, s.keys, types)
+	if len(s.chunks) == 0 {
+		for _, rec := range s.records {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 一時ファイルを先に加えた順に並べ、最後にメモリ上のレコードを加える
+	m := &sortMerge{keys: s.keys, types: types}
+	for _, c := range s.chunks {
+		if !slices.Equal(c.types, types) {
+			if err := c.resort(s.keys, types); err != nil {
+				return err
+			}
+		}
+		next, err := c.reader()
+		if err != nil {
+			return err
+		}
+		if err := m.push(next); err != nil {
+			return err
+		}
+	}
+	records := s.records
+	if err := m.push(func() (Record, error) {
+		if len(records) == 0 {
+			return Record{}, io.EOF
+		}
+		rec := records[0]
+		records = records[1:]
+		return rec, nil
+	}); err != nil {
+		return err
+	}
+
+	for m.Len() > 0 {
+		src := m.sources[0]
+		if err := fn(src.rec); err != nil {
+			return err
+		}
+		rec, err := src.next()
+		if err == io.EOF {
+			heap.Pop(m)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		src.rec = rec
+		heap.Fix(m, 0)
+	}
+	return nil
+}
+
+// close は一時ファイルを削除します。
+func (s *recordSorter) close() {
+	for _, c := range s.chunks {
+		c.file.Close()
+		os.Remove(c.file.Name())
+	}
+	s.chunks = nil
+}
+
+// mergeSource は併合する並べ替え済みのレコードの列の1つです。rec は次に渡すレコードです。
+type mergeSource struct {
+	rec   Record
+	next  func() (Record, error)
+	order int
+}
+
+// sortMerge は mergeSource の次のレコードを比較する最小ヒープです。
+type sortMerge struct {
+	keys    []SortKey
+	types   []SortType
+	sources []*mergeSource
+}
+
+// push は next の列をヒープに加えます。空の列は加えません。
+func (m *sortMerge) push(next func() (Record, error)) error {
+	rec, err := next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	heap.Push(m, &mergeSource{rec: rec, next: next, order: len(m.sources)})
+	return nil
+}
+
+func (m *sortMerge) Len() int { return len(m.sources) }
+
+func (m *sortMerge) Less(i, j int) bool {
+	a, b := m.sources[i], m.sources[j]
+	if c := compareRecords(a.rec, b.rec, m.keys, m.types); c != 0 {
+		return c < 0
+	}
+	// 同じ順位のレコードは先に加えた列のものを先に渡す
+	return a.order < b.order
+}
+
+func (m *sortMerge) Swap(i, j int) { m.sources[i], m.sources[j] = m.sources[j], m.sources[i] }
+
+func (m *sortMerge) Push(x any) { m.sources = append(m.sources, x.(*mergeSource)) }
+
+func (m *sortMerge) Pop() any {
+	last := m.sources[len(m.sources)-1]
+	m.sources = m.sources[:len(m.sources)-1]
+	return last
+}
@@ -0,0 +1,128 @@
+package chiicgrep
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// MissingColumnsError は Config.RequiredColumns の列がファイルに欠けていたことを示すエラーです。
+type MissingColumnsError struct {
+	Missing []string
+}
+
+func (e *MissingColumnsError) Error() string {
+	return "missing required columns: " + strings.Join(e.Missing, ", ")
+}
+
+// FileErrors は1回の処理で発生したファイルごとのエラーをまとめたエラーです。
+// errors.Is と errors.As は個々のエラーについて判定します。
+type FileErrors []FileError
+
+func (e FileErrors) Error() string {
+	files := e.Files()
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Error()
+	}
+	return fmt.Sprintf("%d files had errors: %s", len(files), strings.Join(parts, "; "))
+}
+
+// Unwrap は個々のエラーを返します。
+func (e FileErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe.Err
+	}
+	return errs
+}
+
+// Files はエラーが発生したファイルを、最初のエラーの順に重複なく返します。
+func (e FileErrors) Files() []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, fe := range e {
+		if !seen[fe.File] {
+			seen[fe.File] = true
+			files = append(files, fe.File)
+		}
+	}
+	return files
+}
+
+// ByFile はファイルごとのエラーの一覧を返します。
+func (e FileErrors) ByFile() map[string][]error {
+	m := make(map[string][]error)
+	for _, fe := range e {
+		m[fe.File] = append(m[fe.File], fe.Err)
+	}
+	return m
+}
+
+// Err は読み込みエラーと必須列の欠落を、ファイルごとのエラーをまとめた FileErrors として返します。
+// どちらもない場合は nil を返します。
+func (s Summary) Err() error {
+	if len(s.Errors) == 0 && len(s.SchemaViolations) == 0 {
+		return nil
+	}
+	errs := make(FileErrors, 0, len(s.Errors)+len(s.SchemaViolations))
+	errs = append(errs, s.Errors...)
+	for _, v := range s.SchemaViolations {
+		errs = append(errs, FileError{File: v.File, Err: &MissingColumnsError{Missing: v.Missing}})
+	}
+	return errs
+}
+
+// fileErrorKind はエラー一覧に表示するエラーの種類を返します。
+func fileErrorKind(err error) string {
+	var missing *MissingColumnsError
+	var parseErr *parseError
+	switch {
+	case errors.As(err, &missing):
+		return "必須列の欠落"
+	case errors.As(err, &parseErr):
+		return "CSVの解析エラー"
+	case errors.Is(err, ErrFileLocked):
+		return "ロック中"
+	}
+	return "読み込みエラー"
+}
+
+// writeErrorList は読み込みエラーと必須列の欠落を、ファイルごとにまとめた「エラー一覧」の表として出力します。
+func writeErrorList(sw *render.Writer, sum Summary) {
+	errs, ok := sum.Err().(FileErrors)
+	if !ok {
+		return
+	}
+	byFile := errs.ByFile()
+	files := errs.Files()
+	sw.Printf("<div class=\"errors\" id=\"errors\">\n<div class=\"errors-info\">エラー一覧（%dファイル）: 読み込みエラーのファイルは結果に含まれていないか、途中までしか含まれていません。</div>\n", len(files))
+	sw.WriteString("<table>\n<thead><tr><th>ファイル</th><th>種類</th><th>内容</th></tr></thead>\n")
+	for _, file := range files {
+		for i, err := range byFile[file] {
+			sw.WriteString("<tr>")
+			if i == 0 {
+				sw.Printf("<td class=\"error-file\" rowspan=\"%d\">%s</td>", len(byFile[file]), html.EscapeString(file))
+			}
+			sw.Printf("<td>%s</td><td class=\"error\">%s</td></tr>\n", fileErrorKind(err), html.EscapeString(err.Error()))
+		}
+	}
+	sw.WriteString("</table>\n</div>\n")
+}
+
+// writeLockedFiles はロックされていて開けなかったファイルを「ロック中のファイル」として出力します。
+// 開いているプログラムを閉じれば読み込めるため、ほかのエラーとは分けて先頭に表示します。
+func writeLockedFiles(sw *render.Writer, sum Summary) {
+	files := sum.LockedFiles()
+	if len(files) == 0 {
+		return
+	}
+	sw.Printf("<div class=\"locked\" id=\"locked\">\n<div class=\"locked-info\">ロック中のファイル（%dファイル）: ほかのプログラムで開かれていたため読み込めませんでした。Excelなどで閉じてから再度実行してください。</div>\n<ul>\n", len(files))
+	for _, file := range files {
+		sw.Printf("<li>%s</li>\n", html.EscapeString(file))
+	}
+	sw.WriteString("</ul>\n</div>\n")
+}
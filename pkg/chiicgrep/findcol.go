@@ -0,0 +1,151 @@
+package chiicgrep
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"go-ChiiCgrep/internal/scan"
+)
+
+// findColumnSampleRows は FindColumns が値の例を探すために読み込むデータ行の上限です。
+const findColumnSampleRows = 1000
+
+// ColumnPattern は列名を探すパターンです。"/正規表現/" の形式は正規表現として、それ以外は大文字と小文字を区別しない部分一致として扱います。
+type ColumnPattern struct {
+	Keyword string
+	Pattern *regexp.Regexp
+}
+
+// ParseColumnPattern は列名を探すパターンを解析します。
+func ParseColumnPattern(s string) (ColumnPattern, error) {
+	if s == "" {
+		return ColumnPattern{}, fmt.Errorf("empty column pattern")
+	}
+	if len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		re, err := regexp.Compile(s[1 : len(s)-1])
+		if err != nil {
+			return ColumnPattern{}, fmt.Errorf("invalid regexp in column pattern %q: %w", s, err)
+		}
+		return ColumnPattern{Pattern: re}, nil
+	}
+	return ColumnPattern{Keyword: strings.ToLower(s)}, nil
+}
+
+// match は列名 name がパターンに一致するかを返します。
+func (p ColumnPattern) match(name string) bool {
+	if p.Pattern != nil {
+		return p.Pattern.MatchString(name)
+	}
+	return strings.Contains(strings.ToLower(name), p.Keyword)
+}
+
+// FoundColumn は FindColumns で見つかった列です。
+type FoundColumn struct {
+	Name string
+	// Index は0から数えた列の位置です。
+	Index int
+	// Samples は先頭から読み込んだ行にあった、空でない異なる値の例です。
+	Samples []string
+}
+
+// FileColumns は1つの入力で見つかった列です。
+type FileColumns struct {
+	File    string
+	Columns []FoundColumn
+	// Err はファイルを読み込めなかった場合のエラーです。
+	Err error
+}
+
+// FindColumns は cfg の入力に含まれる各ファイルのヘッダーから、pattern に一致する列を探します。
+// 一致した列ごとに、先頭から最大 findColumnSampleRows 行を読み込んで、空でない異なる値を samples 件まで集めます。
+// 結果は列が見つからなかったファイルも含め、入力の順序で返されます。
+func FindColumns(ctx context.Context, cfg Config, pattern ColumnPattern, samples int) ([]FileColumns, error) {
+	src := cfg.source()
+	files, err := cfg.listFiles(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoCSVFiles
+	}
+	result := make([]FileColumns, 0, len(files))
+	for _, name := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fc := FileColumns{File: name}
+		fc.Columns, fc.Err = findFileColumns(src, name, cfg, pattern, samples)
+		result = append(result, fc)
+	}
+	return result, nil
+}
+
+// findFileColumns は1つのファイルについて FindColumns の処理を行います。
+func findFileColumns(src Source, name string, cfg Config, pattern ColumnPattern, samples int) ([]FoundColumn, error) {
+	r, err := src.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer r.Close()
+
+	reader := scan.NewReader(r, cfg.scanOptions())
+	reader.FieldsPerRecord = -1
+	clean := cfg.cellCleaner()
+	headers, err := scan.ReadHeader(reader, clean)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read headers: %w", err)
+	}
+	if clean == nil {
+		clean = func(s string) string { return s }
+	}
+	cfg.mapHeaders(name, headers)
+	var columns []FoundColumn
+	for i, h := range headers {
+		if pattern.match(h) {
+			columns = append(columns, FoundColumn{Name: h, Index: i})
+		}
+	}
+	if len(columns) == 0 || samples <= 0 {
+		return columns, nil
+	}
+
+	seen := make([]map[string]bool, len(columns))
+	for i := range seen {
+		seen[i] = make(map[string]bool)
+	}
+	for row := 0; row < findColumnSampleRows; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// 値の例は参考のため、途中で読めなくなった場合はそれまでの例を返す
+			break
+		}
+		full := true
+		for i := range columns {
+			c := &columns[i]
+			if len(c.Samples) == samples {
+				continue
+			}
+			full = false
+			if c.Index >= len(record) {
+				continue
+			}
+			if v := clean(record[c.Index]); v != "" && !seen[i][v] {
+				seen[i][v] = true
+				c.Samples = append(c.Samples, v)
+			}
+		}
+		if full {
+			break
+		}
+	}
+	return columns, nil
+}
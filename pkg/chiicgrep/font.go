@@ -0,0 +1,46 @@
+package chiicgrep
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// fontFormats はHTMLレポートに埋め込めるフォントファイルの拡張子ごとの、@font-face の format() とMIMEタイプです。
+var fontFormats = map[string]struct{ format, mimeType string }{
+	".woff2": {"woff2", "font/woff2"},
+	".woff":  {"woff", "font/woff"},
+	".ttf":   {"truetype", "font/ttf"},
+	".otf":   {"opentype", "font/otf"},
+}
+
+// IsFontFile は HTMLOptions.Font の値がフォント名ではなく、埋め込むフォントファイルのパスかを拡張子で判定します。
+func IsFontFile(font string) bool {
+	_, ok := fontFormats[strings.ToLower(filepath.Ext(font))]
+	return ok
+}
+
+// fontFace は font がフォントファイルのパスの場合に、ファイルをbase64で埋め込んだ @font-face の定義と、
+// 値に適用するフォントの名前（拡張子を除いたファイル名）を返します。フォント名の場合は空の定義とそのままの名前を返します。
+// レポートを開くパソコンにフォントがインストールされていなくても、指定したフォントで表示できます。
+func fontFace(font string) (css, family string, err error) {
+	if !IsFontFile(font) {
+		return "", font, nil
+	}
+	data, err := os.ReadFile(font)
+	if err != nil {
+		return "", "", fmt.Errorf("could not embed font: %w", err)
+	}
+	f := fontFormats[strings.ToLower(filepath.Ext(font))]
+	family = render.FontName(strings.TrimSuffix(filepath.Base(font), filepath.Ext(font)))
+	if family == "" {
+		family = "embedded"
+	}
+	css = fmt.Sprintf("@font-face { font-family: \"%s\"; src: url(data:%s;base64,%s) format(\"%s\"); }\n",
+		family, f.mimeType, base64.StdEncoding.EncodeToString(data), f.format)
+	return css, family, nil
+}
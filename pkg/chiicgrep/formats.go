@@ -0,0 +1,247 @@
+package chiicgrep
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// CSVRenderer はレコードをCSVとして出力します。Excelで文字化けしないよう、先頭にUTF-8のBOMを付けます。
+// 1行目は見出しで、ファイル名と行番号に続けて抽出する列を Config.Columns の順に並べます。
+type CSVRenderer struct {
+	// ContextColumn が true の場合、行番号の後に Context 列を加え、Config.Context で出力した前後の行には
+	// "context" を、一致した行には空の値を出力します。-context を指定した場合に一致した行と区別するためです。
+	ContextColumn bool
+
+	w       *csv.Writer
+	bom     io.Writer
+	columns []Column
+}
+
+// NewCSVRenderer は columns の列を出力する新しい CSVRenderer を作成します。
+func NewCSVRenderer(w io.Writer, columns []Column) *CSVRenderer {
+	return &CSVRenderer{w: csv.NewWriter(w), bom: w, columns: columns}
+}
+
+// Begin はBOMと見出しの行を出力します。
+func (r *CSVRenderer) Begin() error {
+	if _, err := io.WriteString(r.bom, "\ufeff"); err != nil {
+		return err
+	}
+	header := []string{"File", "Line"}
+	if r.ContextColumn {
+		header = append(header, "Context")
+	}
+	for _, col := range r.columns {
+		header = append(header, col.Label)
+	}
+	r.w.Write(header)
+	return r.w.Error()
+}
+
+// Render は1件のレコードを1行として出力します。ファイルにない列は空になります。
+func (r *CSVRenderer) Render(rec Record) error {
+	// 同じ列を異なる表示名で指定できるため、表示名で対応付ける
+	values := make(map[string]string, len(rec.Fields))
+	for _, f := range rec.Fields {
+		values[f.Column.Label] = f.Value
+	}
+	row := []string{rec.File, strconv.Itoa(rec.Line)}
+	if r.ContextColumn {
+		row = append(row, contextMarker(rec))
+	}
+	for _, col := range r.columns {
+		row = append(row, values[col.Label])
+	}
+	r.w.Write(row)
+	return r.w.Error()
+}
+
+// contextMarker は CSVRenderer と TSVRenderer の Context 列に出力する値です。
+func contextMarker(rec Record) string {
+	if rec.Context {
+		return "context"
+	}
+	return ""
+}
+
+// End はバッファに残った行を書き出します。
+func (r *CSVRenderer) End(sum Summary) error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// JSONRenderer はレコードと集計を1つのJSONオブジェクトとして出力します。
+// レコードは受け取った順に書き出すため、レコード数によらずメモリ使用量は一定です。
+//
+//	{"records": [{"file": "...", "line": 2, "fields": {"氏名": "..."}, ...}], "summary": {...}}
+type JSONRenderer struct {
+	w     io.Writer
+	count int
+}
+
+// NewJSONRenderer は新しい JSONRenderer を作成します。
+func NewJSONRenderer(w io.Writer) *JSONRenderer {
+	return &JSONRenderer{w: w}
+}
+
+// jsonRecord は JSONRenderer が出力する1件のレコードです。
+type jsonRecord struct {
+	File        string            `json:"file"`
+	Line        int               `json:"line"`
+	Fields      map[string]string `json:"fields"`
+	Highlighted bool              `json:"highlighted,omitempty"`
+	Invalid     bool              `json:"invalid,omitempty"`
+	Context     bool              `json:"context,omitempty"`
+	New         bool              `json:"new,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	RowTags     []string          `json:"row_tags,omitempty"`
+	Duplicates  []string          `json:"duplicate_files,omitempty"`
+	DuplicateOf *jsonLocation     `json:"duplicate_of,omitempty"`
+}
+
+// jsonSummary は JSONRenderer が出力する集計です。
+type jsonSummary struct {
+	FilesScanned     int               `json:"files_scanned"`
+	FilesTotal       int               `json:"files_total"`
+	FilesWithMatches int               `json:"files_with_matches"`
+	RowsScanned      int64             `json:"rows_scanned"`
+	Matches          int               `json:"matches"`
+	ElapsedMS        int64             `json:"elapsed_ms"`
+	Interrupted      bool              `json:"interrupted,omitempty"`
+	Truncated        bool              `json:"truncated,omitempty"`
+	Errors           []jsonFileError   `json:"errors,omitempty"`
+	LockedFiles      []string          `json:"locked_files,omitempty"`
+	Validations      []jsonValidation  `json:"validations,omitempty"`
+	KeyConflicts     []jsonKeyConflict `json:"key_conflicts,omitempty"`
+}
+
+// jsonKeyConflict は Config.UniqueKey の値が重複したレコードの位置です。
+type jsonKeyConflict struct {
+	Key       []string       `json:"key"`
+	Locations []jsonLocation `json:"locations"`
+}
+
+// jsonLocation はレコードのファイルと行番号です。
+type jsonLocation struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// jsonValidation は検証ルールごとの違反です。
+type jsonValidation struct {
+	Rule       string          `json:"rule"`
+	Violations int             `json:"violations"`
+	Examples   []jsonViolation `json:"examples"`
+}
+
+// jsonViolation は検証ルールに違反した1つの値です。
+type jsonViolation struct {
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	Value string `json:"value"`
+}
+
+// jsonFileError はファイルごとのエラーです。
+type jsonFileError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// Begin はオブジェクトとレコードの配列を開始します。
+func (r *JSONRenderer) Begin() error {
+	_, err := io.WriteString(r.w, "{\"records\": [\n")
+	return err
+}
+
+// Render は1件のレコードを配列の要素として出力します。
+func (r *JSONRenderer) Render(rec Record) error {
+	jr := jsonRecord{File: rec.File, Line: rec.Line, Fields: make(map[string]string, len(rec.Fields)),
+		Highlighted: rec.Highlighted, Invalid: rec.Invalid, Context: rec.Context, New: rec.New, Tags: rec.Tags, RowTags: rec.RowTags,
+		Duplicates: rec.DuplicateFiles}
+	if d := rec.DuplicateOf; d != nil {
+		jr.DuplicateOf = &jsonLocation{File: d.File, Line: d.Line}
+	}
+	for _, f := range rec.Fields {
+		jr.Fields[f.Column.Label] = f.Value
+	}
+	data, err := json.Marshal(jr)
+	if err != nil {
+		return err
+	}
+	if r.count > 0 {
+		if _, err := io.WriteString(r.w, ",\n"); err != nil {
+			return err
+		}
+	}
+	r.count++
+	_, err = r.w.Write(data)
+	return err
+}
+
+// End はレコードの配列を閉じ、集計を出力してオブジェクトを閉じます。
+func (r *JSONRenderer) End(sum Summary) error {
+	js := jsonSummary{FilesScanned: sum.FilesScanned, FilesTotal: sum.FilesTotal, FilesWithMatches: sum.FilesWithMatches, RowsScanned: sum.RowsScanned,
+		Matches: sum.Matches, ElapsedMS: sum.Elapsed.Milliseconds(), Interrupted: sum.Interrupted || sum.Aborted, Truncated: sum.Truncated(),
+		LockedFiles: sum.LockedFiles()}
+	if errs, ok := sum.Err().(FileErrors); ok {
+		for _, fe := range errs {
+			js.Errors = append(js.Errors, jsonFileError{File: fe.File, Error: fe.Err.Error()})
+		}
+	}
+	for _, v := range sum.Validations {
+		jv := jsonValidation{Rule: v.Rule.String(), Violations: v.Count, Examples: []jsonViolation{}}
+		for _, e := range v.Examples {
+			jv.Examples = append(jv.Examples, jsonViolation{File: e.File, Line: e.Line, Value: e.Value})
+		}
+		js.Validations = append(js.Validations, jv)
+	}
+	for _, c := range sum.KeyConflicts {
+		jc := jsonKeyConflict{Key: c.Key}
+		for _, loc := range c.Locations {
+			jc.Locations = append(jc.Locations, jsonLocation{File: loc.File, Line: loc.Line})
+		}
+		js.KeyConflicts = append(js.KeyConflicts, jc)
+	}
+	data, err := json.Marshal(js)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(r.w, "\n], \"summary\": "+string(data)+"}\n")
+	return err
+}
+
+// MultiRenderer は同じレコードを複数の Renderer に渡します。入力を1回読み込むだけで、複数の形式で出力できます。
+type MultiRenderer []Renderer
+
+// Begin はすべての Renderer の Begin を呼び出します。
+func (m MultiRenderer) Begin() error {
+	for _, r := range m {
+		if err := r.Begin(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render はすべての Renderer に rec を渡します。
+func (m MultiRenderer) Render(rec Record) error {
+	for _, r := range m {
+		if err := r.Render(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// End はすべての Renderer の End を呼び出します。エラーがあっても残りの Renderer を閉じ、最初のエラーを返します。
+func (m MultiRenderer) End(sum Summary) error {
+	var first error
+	for _, r := range m {
+		if err := r.End(sum); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,91 @@
+package chiicgrep
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// contextRecords は -context で出力される、一致した行とその前後の行です。
+func contextRecords() []Record {
+	col := Column{Name: "メッセージ", Label: "メッセージ"}
+	return []Record{
+		{File: "app.csv", Line: 2, Fields: []Field{{Column: col, Value: "開始"}}, Context: true},
+		{File: "app.csv", Line: 3, Fields: []Field{{Column: col, Value: "エラー"}}},
+		{File: "app.csv", Line: 4, Fields: []Field{{Column: col, Value: "終了"}}, Context: true},
+	}
+}
+
+func TestCSVRendererContextColumn(t *testing.T) {
+	tests := []struct {
+		name          string
+		contextColumn bool
+		want          string
+	}{
+		{
+			name: "Context 列なし",
+			want: "\ufeffFile,Line,メッセージ\napp.csv,2,開始\napp.csv,3,エラー\napp.csv,4,終了\n",
+		},
+		{
+			name:          "前後の行に context を出力する",
+			contextColumn: true,
+			want:          "\ufeffFile,Line,Context,メッセージ\napp.csv,2,context,開始\napp.csv,3,,エラー\napp.csv,4,context,終了\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := NewCSVRenderer(&buf, ParseColumns("メッセージ"))
+			r.ContextColumn = tt.contextColumn
+			renderAll(t, r, contextRecords())
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTSVRendererContextColumn(t *testing.T) {
+	tests := []struct {
+		name          string
+		contextColumn bool
+		want          []string
+	}{
+		{
+			name: "Context 列なし",
+			want: []string{"File\tLine\tメッセージ", "app.csv\t2\t開始", "app.csv\t3\tエラー", "app.csv\t4\t終了"},
+		},
+		{
+			name:          "前後の行に context を出力する",
+			contextColumn: true,
+			want:          []string{"File\tLine\tContext\tメッセージ", "app.csv\t2\tcontext\t開始", "app.csv\t3\t\tエラー", "app.csv\t4\tcontext\t終了"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := NewTSVRenderer(&buf, ParseColumns("メッセージ"))
+			r.ContextColumn = tt.contextColumn
+			renderAll(t, r, contextRecords())
+			if got, want := buf.String(), strings.Join(tt.want, "\n")+"\n"; got != want {
+				t.Errorf("output = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// renderAll は records を r で出力します。
+func renderAll(t *testing.T, r Renderer, records []Record) {
+	t.Helper()
+	if err := r.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := r.Render(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.End(Summary{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,201 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// GanttColumns は開始日、終了日と、バーの見出しにする列です。HTMLレポートに、一致したレコードの期間を横棒で描いた工程表を出力します。
+// Label が空の場合は、ファイル名と行番号を見出しにします。
+type GanttColumns struct {
+	Start string
+	End   string
+	Label string
+}
+
+// GanttSpan はレコードの期間です。終了日はその日を含みます。
+type GanttSpan struct {
+	Start time.Time
+	End   time.Time
+	Label string
+}
+
+// ganttMaxRows はHTMLレポートの工程表に描くレコードの最大数です。それ以降のレコードは描きません。
+const ganttMaxRows = 1000
+
+// ParseGanttColumns は "開始日,終了日[,見出し]" 形式の列の指定を解析します。
+func ParseGanttColumns(s string) (*GanttColumns, error) {
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] == "") {
+		return nil, fmt.Errorf("invalid gantt columns %q: expected <start column>,<end column>[,<label column>]", s)
+	}
+	g := &GanttColumns{Start: parts[0], End: parts[1]}
+	if len(parts) == 3 {
+		g.Label = parts[2]
+	}
+	return g, nil
+}
+
+// parseGanttSpan は開始日 start と終了日 end の値を解釈します。終了日が空の場合は開始日の1日だけの期間とします。
+// 開始日を解釈できない場合と、終了日が開始日より前の場合は nil を返します。
+func parseGanttSpan(start, end, label string) *GanttSpan {
+	s, ok := parseDate(start)
+	if !ok {
+		return nil
+	}
+	e := s
+	if strings.TrimSpace(end) != "" {
+		if e, ok = parseDate(end); !ok || e.Before(s) {
+			return nil
+		}
+	}
+	return &GanttSpan{Start: s, End: e, Label: label}
+}
+
+// ganttBar は工程表に描く1件のレコードです。
+type ganttBar struct {
+	span        GanttSpan
+	id          string
+	label       string
+	highlighted bool
+}
+
+// ganttChart はHTMLレポートの工程表に描くレコードを集めます。
+type ganttChart struct {
+	bars []ganttBar
+	// omitted は ganttMaxRows を超えたため描かないレコードの数です。
+	omitted int
+	// invalid は期間を解釈できなかったレコードの数です。
+	invalid int
+}
+
+// add はレコードを工程表に加えます。
+func (g *ganttChart) add(rec Record) {
+	if rec.Context {
+		return
+	}
+	if rec.Span == nil {
+		g.invalid++
+		return
+	}
+	if len(g.bars) >= ganttMaxRows {
+		g.omitted++
+		return
+	}
+	label := rec.Span.Label
+	if label == "" {
+		label = fmt.Sprintf("%s:%d", rec.File, rec.Line)
+	}
+	g.bars = append(g.bars, ganttBar{span: *rec.Span, id: recordID(rec), label: label, highlighted: rec.Highlighted})
+}
+
+// ganttTicks は期間 from〜to の目盛りの日付と、その表示の書式を返します。期間の長さに応じて日、週、月、年の単位にします。
+func ganttTicks(from, to time.Time) ([]time.Time, string) {
+	days := to.Sub(from).Hours() / 24
+	var (
+		t      time.Time
+		next   func(time.Time) time.Time
+		layout string
+	)
+	switch {
+	case days <= 31:
+		t, layout = from, "1/2"
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case days <= 120:
+		// 月曜日ごとに目盛りを付ける
+		t, layout = from.AddDate(0, 0, (8-int(from.Weekday()))%7), "1/2"
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case days <= 3*366:
+		t, layout = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location()), "2006/1"
+		if t.Before(from) {
+			t = t.AddDate(0, 1, 0)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	default:
+		t, layout = time.Date(from.Year(), 1, 1, 0, 0, 0, 0, from.Location()), "2006"
+		if t.Before(from) {
+			t = t.AddDate(1, 0, 0)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+	}
+	var ticks []time.Time
+	for ; !t.After(to); t = next(t) {
+		ticks = append(ticks, t)
+	}
+	return ticks, layout
+}
+
+// write は Summary.Gantt が指定されている場合に、レコードの期間を横棒で描いた工程表をインラインSVGで出力します。
+// レコードは渡された順に上から並べ、各バーはクリックするとそのレコードへ移動します。
+func (g *ganttChart) write(sw *render.Writer, sum Summary) {
+	if sum.Gantt == nil {
+		return
+	}
+	title := html.EscapeString(sum.Gantt.Start + "〜" + sum.Gantt.End)
+	if len(g.bars) == 0 {
+		sw.Printf("<div class=\"summary gantt\">\n<div class=\"summary-info\">工程表: %s</div>\n期間を解釈できるレコードはありません。\n</div>\n", title)
+		return
+	}
+	from, to := g.bars[0].span.Start, g.bars[0].span.End
+	for _, b := range g.bars {
+		if b.span.Start.Before(from) {
+			from = b.span.Start
+		}
+		if b.span.End.After(to) {
+			to = b.span.End
+		}
+	}
+	// 終了日はその日を含むため、最後の日の終わりまでを描く
+	to = to.AddDate(0, 0, 1)
+
+	const labelW, chartW, rowH, headerH = 220.0, 640.0, 20.0, 24.0
+	total := to.Sub(from).Seconds()
+	x := func(t time.Time) float64 { return labelW + t.Sub(from).Seconds()/total*chartW }
+	width, height := labelW+chartW+10, headerH+float64(len(g.bars))*rowH+4
+
+	sw.Printf("<div class=\"summary gantt\">\n<div class=\"summary-info\">工程表: %s（%d件）</div>\n", title, len(g.bars))
+	sw.Printf("<svg class=\"gantt-chart\" xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" role=\"img\">\n", width, height, width, height)
+	ticks, layout := ganttTicks(from, to)
+	for _, t := range ticks {
+		tx := x(t)
+		sw.Printf("<line class=\"gantt-grid\" x1=\"%.1f\" y1=\"%.0f\" x2=\"%.1f\" y2=\"%.0f\"/>", tx, headerH-4, tx, height)
+		sw.Printf("<text class=\"gantt-tick\" x=\"%.1f\" y=\"%.0f\">%s</text>\n", tx+2, headerH-8, t.Format(layout))
+	}
+	for i, b := range g.bars {
+		y := headerH + float64(i)*rowH
+		class := "gantt-bar"
+		if b.highlighted {
+			class = "gantt-bar highlighted"
+		}
+		x1, x2 := x(b.span.Start), x(b.span.End.AddDate(0, 0, 1))
+		period := b.span.Start.Format("2006-01-02") + "〜" + b.span.End.Format("2006-01-02")
+		sw.Printf("<a href=\"#%s\"><text class=\"gantt-label\" x=\"4\" y=\"%.0f\">%s</text>", b.id, y+14, html.EscapeString(ganttLabel(b.label)))
+		sw.Printf("<rect class=\"%s\" x=\"%.1f\" y=\"%.0f\" width=\"%.1f\" height=\"%.0f\" rx=\"3\"><title>%s: %s</title></rect></a>\n",
+			class, x1, y+3, max(x2-x1, 2), rowH-6, html.EscapeString(b.label), period)
+	}
+	sw.WriteString("</svg>\n")
+	if g.omitted > 0 {
+		sw.Printf("<div class=\"chart-note\">最初の %d 件だけを描いています（%d件を省略）。</div>\n", len(g.bars), g.omitted)
+	}
+	if g.invalid > 0 {
+		sw.Printf("<div class=\"chart-note\">開始日または終了日を解釈できなかった %d 件は描いていません。</div>\n", g.invalid)
+	}
+	sw.WriteString("</div>\n")
+}
+
+// ganttLabel は工程表の見出しの欄に収まるよう、長い見出しを省略します。
+func ganttLabel(s string) string {
+	const maxRunes = 16
+	r := []rune(s)
+	if len(r) <= maxRunes {
+		return s
+	}
+	return string(r[:maxRunes-1]) + "…"
+}
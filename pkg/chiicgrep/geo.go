@@ -0,0 +1,153 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// GeoColumns は緯度と経度の列です。HTMLレポートに、一致したレコードの位置を描いた地図を出力します。
+type GeoColumns struct {
+	Lat string
+	Lon string
+}
+
+// GeoPoint はレコードの緯度と経度です。
+type GeoPoint struct {
+	Lat float64
+	Lon float64
+}
+
+// geoMaxPoints はHTMLレポートの地図に描くレコードの最大数です。それ以降のレコードは描きません。
+const geoMaxPoints = 10000
+
+// ParseGeoColumns は "緯度,経度" 形式の列の指定を解析します。
+func ParseGeoColumns(s string) (*GeoColumns, error) {
+	lat, lon, found := strings.Cut(s, ",")
+	g := &GeoColumns{Lat: strings.TrimSpace(lat), Lon: strings.TrimSpace(lon)}
+	if !found || g.Lat == "" || g.Lon == "" || strings.Contains(lon, ",") {
+		return nil, fmt.Errorf("invalid geo columns %q: expected <latitude column>,<longitude column>", s)
+	}
+	return g, nil
+}
+
+// parseGeoPoint は緯度 lat と経度 lon の値を解釈します。数値でない場合と範囲外の場合は nil を返します。
+func parseGeoPoint(lat, lon string) *GeoPoint {
+	la, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil || la < -90 || la > 90 {
+		return nil
+	}
+	lo, err := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err != nil || lo < -180 || lo > 180 {
+		return nil
+	}
+	return &GeoPoint{Lat: la, Lon: lo}
+}
+
+// geoMarker は地図に描く1件のレコードです。
+type geoMarker struct {
+	point       GeoPoint
+	id          string
+	label       string
+	highlighted bool
+}
+
+// geoMap はHTMLレポートの地図に描くレコードを集めます。
+type geoMap struct {
+	markers []geoMarker
+	// omitted は geoMaxPoints を超えたため描かないレコードの数です。
+	omitted int
+	// invalid は緯度と経度を解釈できなかったレコードの数です。
+	invalid int
+}
+
+// add はレコードを地図に加えます。
+func (g *geoMap) add(rec Record) {
+	if rec.Context {
+		return
+	}
+	if rec.Location == nil {
+		g.invalid++
+		return
+	}
+	if len(g.markers) >= geoMaxPoints {
+		g.omitted++
+		return
+	}
+	g.markers = append(g.markers, geoMarker{
+		point:       *rec.Location,
+		id:          recordID(rec),
+		label:       fmt.Sprintf("%s:%d", rec.File, rec.Line),
+		highlighted: rec.Highlighted,
+	})
+}
+
+// write は Summary.Geo が指定されている場合に、レコードの位置を描いた散布図をインラインSVGで出力します。
+// 地図の画像や外部のスクリプトを読み込まないため、インターネットに接続できない環境でも表示できます。
+// 経度は緯度の中央での縮尺に合わせて縮め、各点はクリックするとそのレコードへ移動します。
+func (g *geoMap) write(sw *render.Writer, sum Summary) {
+	if sum.Geo == nil {
+		return
+	}
+	if len(g.markers) == 0 {
+		sw.Printf("<div class=\"summary geo\">\n<div class=\"summary-info\">地図: %s, %s</div>\n緯度と経度を解釈できるレコードはありません。\n</div>\n",
+			html.EscapeString(sum.Geo.Lat), html.EscapeString(sum.Geo.Lon))
+		return
+	}
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, m := range g.markers {
+		minLat, maxLat = math.Min(minLat, m.point.Lat), math.Max(maxLat, m.point.Lat)
+		minLon, maxLon = math.Min(minLon, m.point.Lon), math.Max(maxLon, m.point.Lon)
+	}
+	// 1点だけの場合や点が一直線に並ぶ場合も描けるよう、範囲に最低限の幅を持たせる
+	const minSpan = 0.01
+	if maxLat-minLat < minSpan {
+		c := (minLat + maxLat) / 2
+		minLat, maxLat = c-minSpan/2, c+minSpan/2
+	}
+	if maxLon-minLon < minSpan {
+		c := (minLon + maxLon) / 2
+		minLon, maxLon = c-minSpan/2, c+minSpan/2
+	}
+	scaleX := math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+	spanX, spanY := (maxLon-minLon)*scaleX, maxLat-minLat
+
+	const maxW, maxH, pad = 640.0, 480.0, 16.0
+	scale := math.Min(maxW/spanX, maxH/spanY)
+	width, height := spanX*scale+2*pad, spanY*scale+2*pad
+
+	sw.Printf("<div class=\"summary geo\">\n<div class=\"summary-info\">地図: %s, %s（%d件）</div>\n",
+		html.EscapeString(sum.Geo.Lat), html.EscapeString(sum.Geo.Lon), len(g.markers))
+	sw.Printf("<svg class=\"geo-map\" xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" role=\"img\">\n", width, height, width, height)
+	sw.Printf("<rect x=\"0\" y=\"0\" width=\"%.0f\" height=\"%.0f\" fill=\"#f1f8e9\" stroke=\"#ccc\"/>\n", width, height)
+	// 強調表示したレコードが他の点に隠れないよう、後から描く
+	for _, highlighted := range []bool{false, true} {
+		for _, m := range g.markers {
+			if m.highlighted != highlighted {
+				continue
+			}
+			x := pad + (m.point.Lon-minLon)*scaleX*scale
+			y := pad + (maxLat-m.point.Lat)*scale
+			class := "geo-point"
+			if m.highlighted {
+				class = "geo-point highlighted"
+			}
+			sw.Printf("<a href=\"#%s\"><circle class=\"%s\" cx=\"%.1f\" cy=\"%.1f\" r=\"4\"><title>%s (%g, %g)</title></circle></a>\n",
+				m.id, class, x, y, html.EscapeString(m.label), m.point.Lat, m.point.Lon)
+		}
+	}
+	sw.WriteString("</svg>\n")
+	sw.Printf("<div class=\"chart-note\">緯度 %.4f〜%.4f、経度 %.4f〜%.4f</div>\n", minLat, maxLat, minLon, maxLon)
+	if g.omitted > 0 {
+		sw.Printf("<div class=\"chart-note\">最初の %d 件だけを描いています（%d件を省略）。</div>\n", len(g.markers), g.omitted)
+	}
+	if g.invalid > 0 {
+		sw.Printf("<div class=\"chart-note\">緯度と経度を解釈できなかった %d 件は描いていません。</div>\n", g.invalid)
+	}
+	sw.WriteString("</div>\n")
+}
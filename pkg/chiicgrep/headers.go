@@ -0,0 +1,72 @@
+package chiicgrep
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go-ChiiCgrep/internal/scan"
+)
+
+// FileHeaders は1つの入力のヘッダー行です。
+type FileHeaders struct {
+	File    string
+	Headers []string
+}
+
+// ReadHeaders は cfg の入力に含まれる各ファイルのヘッダー行を読み込みます。
+// データ行は読み込みません。ヘッダーを読めなかったファイルは警告を出して除外されます。
+func ReadHeaders(ctx context.Context, cfg Config) ([]FileHeaders, error) {
+	src := cfg.source()
+	files, err := cfg.listFiles(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]FileHeaders, 0, len(files))
+	for _, name := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		headers, err := readHeader(src, name, cfg)
+		if err != nil {
+			warnf(LogKindReadError, name, "could not read headers of %s: %v", name, err)
+			continue
+		}
+		result = append(result, FileHeaders{File: name, Headers: headers})
+	}
+	return result, nil
+}
+
+// UniqueHeaders は複数ファイルのヘッダーを、最初に現れた順序で重複なく並べて返します。
+func UniqueHeaders(files []FileHeaders) []string {
+	seen := make(map[string]bool)
+	var headers []string
+	for _, f := range files {
+		for _, h := range f.Headers {
+			if !seen[h] {
+				seen[h] = true
+				headers = append(headers, h)
+			}
+		}
+	}
+	return headers
+}
+
+// readHeader は src の name を開き、先頭行のみを読み込みます。
+func readHeader(src Source, name string, cfg Config) ([]string, error) {
+	r, err := src.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer r.Close()
+
+	headers, err := scan.ReadHeader(scan.NewReader(r, cfg.scanOptions()), cfg.cellCleaner())
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read headers: %w", err)
+	}
+	cfg.mapHeaders(name, headers)
+	return headers, nil
+}
@@ -0,0 +1,89 @@
+package chiicgrep
+
+// Row はCSVの1行分の値です。RecordHook.BeforeRecord に渡されます。
+type Row struct {
+	File string
+	Line int
+	// Headers は列名、Values はそれぞれの列の値です。Config.Join で加えた列も含みます。
+	// Values の要素は書き換えられますが、長さは変えないでください。
+	Headers []string
+	Values  []string
+
+	index map[string]int
+}
+
+// Get は列 column の値を返します。列がない場合は ok が false です。
+func (r *Row) Get(column string) (value string, ok bool) {
+	idx, ok := r.index[column]
+	if !ok || idx >= len(r.Values) {
+		return "", false
+	}
+	return r.Values[idx], true
+}
+
+// Set は列 column の値を value に置き換えます。列がない場合は何もせず false を返します。
+func (r *Row) Set(column, value string) bool {
+	idx, ok := r.index[column]
+	if !ok || idx >= len(r.Values) {
+		return false
+	}
+	r.Values[idx] = value
+	return true
+}
+
+// RecordHook は1行ごとの処理に割り込むフックです。Config.Hooks に設定すると、指定した順に呼び出されます。
+// フックがエラーを返した場合は、そのファイルの読み込みエラーとして扱います。
+// Config.Jobs が2以上の場合は複数のファイルについて同時に呼び出されるため、フックは並行して呼び出されても安全である必要があります。
+type RecordHook interface {
+	// BeforeRecord は検索文字列や強調表示規則と照合する前に、すべての行について呼び出されます。
+	// row.Values を書き換えると、照合と出力には書き換えた値が使われます。false を返すとその行を読み飛ばします。
+	BeforeRecord(row *Row) (keep bool, err error)
+	// AfterRecord は一致した行のレコードを組み立てた後、出力する前に呼び出されます。
+	// rec を書き換えると、出力には書き換えた値が使われます。false を返すとそのレコードを出力しません。
+	AfterRecord(rec *Record) (keep bool, err error)
+}
+
+// hooks は組み込みの機能のフックと Config.Hooks を、呼び出す順に返します。
+func (cfg Config) hooks() []RecordHook {
+	var hooks []RecordHook
+	if len(cfg.Replacements) > 0 {
+		hooks = append(hooks, replaceHook(cfg.Replacements))
+	}
+	return append(hooks, cfg.Hooks...)
+}
+
+// replaceHook は Config.Replacements を適用するフックです。
+type replaceHook []Replacement
+
+func (h replaceHook) BeforeRecord(row *Row) (bool, error) {
+	for _, rep := range h {
+		if v, ok := row.Get(rep.Column); ok {
+			row.Set(rep.Column, rep.apply(v))
+		}
+	}
+	return true, nil
+}
+
+func (h replaceHook) AfterRecord(*Record) (bool, error) { return true, nil }
+
+// runBeforeHooks は hooks の BeforeRecord を順に呼び出します。いずれかが false を返した時点で残りは呼び出しません。
+func runBeforeHooks(hooks []RecordHook, row *Row) (bool, error) {
+	for _, h := range hooks {
+		keep, err := h.BeforeRecord(row)
+		if err != nil || !keep {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// runAfterHooks は hooks の AfterRecord を順に呼び出します。いずれかが false を返した時点で残りは呼び出しません。
+func runAfterHooks(hooks []RecordHook, rec *Record) (bool, error) {
+	for _, h := range hooks {
+		keep, err := h.AfterRecord(rec)
+		if err != nil || !keep {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,67 @@
+package chiicgrep
+
+import (
+	"encoding/base64"
+	"html"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// isImageColumn は列 col が HTMLOptions.ImageColumns に含まれるかを返します。
+func (r *HTMLRenderer) isImageColumn(col Column) bool {
+	return slices.Contains(r.opts.ImageColumns, col.Name)
+}
+
+// imageSource は画像の列の値 value を <img> の src に使うURLに変換します。
+// http(s) のURLはそのまま使い、パスは相対パスの場合 CSVファイル file のフォルダを基準に解決します。
+// HTMLOptions.EmbedImages の場合はファイルを読み込んでデータURLにします。読み込めない場合は警告を出し、ファイルのURLを返します。
+func (r *HTMLRenderer) imageSource(value, file string) string {
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "data:") {
+		return value
+	}
+	path := filepath.FromSlash(value)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(file), path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if r.opts.EmbedImages {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+			if mimeType == "" {
+				mimeType = "application/octet-stream"
+			}
+			return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		}
+		warnf(LogKindReadError, file, "could not embed image %s referenced in %s: %v", path, file, err)
+	}
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		// Windows のドライブ文字の前にも / を付ける（file:///C:/...）
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// writeImage は画像の列の値を、元の画像へのリンクを付けたサムネイルとして出力します。値が空の場合は何も出力しません。
+func (r *HTMLRenderer) writeImage(sw *render.Writer, f Field, file string) {
+	if strings.TrimSpace(f.Value) == "" {
+		return
+	}
+	src := html.EscapeString(r.imageSource(strings.TrimSpace(f.Value), file))
+	alt := html.EscapeString(f.Value)
+	if strings.HasPrefix(src, "data:") {
+		// データURLはリンク先として開けないブラウザがあるため、リンクを付けない
+		sw.Printf("<img class=\"thumb\" src=\"%s\" alt=\"%s\" title=\"%s\">", src, alt, alt)
+		return
+	}
+	sw.Printf("<a href=\"%s\" target=\"_blank\" rel=\"noopener\"><img class=\"thumb\" src=\"%s\" alt=\"%s\" title=\"%s\" loading=\"lazy\"></a>", src, src, alt, alt)
+}
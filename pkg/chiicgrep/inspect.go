@@ -0,0 +1,244 @@
+package chiicgrep
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ChiiCgrep/internal/scan"
+)
+
+// InspectMaxDistinct は InspectFiles が列ごとに数える異なる値の上限です。これを超える列は DistinctOver を true にします。
+const InspectMaxDistinct = 100000
+
+// ColumnType は InspectFiles が列の値から推定した型です。
+type ColumnType string
+
+const (
+	// TypeInt はすべての値が整数の列です。"1,200" のような桁区切りも整数とみなします。
+	TypeInt ColumnType = "int"
+	// TypeFloat はすべての値が数値で、小数を含む列です。
+	TypeFloat ColumnType = "float"
+	// TypeDate はすべての値が日付（-sort の date と同じ書式）の列です。
+	TypeDate ColumnType = "date"
+	// TypeString はそれ以外の値を含む列です。
+	TypeString ColumnType = "string"
+	// TypeEmpty はすべての値が空の列です。
+	TypeEmpty ColumnType = "empty"
+)
+
+// ColumnProfile は1つの列の値の概要です。
+type ColumnProfile struct {
+	Name string
+	Type ColumnType
+	// Empty は値が空の行（列が足りない行を含む）の数です。
+	Empty int
+	// Distinct は空でない異なる値の数です。DistinctOver の場合は InspectMaxDistinct で数えるのをやめています。
+	Distinct     int
+	DistinctOver bool
+	// Min と Max は Type に従って比較した最小値と最大値です。TypeEmpty の場合は空です。
+	Min string
+	Max string
+	// Samples は先頭から読み込んだ行にあった、空でない異なる値の例です。
+	Samples []string
+}
+
+// NullRate は rows 行のうち値が空の行の割合（0〜1）を返します。
+func (c ColumnProfile) NullRate(rows int) float64 {
+	if rows == 0 {
+		return 0
+	}
+	return float64(c.Empty) / float64(rows)
+}
+
+// FileProfile は1つの入力の列ごとの概要です。
+type FileProfile struct {
+	File string
+	// Rows はヘッダーを除いて読み込んだ行の数です。
+	Rows    int
+	Columns []ColumnProfile
+	// Err はファイルを読み込めなかった場合のエラーです。途中で読めなくなった場合は、それまでの行の概要も返します。
+	Err error
+}
+
+// InspectFiles は cfg の入力に含まれる各ファイルのすべての行を読み込み、列ごとに推定した型、異なる値の数、空の値の数、
+// 最小値と最大値、値の例を samples 件まで集めます。見慣れないCSVファイルの内容を、抽出の条件を考える前に把握するためのものです。
+// 結果は入力の順序で返されます。
+func InspectFiles(ctx context.Context, cfg Config, samples int) ([]FileProfile, error) {
+	src := cfg.source()
+	files, err := cfg.listFiles(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoCSVFiles
+	}
+	result := make([]FileProfile, 0, len(files))
+	for _, name := range files {
+		fp := FileProfile{File: name}
+		if err := inspectFile(ctx, src, name, cfg, samples, &fp); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			fp.Err = err
+		}
+		result = append(result, fp)
+	}
+	return result, nil
+}
+
+// inspectFile は1つのファイルについて InspectFiles の処理を行い、結果を fp に格納します。
+func inspectFile(ctx context.Context, src Source, name string, cfg Config, samples int, fp *FileProfile) error {
+	r, err := src.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer r.Close()
+
+	reader := scan.NewReader(r, cfg.scanOptions())
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	clean := cfg.cellCleaner()
+	headers, err := scan.ReadHeader(reader, clean)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read headers: %w", err)
+	}
+	if clean == nil {
+		clean = func(s string) string { return s }
+	}
+	cfg.mapHeaders(name, headers)
+
+	profilers := make([]*columnProfiler, len(headers))
+	for i := range profilers {
+		profilers[i] = newColumnProfiler(samples)
+	}
+	defer func() {
+		fp.Columns = make([]ColumnProfile, len(headers))
+		for i, p := range profilers {
+			fp.Columns[i] = p.profile(headers[i])
+		}
+	}()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read row: %w", err)
+		}
+		fp.Rows++
+		for i, p := range profilers {
+			v := ""
+			if i < len(record) {
+				v = clean(record[i])
+			}
+			p.add(v)
+		}
+	}
+}
+
+// columnProfiler は1つの列の値を読み込みながら ColumnProfile を組み立てます。
+// 型は最後まで決まらないため、最小値と最大値は数値、日付、文字列のそれぞれで保持します。
+type columnProfiler struct {
+	samples  int
+	empty    int
+	values   int
+	distinct map[string]struct{}
+	over     bool
+	example  []string
+
+	isInt, isNumber, isDate  bool
+	minNum, maxNum           float64
+	minNumText, maxNumText   string
+	minDate, maxDate         time.Time
+	minDateText, maxDateText string
+	minText, maxText         string
+}
+
+// newColumnProfiler は値の例を samples 件まで集める columnProfiler を作成します。
+func newColumnProfiler(samples int) *columnProfiler {
+	return &columnProfiler{samples: samples, distinct: make(map[string]struct{}), isInt: true, isNumber: true, isDate: true}
+}
+
+// add は1行の値を加えます。
+func (p *columnProfiler) add(v string) {
+	if strings.TrimSpace(v) == "" {
+		p.empty++
+		return
+	}
+	first := p.values == 0
+	p.values++
+	if _, ok := p.distinct[v]; !ok {
+		if len(p.distinct) < InspectMaxDistinct {
+			p.distinct[v] = struct{}{}
+			if len(p.example) < p.samples {
+				p.example = append(p.example, v)
+			}
+		} else {
+			p.over = true
+		}
+	}
+
+	if first || v < p.minText {
+		p.minText = v
+	}
+	if first || v > p.maxText {
+		p.maxText = v
+	}
+	if p.isNumber {
+		if f, ok := parseNumber(v); ok {
+			if p.isInt {
+				_, err := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(v), ",", ""), 10, 64)
+				p.isInt = err == nil
+			}
+			if first || f < p.minNum {
+				p.minNum, p.minNumText = f, v
+			}
+			if first || f > p.maxNum {
+				p.maxNum, p.maxNumText = f, v
+			}
+		} else {
+			p.isNumber, p.isInt = false, false
+		}
+	}
+	if p.isDate {
+		if t, ok := parseDate(v); ok {
+			if first || t.Before(p.minDate) {
+				p.minDate, p.minDateText = t, v
+			}
+			if first || t.After(p.maxDate) {
+				p.maxDate, p.maxDateText = t, v
+			}
+		} else {
+			p.isDate = false
+		}
+	}
+}
+
+// profile は加えた値から列 name の ColumnProfile を返します。
+// 整数と日付のどちらとも解釈できる列（20240401 など）は整数とみなします。
+func (p *columnProfiler) profile(name string) ColumnProfile {
+	c := ColumnProfile{Name: name, Empty: p.empty, Distinct: len(p.distinct), DistinctOver: p.over, Samples: p.example}
+	switch {
+	case p.values == 0:
+		c.Type = TypeEmpty
+	case p.isInt:
+		c.Type, c.Min, c.Max = TypeInt, p.minNumText, p.maxNumText
+	case p.isNumber:
+		c.Type, c.Min, c.Max = TypeFloat, p.minNumText, p.maxNumText
+	case p.isDate:
+		c.Type, c.Min, c.Max = TypeDate, p.minDateText, p.maxDateText
+	default:
+		c.Type, c.Min, c.Max = TypeString, p.minText, p.maxText
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-ChiiCgrep/internal/scan"
+)
+
+// Join は参照用のCSVファイルから、キー列の値が一致する行の列をレコードに加える指定です。
+type Join struct {
+	// File は参照用のCSVファイルのパスです。
+	File string
+	// Key は入力ファイルと参照用のファイルの両方にある、行を対応付ける列です。
+	Key string
+}
+
+// ParseJoin は "members.csv on 社員番号" 形式の結合の指定を解析します。
+func ParseJoin(s string) (Join, error) {
+	i := strings.LastIndex(s, " on ")
+	if i < 0 {
+		return Join{}, fmt.Errorf("invalid join %q: expected \"<file> on <column>\"", s)
+	}
+	j := Join{File: strings.TrimSpace(s[:i]), Key: strings.TrimSpace(s[i+len(" on "):])}
+	if j.File == "" || j.Key == "" {
+		return Join{}, fmt.Errorf("invalid join %q: expected \"<file> on <column>\"", s)
+	}
+	return j, nil
+}
+
+// lookupTable は読み込んだ参照用のファイルです。
+type lookupTable struct {
+	// headers は加える列の名前です。キー列は含みません。
+	headers []string
+	// rows はキー列の値ごとの、headers に対応する値です。
+	rows map[string][]string
+}
+
+// loadLookup は cfg.Join の参照用のファイルを読み込みます。
+// キー列の値が重複する行は最初の行を採用し、警告を出します。
+func loadLookup(cfg Config) (*lookupTable, error) {
+	j := cfg.Join
+	f, err := os.Open(j.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open join file: %w", err)
+	}
+	defer f.Close()
+
+	reader := scan.NewReader(f, cfg.scanOptions())
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read headers of join file %s: %w", j.File, err)
+	}
+	clean := cfg.cellCleaner()
+	if clean == nil {
+		clean = func(s string) string { return s }
+	}
+	keyIdx := -1
+	var columns []int
+	t := &lookupTable{rows: make(map[string][]string)}
+	for i, h := range headers {
+		h = clean(h)
+		if h == j.Key && keyIdx < 0 {
+			keyIdx = i
+			continue
+		}
+		columns = append(columns, i)
+		t.headers = append(t.headers, h)
+	}
+	if keyIdx < 0 {
+		return nil, fmt.Errorf("join key column '%s' not found in %s", j.Key, j.File)
+	}
+
+	duplicates := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read join file %s: %w", j.File, err)
+		}
+		if keyIdx >= len(record) {
+			continue
+		}
+		key := clean(record[keyIdx])
+		if _, exists := t.rows[key]; exists {
+			duplicates++
+			continue
+		}
+		values := make([]string, len(columns))
+		for i, idx := range columns {
+			if idx < len(record) {
+				values[i] = clean(record[idx])
+			}
+		}
+		t.rows[key] = values
+	}
+	if duplicates > 0 {
+		warnf(LogKindDuplicateKey, j.File, "%d rows with duplicate keys in join file %s were ignored", duplicates, j.File)
+	}
+	return t, nil
+}
+
+// extendHeaders は headers に参照用のファイルの列を加えた見出しと、キー列の位置を返します。
+// 入力ファイルに既にある列は加えません。キー列がない場合は headers をそのまま返し、位置は -1 です。
+func (t *lookupTable) extendHeaders(headers []string, key string) ([]string, int, []int) {
+	keyIdx := -1
+	existing := make(map[string]bool, len(headers))
+	for i, h := range headers {
+		if h == key && keyIdx < 0 {
+			keyIdx = i
+		}
+		existing[h] = true
+	}
+	if keyIdx < 0 {
+		return headers, -1, nil
+	}
+	var columns []int
+	for i, h := range t.headers {
+		if !existing[h] {
+			headers = append(headers, h)
+			columns = append(columns, i)
+		}
+	}
+	return headers, keyIdx, columns
+}
+
+// isSameFile は a と b が同じファイルを指すかを返します。
+func isSameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
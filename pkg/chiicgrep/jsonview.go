@@ -0,0 +1,74 @@
+package chiicgrep
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"slices"
+	"strings"
+)
+
+// isJSONColumn は列 col が HTMLOptions.JSONColumns に含まれるかを返します。
+func (r *HTMLRenderer) isJSONColumn(col Column) bool {
+	return slices.Contains(r.opts.JSONColumns, col.Name)
+}
+
+// prettyJSON は v がJSONのオブジェクトまたは配列の場合に、インデントを付けて整形し、
+// キー、文字列、数値、真偽値と null を色分けする <span> を付けたHTMLを返します。JSONでない場合は ok が false です。
+func prettyJSON(v string) (string, bool) {
+	trimmed := strings.TrimSpace(v)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return "", false
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(trimmed), "", "  "); err != nil {
+		return "", false
+	}
+	return highlightJSON(buf.String()), true
+}
+
+// highlightJSON は整形済みのJSON s をエスケープし、トークンの種類ごとに class を付けた <span> で囲みます。
+func highlightJSON(s string) string {
+	var b strings.Builder
+	span := func(class, token string) {
+		b.WriteString("<span class=\"json-" + class + "\">" + html.EscapeString(token) + "</span>")
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			// 直後に ":" が続く文字列はオブジェクトのキー
+			class := "string"
+			if strings.HasPrefix(s[end:], ":") {
+				class = "key"
+			}
+			span(class, s[i:end])
+			i = end
+		case c == '-' || ('0' <= c && c <= '9'):
+			end := i + 1
+			for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+				end++
+			}
+			span("number", s[i:end])
+			i = end
+		case strings.HasPrefix(s[i:], "true"), strings.HasPrefix(s[i:], "null"):
+			span("literal", s[i:i+4])
+			i += 4
+		case strings.HasPrefix(s[i:], "false"):
+			span("literal", s[i:i+5])
+			i += 5
+		default:
+			b.WriteString(html.EscapeString(s[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package chiicgrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrFileLocked はほかのプロセス（Excelなど）がファイルをロックしていて開けなかったことを示します。
+// Config.Retry の回数だけ開き直しても開けなかった場合に、ファイルごとのエラーとして記録します。
+var ErrFileLocked = errors.New("file is locked by another process")
+
+// defaultRetryWait は Config.RetryWait が指定されていない場合の、最初に開き直すまでの待ち時間です。
+const defaultRetryWait = time.Second
+
+// openFile は name を開きます。ロックされていて開けない場合は、Config.Retry の回数まで待ち時間を倍にしながら開き直します。
+func (r *run) openFile(ctx context.Context, name string) (io.ReadCloser, error) {
+	wait := r.cfg.RetryWait
+	if wait <= 0 {
+		wait = defaultRetryWait
+	}
+	for attempt := 0; ; attempt++ {
+		file, err := r.src.Open(name)
+		if err == nil || !isLockedError(err) {
+			return file, err
+		}
+		if attempt >= r.cfg.Retry {
+			return nil, fmt.Errorf("%w: %w", ErrFileLocked, err)
+		}
+		warnf(LogKindFileLocked, name, "%s is locked by another process; retrying in %s (%d/%d)", name, wait, attempt+1, r.cfg.Retry)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// LockedFiles はロックされていて開けなかったファイルを、入力の順序で返します。
+func (s Summary) LockedFiles() []string {
+	var files []string
+	for _, fe := range s.Errors {
+		if errors.Is(fe.Err, ErrFileLocked) {
+			files = append(files, fe.File)
+		}
+	}
+	return files
+}
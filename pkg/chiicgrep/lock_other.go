@@ -0,0 +1,14 @@
+//go:build !windows
+
+package chiicgrep
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isLockedError は err がファイルのロックによるエラーかを返します。
+// Windows以外では、ファイルを開く際にロックで失敗することはほとんどないため、使用中を示すエラーだけを対象とします。
+func isLockedError(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ETXTBSY)
+}
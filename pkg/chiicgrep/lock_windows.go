@@ -0,0 +1,17 @@
+package chiicgrep
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windowsでほかのプロセスがファイルを開いている、またはロックしていることを示すエラーコードです。
+const (
+	errorSharingViolation = syscall.Errno(32)
+	errorLockViolation    = syscall.Errno(33)
+)
+
+// isLockedError は err がファイルのロックによるエラーかを返します。
+func isLockedError(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
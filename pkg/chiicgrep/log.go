@@ -0,0 +1,142 @@
+package chiicgrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync/atomic"
+)
+
+// LogLevel は処理中に出力するログの程度です。
+type LogLevel int32
+
+const (
+	// LogNormal はエラーと警告を出力します。
+	LogNormal LogLevel = iota
+	// LogQuiet はエラーだけを出力し、警告を出力しません。
+	LogQuiet
+	// LogVerbose はエラーと警告に加えて、見つかったファイル、除外したファイル、
+	// 列の位置の解決や規則の適用などの詳細を出力します。
+	LogVerbose
+)
+
+// ログのイベントに付ける属性のキーです。
+const (
+	LogKeyFile  = "file"
+	LogKeyLine  = "line"
+	LogKeyKind  = "kind"
+	LogKeyError = "error"
+)
+
+// ログのイベントの種類です。LogKeyKind の値として設定されます。
+const (
+	// LogKindReadError はファイルを開けない、読み込めないなどのエラーです。
+	LogKindReadError = "read_error"
+	// LogKindParseError はCSVの解析エラーです。
+	LogKindParseError = "parse_error"
+	// LogKindMissingColumn は指定された列がファイルに見つからなかったことを示します。
+	LogKindMissingColumn = "missing_column"
+	// LogKindMissingRequiredColumn は Config.RequiredColumns の列が欠けていたことを示します。
+	LogKindMissingRequiredColumn = "missing_required_column"
+	// LogKindFileSkipped は抽出する列が1つもないためにファイルを読み飛ばしたことを示します。
+	LogKindFileSkipped = "file_skipped"
+	// LogKindDuplicateKey はキー列の値が重複した行を無視したことを示します。
+	LogKindDuplicateKey = "duplicate_key"
+	// LogKindFileLocked はほかのプロセスがファイルをロックしていて開けなかったことを示します。
+	LogKindFileLocked = "file_locked"
+	// LogKindWhereError は Config.Where の条件式を行に対して評価できなかったことを示します。
+	LogKindWhereError = "where_error"
+)
+
+var (
+	// logLevel は現在のログの出力の程度です。
+	logLevel atomic.Int32
+	// logger は SetLogger で設定されたロガーです。nil の場合は標準のロガーにテキストで出力します。
+	logger atomic.Pointer[slog.Logger]
+)
+
+// SetLogLevel はパッケージ全体のログの出力の程度を設定します。
+func SetLogLevel(level LogLevel) {
+	logLevel.Store(int32(level))
+}
+
+// SetLogger はパッケージ全体のログの出力先を設定します。
+// イベントには LogKeyFile、LogKeyLine、LogKeyKind、LogKeyError の属性が、わかる範囲で付きます。
+// 出力するかどうかは SetLogLevel の設定で決まるため、l の側で絞り込む必要はありません。
+// nil を指定すると、"Warning: ..." 形式のテキストを標準のロガーに出力する既定の動作に戻ります。
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// logEvent は1件のログを出力します。
+func logEvent(level slog.Level, msg string, attrs ...slog.Attr) {
+	if l := logger.Load(); l != nil {
+		l.LogAttrs(context.Background(), level, msg, attrs...)
+		return
+	}
+	switch level {
+	case slog.LevelDebug:
+		log.Print("Debug: " + msg)
+	case slog.LevelWarn:
+		log.Print("Warning: " + msg)
+	default:
+		log.Print("Error: " + msg)
+	}
+}
+
+// eventAttrs はイベントの種類とファイルの属性を返します。空の値は省きます。
+func eventAttrs(kind, file string) []slog.Attr {
+	var attrs []slog.Attr
+	if kind != "" {
+		attrs = append(attrs, slog.String(LogKeyKind, kind))
+	}
+	if file != "" {
+		attrs = append(attrs, slog.String(LogKeyFile, file))
+	}
+	return attrs
+}
+
+// warnf は LogQuiet でない場合に、種類 kind、ファイル file の警告を出力します。
+func warnf(kind, file, format string, args ...any) {
+	if LogLevel(logLevel.Load()) != LogQuiet {
+		logEvent(slog.LevelWarn, fmt.Sprintf(format, args...), eventAttrs(kind, file)...)
+	}
+}
+
+// debugf は LogVerbose の場合に、ファイル file についての詳細を出力します。
+func debugf(file, format string, args ...any) {
+	if LogLevel(logLevel.Load()) == LogVerbose {
+		logEvent(slog.LevelDebug, fmt.Sprintf(format, args...), eventAttrs("", file)...)
+	}
+}
+
+// logFileError はファイル file の処理エラーを出力します。CSVの解析エラーの場合は行番号も付けます。
+func logFileError(file string, err error) {
+	kind := LogKindReadError
+	var pErr *parseError
+	if errors.As(err, &pErr) {
+		kind = LogKindParseError
+	} else if errors.Is(err, ErrFileLocked) {
+		kind = LogKindFileLocked
+	}
+	attrs := eventAttrs(kind, file)
+	if pErr != nil {
+		attrs = append(attrs, slog.Int(LogKeyLine, pErr.line))
+	}
+	attrs = append(attrs, slog.String(LogKeyError, err.Error()))
+	logEvent(slog.LevelError, fmt.Sprintf("could not process %s: %v", file, err), attrs...)
+}
+
+// parseError はCSVの解析エラーと、その位置です。
+type parseError struct {
+	line, column int
+	err          error
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("parse error at line %d, column %d: %v", e.line, e.column, e.err)
+}
+
+func (e *parseError) Unwrap() error { return e.err }
@@ -0,0 +1,101 @@
+package chiicgrep
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaskMethod は値を伏せる方法です。
+type MaskMethod string
+
+const (
+	// MaskRedact は値全体を "****" に置き換えます。
+	MaskRedact MaskMethod = "redact"
+	// MaskHash は値をハッシュ値（16進数16桁）に置き換えます。同じ値は同じハッシュ値になるため、件数の集計や突き合わせに使えます。
+	MaskHash MaskMethod = "hash"
+	// MaskLast は末尾の Keep 文字を残し、それ以外を "*" に置き換えます。
+	MaskLast MaskMethod = "last"
+	// MaskFirst は先頭の Keep 文字を残し、それ以外を "*" に置き換えます。
+	MaskFirst MaskMethod = "first"
+)
+
+// redactedValue は MaskRedact で置き換えた値です。
+const redactedValue = "****"
+
+// Mask はレポートに書き込む前に列の値を伏せる指定です。
+type Mask struct {
+	Column string
+	Method MaskMethod
+	// Keep は MaskLast と MaskFirst で残す文字数です。
+	Keep int
+}
+
+// ParseMask は "列名:方法" 形式の指定を解析します。方法は redact、hash、lastN、firstN（N は残す文字数）のいずれかです。
+func ParseMask(s string) (Mask, error) {
+	column, method, found := strings.Cut(s, ":")
+	m := Mask{Column: strings.TrimSpace(column)}
+	if m.Column == "" || !found {
+		return Mask{}, fmt.Errorf("invalid mask %q: expected column:method", s)
+	}
+	method = strings.ToLower(strings.TrimSpace(method))
+	switch MaskMethod(method) {
+	case MaskRedact, MaskHash:
+		m.Method = MaskMethod(method)
+		return m, nil
+	}
+	for _, prefix := range []MaskMethod{MaskLast, MaskFirst} {
+		if n, ok := strings.CutPrefix(method, string(prefix)); ok {
+			keep, err := strconv.Atoi(n)
+			if err != nil || keep <= 0 {
+				return Mask{}, fmt.Errorf("invalid mask %q: %s must be followed by a positive number of characters to keep", s, prefix)
+			}
+			m.Method, m.Keep = prefix, keep
+			return m, nil
+		}
+	}
+	return Mask{}, fmt.Errorf("invalid mask %q: unknown method %q (expected redact, hash, lastN or firstN)", s, method)
+}
+
+// String は ParseMask で解析できる形式で m を返します。
+func (m Mask) String() string {
+	if m.Method == MaskLast || m.Method == MaskFirst {
+		return fmt.Sprintf("%s:%s%d", m.Column, m.Method, m.Keep)
+	}
+	return m.Column + ":" + string(m.Method)
+}
+
+// apply は v を伏せた値を返します。key が空でない場合、MaskHash は key を鍵とするHMAC-SHA256を使います。
+// 空の値は伏せずにそのまま返します。
+func (m Mask) apply(v, key string) string {
+	if v == "" {
+		return v
+	}
+	switch m.Method {
+	case MaskHash:
+		var sum []byte
+		if key != "" {
+			h := hmac.New(sha256.New, []byte(key))
+			h.Write([]byte(v))
+			sum = h.Sum(nil)
+		} else {
+			s := sha256.Sum256([]byte(v))
+			sum = s[:]
+		}
+		return hex.EncodeToString(sum[:8])
+	case MaskLast, MaskFirst:
+		runes := []rune(v)
+		if len(runes) <= m.Keep {
+			return strings.Repeat("*", len(runes))
+		}
+		hidden := strings.Repeat("*", len(runes)-m.Keep)
+		if m.Method == MaskLast {
+			return hidden + string(runes[len(runes)-m.Keep:])
+		}
+		return string(runes[:m.Keep]) + hidden
+	}
+	return redactedValue
+}
@@ -0,0 +1,46 @@
+package chiicgrep
+
+import (
+	"html"
+	"time"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// writeMetadata はレポートの末尾に、作成日時、生成したプログラムのバージョン、コマンドラインとオプション、
+// 処理時間、入力ファイルごとの読み込んだ行数と一致した行数を「レポートの情報」として出力します。
+// 後からレポートを見たときに、どのように作られたかを確認し、同じ条件で作り直せるようにするためのものです。
+func (r *HTMLRenderer) writeMetadata(sw *render.Writer, sum Summary) {
+	if r.opts.PrintLayout {
+		// 印刷したレポートでも作成の条件を確認できるよう、開いた状態で出力する
+		sw.WriteString("<details class=\"metadata\" open>\n<summary>レポートの情報")
+	} else {
+		sw.WriteString("<details class=\"metadata\">\n<summary>レポートの情報")
+	}
+	if r.opts.Generator != "" {
+		// バージョンは問い合わせの際にすぐ確認できるよう、閉じた状態でも表示する
+		sw.Printf(" <span class=\"generator\">Generated by %s</span>", html.EscapeString(r.opts.Generator))
+	}
+	sw.WriteString("</summary>\n<table>\n")
+	sw.Printf("<tr><th>作成日時</th><td>%s</td></tr>\n", time.Now().Format("2006-01-02 15:04:05 MST"))
+	if r.opts.CommandLine != "" {
+		sw.Printf("<tr><th>コマンドライン</th><td><code>%s</code></td></tr>\n", html.EscapeString(r.opts.CommandLine))
+	}
+	if len(r.opts.Options) > 0 {
+		sw.WriteString("<tr><th>オプション</th><td>")
+		for _, o := range r.opts.Options {
+			sw.Printf("<div><code>-%s=%s</code></div>", html.EscapeString(o[0]), html.EscapeString(o[1]))
+		}
+		sw.WriteString("</td></tr>\n")
+	}
+	sw.Printf("<tr><th>処理時間</th><td>%s</td></tr>\n", sum.Elapsed.Round(time.Millisecond))
+	sw.WriteString("</table>\n")
+	if len(sum.Files) > 0 {
+		sw.WriteString("<table>\n<thead><tr><th>入力ファイル</th><th>読み込んだ行</th><th>一致した行</th></tr></thead>\n")
+		for _, f := range sum.Files {
+			sw.Printf("<tr><td>%s</td><td class=\"number\">%d</td><td class=\"number\">%d</td></tr>\n", html.EscapeString(f.File), f.Rows, f.Matches)
+		}
+		sw.WriteString("</table>\n")
+	}
+	sw.WriteString("</details>\n")
+}
@@ -0,0 +1,48 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization は照合の前に値へ適用するUnicode正規化の種類です。
+// 正規化は検索文字列や条件との比較にのみ使われ、出力される値は元のままです。
+type Normalization string
+
+const (
+	// NormalizeNone は正規化を行いません。
+	NormalizeNone Normalization = ""
+	// NormalizeNFKC はNFKC正規化を行います。全角英数字は半角に、半角カタカナは全角にそろえられます。
+	NormalizeNFKC Normalization = "nfkc"
+)
+
+// ParseNormalization は -normalize などで指定された名前を Normalization に変換します。
+func ParseNormalization(s string) (Normalization, error) {
+	switch n := Normalization(s); n {
+	case NormalizeNone, NormalizeNFKC:
+		return n, nil
+	}
+	return NormalizeNone, fmt.Errorf("unknown normalization %q (expected \"nfkc\")", s)
+}
+
+// normalizer は n に対応する変換関数を返します。正規化を行わない場合は nil を返します。
+func (n Normalization) normalizer() func(string) string {
+	if n == NormalizeNFKC {
+		return norm.NFKC.String
+	}
+	return nil
+}
+
+// cellCleaner は Config.TrimCells と Config.CollapseSpaces に対応する、セルの空白を整える関数を返します。
+// どちらも指定されていない場合は nil を返します。
+func (cfg Config) cellCleaner() func(string) string {
+	switch {
+	case cfg.CollapseSpaces:
+		return func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	case cfg.TrimCells:
+		return strings.TrimSpace
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package chiicgrep
+
+import (
+	"os"
+	"strings"
+)
+
+// Option は New で作成する Processor の設定です。
+type Option func(*Processor)
+
+// New は opts を順に適用した Processor を作成します。コマンドラインの引数を組み立てずに、Goのプログラムから抽出を設定するために使います。
+// WithRenderer を指定しない場合、結果は色付きのテキストとして標準出力に書き込まれます。
+//
+//	p := chiicgrep.New(
+//		chiicgrep.WithInput("data", true),
+//		chiicgrep.WithColumns("氏名", "備考:メモ"),
+//		chiicgrep.WithTarget("重要"),
+//	)
+//	if err := p.AddHighlightRule("ステータス=保留"); err != nil {
+//		return err
+//	}
+//	err := p.Run(ctx)
+func New(opts ...Option) *Processor {
+	p := &Processor{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.renderer == nil {
+		p.renderer = NewTextRenderer(os.Stdout, TextOptions{})
+	}
+	return p
+}
+
+// WithConfig は cfg を設定の初期値とします。他の Option より先に指定してください。
+func WithConfig(cfg Config) Option {
+	return func(p *Processor) { p.cfg = cfg }
+}
+
+// WithInput は入力のファイルまたはフォルダと、サブフォルダも検索するかを設定します（-in と -r）。
+func WithInput(path string, recursive bool) Option {
+	return func(p *Processor) {
+		p.cfg.InputPath = path
+		p.cfg.Recursive = recursive
+	}
+}
+
+// WithColumns は抽出する列を設定します（-cols）。各要素は "列名" または "列名:表示名" の形式です。
+func WithColumns(columns ...string) Option {
+	return func(p *Processor) { p.SetColumns(columns...) }
+}
+
+// WithTarget は行を絞り込む検索文字列を設定します（-target）。
+func WithTarget(target string) Option {
+	return func(p *Processor) { p.cfg.SearchTarget = target }
+}
+
+// WithHighlightRules は強調表示の規則を加えます（-highlight-if）。
+func WithHighlightRules(rules ...Condition) Option {
+	return func(p *Processor) { p.cfg.HighlightRules = append(p.cfg.HighlightRules, rules...) }
+}
+
+// WithTagRules はファイルにタグを付ける規則を加えます（-tag-file、-tag-dir）。
+func WithTagRules(rules ...TagRule) Option {
+	return func(p *Processor) { p.cfg.TagRules = append(p.cfg.TagRules, rules...) }
+}
+
+// WithJobs は並行して処理するファイル数の上限を設定します（-jobs）。
+func WithJobs(n int) Option {
+	return func(p *Processor) { p.cfg.Jobs = n }
+}
+
+// WithRenderer は結果の出力先を設定します。
+func WithRenderer(r Renderer) Option {
+	return func(p *Processor) { p.renderer = r }
+}
+
+// SetColumns は抽出する列を置き換えます（-cols）。各要素は "列名" または "列名:表示名" の形式です。
+func (p *Processor) SetColumns(columns ...string) {
+	p.cfg.Columns = make([]Column, 0, len(columns))
+	for _, c := range columns {
+		name, label, found := strings.Cut(c, ":")
+		if !found || label == "" {
+			label = name
+		}
+		p.cfg.Columns = append(p.cfg.Columns, Column{Name: name, Label: label})
+	}
+}
+
+// AddHighlightRule は "列名 演算子 値" 形式の強調表示の規則を加えます（-highlight-if）。
+func (p *Processor) AddHighlightRule(expr string) error {
+	c, err := ParseCondition(expr)
+	if err != nil {
+		return err
+	}
+	p.cfg.HighlightRules = append(p.cfg.HighlightRules, c)
+	return nil
+}
+
+// AddTagRule は "タグ:キーワード" または "タグ:/正規表現/" 形式のタグ付け規則を加えます（-tag-file）。
+func (p *Processor) AddTagRule(expr string) error {
+	rule, err := ParseTagRule(expr)
+	if err != nil {
+		return err
+	}
+	p.cfg.TagRules = append(p.cfg.TagRules, rule)
+	return nil
+}
+
+// Config は Processor の現在の設定を返します。
+func (p *Processor) Config() Config {
+	return p.cfg
+}
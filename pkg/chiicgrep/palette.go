@@ -0,0 +1,94 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Palette はHTMLレポートの強調表示とタグの配色です。
+type Palette string
+
+const (
+	// PaletteDefault は既定の配色です。
+	PaletteDefault Palette = "default"
+	// PaletteColorblind は色覚の多様性に配慮した配色です。赤と緑の組み合わせを避けた色（Okabe-Ito の配色）に変え、
+	// 強調表示したレコードやセル、組み込みのタグに、色以外でも区別できる記号と線の種類を加えます。
+	PaletteColorblind Palette = "colorblind"
+)
+
+// ParsePalette は配色の名前を解析します。空文字列は PaletteDefault です。
+func ParsePalette(s string) (Palette, error) {
+	switch p := Palette(strings.ToLower(strings.TrimSpace(s))); p {
+	case "", PaletteDefault:
+		return PaletteDefault, nil
+	case PaletteColorblind:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown palette %q (expected %s, %s)", s, PaletteDefault, PaletteColorblind)
+}
+
+// colorblindTheme は PaletteColorblind で ThemeVariables の既定値を置き換える色です。
+var colorblindTheme = map[string]string{
+	"value":            "#0072b2",
+	"highlight":        "#f0e442",
+	"highlight-border": "#e69f00",
+	"error":            "#d55e00",
+	"bar":              "#56b4e9",
+}
+
+// colorblindTags は PaletteColorblind で組み込みのタグ（DefaultTags）の色を置き換える色です。
+var colorblindTags = map[string]string{
+	"important": "#d55e00",
+	"warning":   "#e69f00",
+	"archived":  "#666666",
+	"completed": "#0072b2",
+}
+
+// colorblindCSS は PaletteColorblind で加える、色以外で区別するためのCSSです。
+const colorblindCSS = `.record.highlighted { border-left-style: double; border-left-width: 6px; }
+.record.highlighted .record-info::before { content: "★ "; color: var(--text); }
+.value.highlight { outline: 2px dashed var(--highlight-border); text-decoration: underline; }
+.geo-point.highlighted { stroke: #000; stroke-width: 2; }
+.gantt-bar.highlighted { stroke: #000; stroke-width: 1.5; stroke-dasharray: 4 2; }
+.errors { border-style: dashed; border-width: 2px; }
+.tag-important::before { content: "! "; }
+.tag-important { border: 2px solid #000; }
+.tag-warning::before { content: "▲ "; }
+.tag-warning { color: #000; border: 2px dashed #000; }
+.tag-archived::before { content: "■ "; }
+.tag-archived { border: 2px dotted #000; }
+.tag-completed::before { content: "✔ "; }
+`
+
+// theme は配色による ThemeVariables の既定値の置き換えを返します。
+func (p Palette) theme() map[string]string {
+	if p == PaletteColorblind {
+		return colorblindTheme
+	}
+	return nil
+}
+
+// tagDefs は defs のうち、-define-tag で色を変えていない組み込みのタグの色を配色に合わせて置き換えます。
+func (p Palette) tagDefs(defs []TagDef) []TagDef {
+	if p != PaletteColorblind {
+		return defs
+	}
+	result := make([]TagDef, len(defs))
+	for i, d := range defs {
+		result[i] = d
+		for _, builtin := range DefaultTags {
+			if d == builtin {
+				result[i].Color = colorblindTags[d.Name]
+			}
+		}
+	}
+	return result
+}
+
+// css は配色に応じて加えるCSSを返します。
+func (p Palette) css() string {
+	if p == PaletteColorblind {
+		return colorblindCSS
+	}
+	return ""
+}
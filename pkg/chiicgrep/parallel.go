@@ -0,0 +1,75 @@
+package chiicgrep
+
+import (
+	"context"
+	"errors"
+)
+
+// fileResult は1ファイル分の処理結果です。
+type fileResult struct {
+	records []Record
+	err     error
+}
+
+// processFilesParallel は最大 jobs 個のファイルを並行して処理します。
+// 各ファイルのレコードはファイル単位でバッファされ、files の順序どおりに fn へ渡されるため、
+// 出力はファイルごとにまとまった決定的な順序になります。
+// 処理中またはバッファ済みのファイルは常に jobs 個以下に抑えられます。
+func (r *run) processFilesParallel(ctx context.Context, jobs int, fn func(Record) error) error {
+	files := r.files
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan fileResult, len(files))
+	for i := range results {
+		results[i] = make(chan fileResult, 1)
+	}
+	window := make(chan struct{}, jobs)
+
+	go func() {
+		for i, file := range files {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, file string) {
+				var res fileResult
+				res.err = r.processFile(ctx, file, func(rec Record) error {
+					res.records = append(res.records, rec)
+					return nil
+				})
+				results[i] <- res
+			}(i, file)
+		}
+	}()
+
+	for i, file := range files {
+		var res fileResult
+		select {
+		case res = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-window
+
+		for _, rec := range res.records {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		if errors.Is(res.err, errFileLimit) {
+			r.addTruncatedFile(file)
+			continue
+		}
+		if res.err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err := r.recordFileError(file, res.err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Pivot は出力したレコードを行と列の2つの列の値で分類するクロス集計の指定です。
+type Pivot struct {
+	// Rows は表の行の見出しにする列です。
+	Rows string
+	// Cols は表の列の見出しにする列です。
+	Cols string
+	// Value は集計する数値の列です。Func が AggCount の場合は省略できます。
+	Value string
+	// Func は集計関数です。AggCount はレコード数を数えます。
+	Func AggregateFunc
+}
+
+// ParsePivot は "rows=部署 cols=月 value=金額 agg=sum" 形式のクロス集計の指定を解析します。
+// 各項目は空白またはカンマで区切ります。agg を省略した場合、value があれば sum、なければ count を集計します。
+func ParsePivot(s string) (Pivot, error) {
+	var p Pivot
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		key, value, found := strings.Cut(item, "=")
+		if !found || value == "" {
+			return Pivot{}, fmt.Errorf("invalid pivot item %q: expected key=value", item)
+		}
+		switch strings.ToLower(key) {
+		case "rows":
+			p.Rows = value
+		case "cols":
+			p.Cols = value
+		case "value":
+			p.Value = value
+		case "agg":
+			p.Func = AggregateFunc(strings.ToLower(value))
+			switch p.Func {
+			case AggSum, AggAvg, AggMin, AggMax, AggCount:
+			default:
+				return Pivot{}, fmt.Errorf("invalid pivot item %q: unknown function (expected sum, avg, min, max or count)", item)
+			}
+		default:
+			return Pivot{}, fmt.Errorf("invalid pivot item %q: unknown key (expected rows, cols, value or agg)", item)
+		}
+	}
+	if p.Rows == "" || p.Cols == "" {
+		return Pivot{}, fmt.Errorf("invalid pivot %q: rows and cols are required", s)
+	}
+	if p.Func == "" {
+		p.Func = AggCount
+		if p.Value != "" {
+			p.Func = AggSum
+		}
+	}
+	if p.Func != AggCount && p.Value == "" {
+		return Pivot{}, fmt.Errorf("invalid pivot %q: agg=%s requires value", s, p.Func)
+	}
+	return p, nil
+}
+
+// PivotCell はクロス集計の1つのセルです。
+type PivotCell struct {
+	// Records はセルに分類されたレコードの数です。
+	Records int
+	// Values は Pivot.Value の列の集計です。
+	Values AggregateValues
+}
+
+// Format は関数 f の集計結果を文字列で返します。AggCount の場合はレコード数を返します。
+func (c PivotCell) Format(f AggregateFunc) string {
+	if f == AggCount {
+		return strconv.Itoa(c.Records)
+	}
+	return c.Values.Format(f)
+}
+
+// merge は o の集計を c に加えます。
+func (c *PivotCell) merge(o PivotCell) {
+	c.Records += o.Records
+	c.Values.Merge(o.Values)
+}
+
+// PivotResult は Config.Pivot の集計結果です。
+// 見出しは、値がすべて数値なら数値の順、すべて日付なら日付の順、それ以外は文字列の順に並びます。
+type PivotResult struct {
+	Pivot
+	RowKeys []string
+	ColKeys []string
+	// Cells は RowKeys と ColKeys の順に並んだセルです（Cells[行][列]）。
+	Cells     [][]PivotCell
+	RowTotals []PivotCell
+	ColTotals []PivotCell
+	Total     PivotCell
+}
+
+// pivotKey はクロス集計のセルを特定する行と列の値です。
+type pivotKey struct {
+	row, col string
+}
+
+// countPivot は Config.Pivot が指定されている場合に、fn に渡すレコードをクロス集計するよう fn を包みます。
+// 行または列の見出しにする列がないファイルのレコードは集計しません。
+func (r *run) countPivot(fn func(Record) error) func(Record) error {
+	if r.cfg.Pivot == nil {
+		return fn
+	}
+	r.pivotCells = make(map[pivotKey]*PivotCell)
+	return func(rec Record) error {
+		if rec.pivotValues != nil {
+			key := pivotKey{row: rec.pivotValues[0], col: rec.pivotValues[1]}
+			r.mu.Lock()
+			cell, ok := r.pivotCells[key]
+			if !ok {
+				cell = &PivotCell{}
+				r.pivotCells[key] = cell
+			}
+			cell.Records++
+			cell.Values.add(rec.pivotValues[2])
+			r.mu.Unlock()
+		}
+		return fn(rec)
+	}
+}
+
+// pivotResult はクロス集計の結果を返します。r.mu を保持した状態で呼び出します。
+func (r *run) pivotResult() *PivotResult {
+	if r.cfg.Pivot == nil {
+		return nil
+	}
+	res := &PivotResult{Pivot: *r.cfg.Pivot}
+	rowSet, colSet := make(map[string]bool), make(map[string]bool)
+	for key := range r.pivotCells {
+		if !rowSet[key.row] {
+			rowSet[key.row] = true
+			res.RowKeys = append(res.RowKeys, key.row)
+		}
+		if !colSet[key.col] {
+			colSet[key.col] = true
+			res.ColKeys = append(res.ColKeys, key.col)
+		}
+	}
+	sortPivotKeys(res.RowKeys)
+	sortPivotKeys(res.ColKeys)
+
+	res.Cells = make([][]PivotCell, len(res.RowKeys))
+	res.RowTotals = make([]PivotCell, len(res.RowKeys))
+	res.ColTotals = make([]PivotCell, len(res.ColKeys))
+	for i, row := range res.RowKeys {
+		res.Cells[i] = make([]PivotCell, len(res.ColKeys))
+		for j, col := range res.ColKeys {
+			cell, ok := r.pivotCells[pivotKey{row: row, col: col}]
+			if !ok {
+				continue
+			}
+			res.Cells[i][j] = *cell
+			res.RowTotals[i].merge(*cell)
+			res.ColTotals[j].merge(*cell)
+			res.Total.merge(*cell)
+		}
+	}
+	return res
+}
+
+// sortPivotKeys はクロス集計の見出しを並べ替えます。比較方法は detectSortType で決めます。
+func sortPivotKeys(keys []string) {
+	typ := detectSortType(keys)
+	slices.SortStableFunc(keys, func(a, b string) int {
+		if c := compareSortValues(a, b, typ); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+}
@@ -0,0 +1,72 @@
+package chiicgrep
+
+import (
+	"context"
+
+	"go-ChiiCgrep/internal/scan"
+)
+
+// FilePlan は1つの入力を処理した場合に、どの列が使われるかの見込みです。
+type FilePlan struct {
+	File string
+	// TagRules は Config.TagRules のうち、このファイルに該当する規則です。
+	TagRules []TagRule
+	// Found と Missing は Config.Columns のうち、ヘッダーにあった列とない列の名前です。
+	Found   []string
+	Missing []string
+	// MissingRequired は Config.RequiredColumns のうち、ヘッダーにない列です。
+	MissingRequired []string
+	// Err はヘッダーを読み込めなかった場合のエラーです。
+	Err error
+}
+
+// Skipped は抽出する列が1つもないためにファイルが読み飛ばされるかを返します。
+func (p FilePlan) Skipped() bool {
+	return p.Err == nil && len(p.Found) == 0
+}
+
+// PlanFiles は cfg の入力を列挙してヘッダー行だけを読み込み、各ファイルで列がどのように解決されるかを返します。
+// データ行は読み込まず、何も出力しません。入力が1つもない場合は ErrNoCSVFiles を返します。
+func PlanFiles(ctx context.Context, cfg Config) ([]FilePlan, error) {
+	r, err := newRun(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]FilePlan, 0, len(r.files))
+	for _, name := range r.files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		plan := FilePlan{File: name}
+		for _, rule := range cfg.TagRules {
+			if rule.matchFile(name) {
+				plan.TagRules = append(plan.TagRules, rule)
+			}
+		}
+		headers, err := readHeader(r.src, name, cfg)
+		if err != nil {
+			plan.Err = err
+			plans = append(plans, plan)
+			continue
+		}
+		if r.lookup != nil {
+			headers, _, _ = r.lookup.extendHeaders(headers, cfg.Join.Key)
+		}
+		headerMap := scan.HeaderIndex(headers)
+		cfg.addAliases(headerMap, name)
+		for _, col := range cfg.Columns {
+			if _, ok := headerMap[col.Name]; ok {
+				plan.Found = append(plan.Found, col.Name)
+			} else {
+				plan.Missing = append(plan.Missing, col.Name)
+			}
+		}
+		for _, col := range cfg.RequiredColumns {
+			if _, ok := headerMap[col]; !ok {
+				plan.MissingRequired = append(plan.MissingRequired, col)
+			}
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
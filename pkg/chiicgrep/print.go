@@ -0,0 +1,40 @@
+package chiicgrep
+
+// printCSS は印刷する際のスタイルシートです。画面でだけ使うボタンなどを隠し、固定表示の要素を本文の流れに戻し、
+// 表の見出しの行（thead）をページごとに繰り返し、レコードや表の行がページの境目で分かれないようにします。
+const printCSS = `@media print {
+.toolbar, .legend input, .permalink, .timeline-nav { display: none; }
+.legend, .interrupted, .day-heading { position: static; box-shadow: none; }
+body:has(.interrupted) { padding-top: 0; }
+.timeline { margin-left: 0; }
+thead { display: table-header-group; }
+tr, .record, .summary, .geo-map, .gantt-chart { page-break-inside: avoid; break-inside: avoid; }
+.file-info, .day-heading, .summary-info { page-break-after: avoid; break-after: avoid; }
+a { color: inherit; text-decoration: none; }
+}
+`
+
+// printLayoutCSS は HTMLOptions.PrintLayout の場合に加える、A4用紙に印刷するためのスタイルシートです。
+// ファイルごとのセクションの前で改ページし、背景色を印刷し、長い値やJSONをスクロールさせずにすべて印刷します。
+const printLayoutCSS = `@page { size: A4 portrait; margin: 15mm 12mm 18mm; @bottom-center { content: counter(page) " / " counter(pages); font-size: 9pt; } }
+@media print {
+body { margin: 0; font-size: 9.5pt; background: #fff; -webkit-print-color-adjust: exact; print-color-adjust: exact; }
+h1 { font-size: 14pt; }
+.file ~ .file { page-break-before: always; break-before: page; }
+.record { border-color: #999; margin: 0.3em 0; }
+.value, .error, .metadata code { word-break: break-all; }
+pre.json { max-height: none; overflow: visible; white-space: pre-wrap; }
+.dashboard { display: block; }
+.panel { overflow: visible; margin-bottom: 1em; page-break-inside: avoid; break-inside: avoid; }
+.panel-records { page-break-inside: auto; break-inside: auto; }
+.thumb { max-width: 45mm; max-height: 35mm; }
+}
+`
+
+// printStyles は印刷用のスタイルシートを返します。
+func (r *HTMLRenderer) printStyles() string {
+	if r.opts.PrintLayout {
+		return printCSS + printLayoutCSS
+	}
+	return printCSS
+}
@@ -0,0 +1,268 @@
+package chiicgrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNoCSVFiles は入力パスにCSVファイルが1つも見つからなかったことを示します。
+	ErrNoCSVFiles = errors.New("no CSV files found")
+	// ErrStrict は Config.Strict によりファイルの読み込みエラーで処理を中止したことを示します。
+	ErrStrict = errors.New("aborted in strict mode")
+)
+
+// Field は抽出された1セル分の値です。
+type Field struct {
+	Column Column
+	Value  string
+	// Highlighted はこのセルの列に対する強調表示規則が成立したことを示します。
+	Highlighted bool
+	// Code は Config.ValueMaps により Value を置き換えた場合の、元のコードです。置き換えていない場合は空です。
+	Code string
+	// Violations はこのセルの値が違反した Config.Validations のルール（"列:種類:引数" 形式）です。
+	Violations []string
+}
+
+// Record は条件に一致した1行分の抽出結果です。
+type Record struct {
+	File   string
+	Line   int
+	Fields []Field
+	// Highlighted はいずれかの強調表示規則が成立したことを示します。
+	Highlighted bool
+	// Tags はこのレコードのファイルに Config.TagRules で付いたタグです。同じファイルのレコード間で共有されます。
+	Tags []string
+	// RowTags はこのレコード自体に Config.RowTagRules で付いたタグです。
+	RowTags []string
+	// DuplicateFiles は Config.DedupFiles により、このレコードのファイルと内容が同じため処理を省略したファイルです。
+	// 同じファイルのレコード間で共有されます。
+	DuplicateFiles []string
+	// Context は Config.Context により、一致した行の前後の行として出力したレコードであることを示します。
+	// 前後の行は強調表示、タグ付け、重複の除外、件数の上限と集計の対象になりません。
+	Context bool
+	// New は RecordState.Renderer により、前回の実行で出力しなかったレコードであることを示します。
+	New bool
+	// Date は Config.Timeline の列の値の日付の部分（"2006-01-02" 形式）です。日付として解釈できない場合は空です。
+	Date string
+	// Location は Config.Geo の列の緯度と経度です。解釈できない場合は nil です。
+	Location *GeoPoint
+	// Span は Config.Gantt の列の期間です。解釈できない場合は nil です。
+	Span *GanttSpan
+	// Invalid は Config.Validations のいずれかのルールに違反したことを示します。出力しない列の違反も含みます。
+	Invalid bool
+	// DuplicateOf は Config.UniqueKey の値が、先に出力したレコードと重複した場合に、最初のレコードの位置を示します。
+	DuplicateOf *KeyLocation
+
+	// sortValues は Config.Sort のキーの値です。出力する列に含まれないキーも保持します。
+	sortValues []string
+	// dedupKey は Config.DedupBy の列の値から作った重複判定のキーです。
+	dedupKey string
+	// aggregateValues は Config.Aggregates の列の値です。
+	aggregateValues []string
+	// topValues は Config.TopValues の列の値です。
+	topValues []string
+	// pivotValues は Config.Pivot の行、列、値の列の値です。
+	pivotValues []string
+	// violations は Config.Validations のうち違反したルールと、その値です。
+	violations []recordViolation
+	// uniqueKey は Config.UniqueKey の列の値を連結したキーです。
+	uniqueKey string
+}
+
+// FileError は読み込みに失敗したファイルとそのエラーです。
+type FileError struct {
+	File string
+	Err  error
+}
+
+func (e FileError) Error() string { return e.File + ": " + e.Err.Error() }
+
+// SchemaViolation は Config.RequiredColumns の列が欠けていたファイルです。
+type SchemaViolation struct {
+	File    string
+	Missing []string
+}
+
+// FileStats は1ファイル分の処理の件数です。
+type FileStats struct {
+	File string
+	// Rows は読み込んだデータ行の数です。
+	Rows int64
+	// Matches は出力したレコードの数です。Processor.Run の場合のみ設定されます。
+	Matches int
+}
+
+// Summary は処理全体の結果を表し、Renderer.End に渡されます。
+type Summary struct {
+	// FilesScanned は処理したファイルの数です。
+	FilesScanned int
+	// FilesTotal は処理の対象として見つかったファイルの数です。中断した場合は FilesScanned より多くなります。
+	FilesTotal int
+	// FilesWithMatches はレコードを1件以上出力したファイルの数です。
+	FilesWithMatches int
+	// RowsScanned は読み込んだデータ行の数です。
+	RowsScanned int64
+	// Matches は出力したレコード数です。
+	Matches int
+	// ColumnWarnings は指定された列（抽出列や強調表示規則の列）がファイルに見つからなかった件数です。
+	ColumnWarnings int
+	// Elapsed は処理にかかった時間です。
+	Elapsed time.Duration
+	// Errors は読み込みに失敗したファイルです。CSVの解析エラーを含み、入力の順序で並びます。
+	Errors []FileError
+	// SchemaViolations は Config.RequiredColumns の列が欠けていたファイルです。入力の順序で並びます。
+	SchemaViolations []SchemaViolation
+	// Files は処理を終えたファイルごとの件数です。入力の順序で並びます。
+	Files []FileStats
+
+	// Interrupted はキャンセルやタイムアウトにより処理が途中で打ち切られたことを示します。
+	Interrupted bool
+	// Aborted は Config.Strict によりファイルの読み込みエラーで処理を中止したことを示します。
+	Aborted bool
+
+	// Duplicates は Config.Dedup により除外した重複レコードの数です。
+	Duplicates int
+	// Sample は Config.Sample の設定です。乱数の種を指定しなかった場合は、実際に使った種が設定されます。
+	Sample *Sample
+	// Geo は Config.Geo の列です。HTMLレポートはこの列の位置を地図に描きます。
+	Geo *GeoColumns
+	// Gantt は Config.Gantt の列です。HTMLレポートはこの列の期間を工程表に描きます。
+	Gantt *GanttColumns
+	// DuplicateFiles は Config.DedupFiles により、ほかのファイルと内容が同じため処理を省略したファイルの数です。
+	DuplicateFiles int
+
+	// Aggregates は Config.Aggregates の集計結果です。Config.Aggregates と同じ順序で並びます。
+	Aggregates []AggregateResult
+	// TopValues は Config.TopValues の集計結果です。Config.TopValues と同じ順序で並びます。
+	TopValues []TopValuesResult
+	// Pivot は Config.Pivot のクロス集計の結果です。
+	Pivot *PivotResult
+	// Validations は Config.Validations の検証の結果です。Config.Validations と同じ順序で並びます。
+	Validations []ValidationResult
+	// UniqueKey は Config.UniqueKey の列です。
+	UniqueKey []string
+	// KeyConflicts は Config.UniqueKey の値が重複したレコードの一覧です。
+	KeyConflicts []KeyConflict
+
+	// JoinMisses は Config.Join の参照用のファイルにキーが見つからなかった一致行の数です。
+	JoinMisses int
+
+	// ResultLimit は出力件数が Config.MaxResults に達して打ち切った場合に、その上限値が設定されます。
+	ResultLimit int
+	// TruncatedFiles は Config.MaxPerFile に達して途中で打ち切ったファイルです。
+	TruncatedFiles []string
+	// PerFileLimit は TruncatedFiles を打ち切った上限値です。
+	PerFileLimit int
+}
+
+// Truncated は件数の上限により結果が打ち切られたかを返します。
+func (s Summary) Truncated() bool {
+	return s.ResultLimit > 0 || len(s.TruncatedFiles) > 0
+}
+
+// Processor は設定に従ってCSVファイルを処理し、結果を Renderer に渡します。
+type Processor struct {
+	cfg      Config
+	renderer Renderer
+	summary  Summary
+}
+
+// NewProcessor は新しい Processor を作成します。
+func NewProcessor(cfg Config, renderer Renderer) *Processor {
+	return &Processor{cfg: cfg, renderer: renderer}
+}
+
+// Run は入力パスからCSVファイルを検索し、すべてのファイルを処理します。
+// 個々のファイルの処理エラーはログに記録され、残りのファイルの処理は継続されます。
+// ctx がキャンセルされた場合や Config.Strict で処理を中止した場合は、それまでの結果でレポートを閉じてからエラーを返します。
+func (p *Processor) Run(ctx context.Context) error {
+	r, err := newRun(ctx, p.cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := p.renderer.Begin(); err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	matches := 0
+	// 並べ替えやタイムライン表示ではレコードがファイルごとにまとまらないため、ファイル名で数える
+	matchedFiles := make(map[string]int)
+	err = r.processFiles(ctx, func(rec Record) error {
+		if err := p.renderer.Render(rec); err != nil {
+			return fmt.Errorf("failed to write to output: %w", err)
+		}
+		if rec.Context {
+			return nil
+		}
+		matches++
+		matchedFiles[rec.File]++
+		return nil
+	})
+	interrupted := err != nil && ctx.Err() != nil
+	aborted := errors.Is(err, ErrStrict)
+	sum := r.summary()
+	sum.Matches = matches
+	sum.FilesWithMatches = len(matchedFiles)
+	for i := range sum.Files {
+		sum.Files[i].Matches = matchedFiles[sum.Files[i].File]
+	}
+	sum.Interrupted = interrupted
+	sum.Aborted = aborted
+	p.summary = sum
+	if err != nil && !interrupted && !aborted {
+		return err
+	}
+	if err := p.renderer.End(sum); err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	return err
+}
+
+// Summary は直前の Run の結果を返します。
+func (p *Processor) Summary() Summary {
+	return p.summary
+}
+
+// Process は入力パスからCSVファイルを検索し、条件に一致したレコードごとに fn を呼び出します。
+// HTMLなどを生成せずに結果を独自の出力先へ逐次渡せるため、レコード数によらずメモリ使用量は一定です。
+// ただし cfg.Jobs が2以上の場合は、順序を保つために処理中のファイルのレコードがバッファされます。
+// fn がエラーを返すと処理を中断し、そのエラーを返します。
+// 個々のファイルの読み込みエラーはログに記録され、cfg.Strict でなければ残りのファイルの処理は継続されます。
+func Process(ctx context.Context, cfg Config, fn func(rec Record) error) error {
+	r, err := newRun(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	return r.processFiles(ctx, fn)
+}
+
+// ProcessReader は r から読み込んだCSVデータを処理し、条件に一致したレコードを返します。
+// name はレコードの File に設定され、警告メッセージにも使われます。
+// ファイルパス以外の入力（メモリ上のデータ、ネットワークストリーム、アーカイブ内のエントリなど）を扱う場合に使用します。
+// r を ReaderSource として Process と同じ処理に渡すため、cfg の指定はすべて Process と同様に反映されます。
+// 読み込みエラーも Process と同様にログに記録され、それまでに一致したレコードが返されます。
+func ProcessReader(r io.Reader, name string, cfg Config) ([]Record, error) {
+	cfg.Source = &ReaderSource{Name: name, Reader: r}
+	var records []Record
+	err := Process(context.Background(), cfg, func(rec Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	if errors.Is(err, errFileLimit) {
+		err = nil
+	}
+	return records, err
+}
+
+// callbackError はレコードごとのコールバックが返したエラーを、
+// ファイルの読み込みエラーと区別するために包みます。
+type callbackError struct {
+	err error
+}
+
+func (e *callbackError) Error() string { return e.err.Error() }
+func (e *callbackError) Unwrap() error { return e.err }
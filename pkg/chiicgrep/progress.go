@@ -0,0 +1,89 @@
+package chiicgrep
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval は行の読み込み中に進捗を通知する最小間隔です。
+const progressInterval = 200 * time.Millisecond
+
+// Progress は処理の進捗です。Config.OnProgress に渡されます。
+type Progress struct {
+	FilesDone   int
+	FilesTotal  int
+	RowsScanned int64
+	Matches     int64
+	Elapsed     time.Duration
+
+	// File と FileElapsed は、1ファイルの処理が完了したときの通知でのみ設定されます。
+	File        string
+	FileElapsed time.Duration
+}
+
+// runStats は1回の処理の件数を集計し、進捗を通知します。
+// 並行処理中の複数のゴルーチンから安全に更新できます。
+type runStats struct {
+	filesTotal int
+	filesDone  atomic.Int64
+	rows       atomic.Int64
+	matches    atomic.Int64
+	begin      time.Time
+
+	onProgress func(Progress)
+	mu         sync.Mutex
+	lastReport time.Time
+}
+
+// start は集計を開始します。onProgress が nil の場合は進捗を通知しません。
+func (s *runStats) start(filesTotal int, onProgress func(Progress)) {
+	s.filesTotal = filesTotal
+	s.begin = time.Now()
+	s.onProgress = onProgress
+}
+
+// beginFile は1ファイルの処理開始を記録し、処理完了時に呼び出す関数を返します。
+func (s *runStats) beginFile() func(name string) {
+	fileStart := time.Now()
+	return func(name string) {
+		s.filesDone.Add(1)
+		s.report(name, time.Since(fileStart), true)
+	}
+}
+
+// addRow は読み込んだデータ行を1件加算します。
+func (s *runStats) addRow() {
+	if n := s.rows.Add(1); n%1024 == 0 {
+		s.report("", 0, false)
+	}
+}
+
+// addMatch は条件に一致した行を1件加算します。
+func (s *runStats) addMatch() {
+	s.matches.Add(1)
+}
+
+// report は現在の進捗を通知します。force が false の場合は progressInterval ごとに間引きます。
+// onProgress は同時に複数呼び出されることはありません。
+func (s *runStats) report(file string, fileElapsed time.Duration, force bool) {
+	if s.onProgress == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if !force && now.Sub(s.lastReport) < progressInterval {
+		return
+	}
+	s.lastReport = now
+	s.onProgress(Progress{
+		FilesDone:   int(s.filesDone.Load()),
+		FilesTotal:  s.filesTotal,
+		RowsScanned: s.rows.Load(),
+		Matches:     s.matches.Load(),
+		Elapsed:     now.Sub(s.begin),
+		File:        file,
+		FileElapsed: fileElapsed,
+	})
+}
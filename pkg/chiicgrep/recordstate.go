@@ -0,0 +1,146 @@
+package chiicgrep
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// recordStateVersion は状態ファイルの形式のバージョンです。
+const recordStateVersion = 1
+
+// RecordState は前回の実行で出力したレコードのハッシュ値を保持し、今回初めて現れたレコードを判定します。
+// Renderer で Renderer を包むと、前回になかったレコードの Record.New を true にし、今回出力したレコードを記録します。
+// 重複の除外や件数の上限で出力されなかったレコードは記録しないため、次回以降に出力された時点で新規になります。
+// レコードはファイルのパスと抽出した列の値で識別するため、行が挿入されて行番号が変わっても同じレコードとして扱います。
+type RecordState struct {
+	mu       sync.Mutex
+	previous map[string]bool
+	current  map[string]bool
+	baseline bool
+}
+
+// recordStateFile は状態ファイルの内容です。
+type recordStateFile struct {
+	Version int       `json:"version"`
+	Saved   time.Time `json:"saved"`
+	Records []string  `json:"records"`
+}
+
+// LoadRecordState は path の状態ファイルを読み込みます。ファイルがない場合は、前回の状態がない RecordState を返します。
+// その場合はすべてのレコードを今回の基準として記録し、新規とはしません。
+func LoadRecordState(path string) (*RecordState, error) {
+	s := &RecordState{previous: make(map[string]bool), current: make(map[string]bool)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.baseline = true
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read state file %s: %w", path, err)
+	}
+	var f recordStateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if f.Version != recordStateVersion {
+		return nil, fmt.Errorf("unsupported state file version %d in %s", f.Version, path)
+	}
+	for _, h := range f.Records {
+		s.previous[h] = true
+	}
+	return s, nil
+}
+
+// Baseline は前回の状態がなく、新規のレコードを判定しないかを返します。
+func (s *RecordState) Baseline() bool {
+	return s.baseline
+}
+
+// Save は今回出力したレコードを path に書き込みます。次回の実行ではこれが前回の状態になります。
+// 書き込みの途中で失敗しても前回の状態が壊れないよう、一時ファイルに書き込んでから置き換えます。
+func (s *RecordState) Save(path string) error {
+	s.mu.Lock()
+	f := recordStateFile{Version: recordStateVersion, Saved: time.Now(), Records: make([]string, 0, len(s.current))}
+	for h := range s.current {
+		f.Records = append(f.Records, h)
+	}
+	s.mu.Unlock()
+	slices.Sort(f.Records)
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not write state file %s: %w", path, err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write state file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Renderer は r に渡すレコードを記録し、前回の状態になければ Record.New を true にしてから渡す Renderer を返します。
+// 前後の行（Record.Context）は記録しません。
+func (s *RecordState) Renderer(r Renderer) Renderer {
+	return &stateRenderer{state: s, r: r}
+}
+
+// stateRenderer は RecordState.Renderer が返す Renderer です。
+type stateRenderer struct {
+	state *RecordState
+	r     Renderer
+}
+
+// Begin は r の Begin を呼び出します。
+func (sr *stateRenderer) Begin() error { return sr.r.Begin() }
+
+// Render はレコードを記録してから r に渡します。
+func (sr *stateRenderer) Render(rec Record) error {
+	if !rec.Context {
+		rec.New = sr.state.record(rec)
+	}
+	return sr.r.Render(rec)
+}
+
+// End は r の End を呼び出します。
+func (sr *stateRenderer) End(sum Summary) error { return sr.r.End(sum) }
+
+// record はレコードを記録し、前回の状態になかったかを返します。
+func (s *RecordState) record(rec Record) bool {
+	h := recordHash(rec)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current[h] = true
+	return !s.baseline && !s.previous[h]
+}
+
+// recordHash はファイルのパスと抽出した列の値から、レコードを識別するハッシュ値を返します。
+func recordHash(rec Record) string {
+	h := sha256.New()
+	h.Write([]byte(filepath.ToSlash(rec.File)))
+	for _, f := range rec.Fields {
+		h.Write([]byte{0})
+		h.Write([]byte(f.Column.Name))
+		h.Write([]byte{0x1f})
+		h.Write([]byte(f.Value))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
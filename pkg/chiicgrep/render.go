@@ -0,0 +1,1023 @@
+package chiicgrep
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// Renderer は抽出結果を出力形式に変換します。
+// Begin は最初のレコードの前に、End は最後のレコードの後に一度だけ呼び出されます。
+// 処理が中断された場合も End は呼び出され、その旨が Summary に設定されます。
+type Renderer interface {
+	Begin() error
+	Render(rec Record) error
+	End(sum Summary) error
+}
+
+var (
+	headerColor    = color.New(color.FgCyan).SprintFunc()
+	valueColor     = color.New(color.FgGreen).SprintFunc()
+	highlightColor = color.New(color.FgBlack, color.BgYellow).SprintFunc()
+	// recordColor と highlightedRecordColor はレコードの見出しの行の色です。強調表示されたレコードは黄色で示します。
+	recordColor            = color.New(color.Bold).SprintFunc()
+	highlightedRecordColor = color.New(color.Bold, color.FgYellow).SprintFunc()
+	errorColor             = color.New(color.FgRed).SprintFunc()
+	noticeColor            = color.New(color.FgYellow).SprintFunc()
+	// contextColor は Config.Context で出力した前後の行の色です。一致した行より目立たないよう薄く表示します。
+	contextColor = color.New(color.Faint).SprintFunc()
+	// newColor は RecordState により前回の実行になかったレコードの印の色です。
+	newColor = color.New(color.FgHiGreen, color.Bold).SprintFunc()
+)
+
+// TextOptions は TextRenderer の出力設定です。
+type TextOptions struct {
+	// Tags は DefaultTags に加えて使用するタグの定義です。タグはこの色で表示されます。
+	Tags []TagDef
+	// Timeline が指定されている場合、Record.Date の日付が切り替わるごとに日付の見出しを出力します。
+	Timeline string
+}
+
+// TextRenderer はレコードをコンソール向けのテキストとして出力します。
+// 色付けの有無は color.NoColor に従います。
+type TextRenderer struct {
+	w         io.Writer
+	tagColors map[string]func(a ...any) string
+	timeline  bool
+	// lastDate はタイムライン表示で直前に見出しを出力した日付です。
+	lastDate *string
+}
+
+// NewTextRenderer は新しい TextRenderer を作成します。
+func NewTextRenderer(w io.Writer, opts TextOptions) *TextRenderer {
+	r := &TextRenderer{w: w, tagColors: make(map[string]func(a ...any) string), timeline: opts.Timeline != ""}
+	for _, d := range MergeTagDefs(opts.Tags) {
+		r.tagColors[d.Name] = ansiTagColor(d.Color)
+	}
+	return r
+}
+
+// defaultTagColor は定義されていないタグの色です。HTMLレポートの既定の色に合わせて灰色で表示します。
+var defaultTagColor = color.New(color.FgWhite, color.BgHiBlack).SprintFunc()
+
+// namedTagColors はタグの色に指定できるCSSの色名のうち、端末の色で表示できるものです。
+var namedTagColors = map[string]color.Attribute{
+	"black":   color.BgBlack,
+	"red":     color.BgRed,
+	"green":   color.BgGreen,
+	"yellow":  color.BgYellow,
+	"blue":    color.BgBlue,
+	"magenta": color.BgMagenta,
+	"cyan":    color.BgCyan,
+	"white":   color.BgWhite,
+	"gray":    color.BgHiBlack,
+	"grey":    color.BgHiBlack,
+}
+
+// ansiTagColor はタグの色 css（#rgb、#rrggbb または色名）を背景色とする表示関数を返します。
+// 端末で表せない色名の場合は defaultTagColor を返します。
+func ansiTagColor(css string) func(a ...any) string {
+	hex := strings.TrimPrefix(css, "#")
+	if hex != css {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		var rgb [3]int
+		if len(hex) >= 6 {
+			if _, err := fmt.Sscanf(hex[:6], "%02x%02x%02x", &rgb[0], &rgb[1], &rgb[2]); err == nil {
+				return color.New(color.FgWhite).AddBgRGB(rgb[0], rgb[1], rgb[2]).SprintFunc()
+			}
+		}
+		return defaultTagColor
+	}
+	if bg, ok := namedTagColors[strings.ToLower(css)]; ok {
+		return color.New(color.FgWhite, bg).SprintFunc()
+	}
+	return defaultTagColor
+}
+
+// formatTags はタグを " [タグ1, タグ2]" の形式で、それぞれの色を付けて返します。
+func (r *TextRenderer) formatTags(tags []string) string {
+	colored := make([]string, len(tags))
+	for i, t := range tags {
+		c, ok := r.tagColors[t]
+		if !ok {
+			c = defaultTagColor
+		}
+		colored[i] = c(t)
+	}
+	return " [" + strings.Join(colored, ", ") + "]"
+}
+
+// Begin はテキスト出力では何もしません。
+func (r *TextRenderer) Begin() error { return nil }
+
+// Render は1件のレコードを出力します。
+func (r *TextRenderer) Render(rec Record) error {
+	sw := render.Writer{W: r.w}
+	if r.timeline && (r.lastDate == nil || *r.lastDate != rec.Date) {
+		sw.Printf("%s\n", recordColor("=== "+timelineDayLabel(rec.Date)+" ==="))
+		r.lastDate = &rec.Date
+	}
+	heading := recordColor
+	switch {
+	case rec.Context:
+		heading = contextColor
+	case rec.Highlighted:
+		heading = highlightedRecordColor
+	}
+	sw.WriteString(heading("--- File: " + rec.File))
+	if len(rec.Tags) > 0 {
+		sw.WriteString(r.formatTags(rec.Tags))
+	}
+	sw.WriteString(heading(fmt.Sprintf(", Line: %d", rec.Line)))
+	if rec.New {
+		sw.WriteString(" " + newColor("[新規]"))
+	}
+	if len(rec.RowTags) > 0 {
+		sw.WriteString(r.formatTags(rec.RowTags))
+	}
+	if d := rec.DuplicateOf; d != nil {
+		sw.WriteString(" " + errorColor(fmt.Sprintf("[キー重複: %s:%d]", d.File, d.Line)))
+	}
+	sw.WriteString(heading(" ---") + "\n")
+	for _, f := range rec.Fields {
+		if rec.Context {
+			sw.Printf("%s\n", contextColor(f.Column.Label+":["+f.Value+"]"))
+			continue
+		}
+		value := valueColor(f.Value)
+		if f.Highlighted {
+			value = highlightColor(f.Value)
+		}
+		if len(f.Violations) > 0 {
+			value = errorColor(f.Value)
+			sw.Printf("%s:[%s] %s\n", headerColor(f.Column.Label), value, errorColor("! "+strings.Join(f.Violations, ", ")))
+			continue
+		}
+		sw.Printf("%s:[%s]\n", headerColor(f.Column.Label), value)
+	}
+	return sw.Err
+}
+
+// End は列の集計値と頻出値、クロス集計、読み込みエラーや必須列の欠落があったファイルと、処理が中断された場合や
+// 件数の上限で結果を打ち切った場合にはその旨を出力します。
+func (r *TextRenderer) End(sum Summary) error {
+	sw := render.Writer{W: r.w}
+	for _, agg := range sum.Aggregates {
+		sw.Printf("--- Aggregate %s: %s ---\n", agg.Column, formatAggregateValues(agg.Total, agg.Funcs))
+		if len(agg.PerFile) > 1 {
+			for _, fa := range agg.PerFile {
+				sw.Printf("--- Aggregate %s in %s: %s ---\n", agg.Column, fa.File, formatAggregateValues(fa.Values, agg.Funcs))
+			}
+		}
+	}
+	if p := sum.Pivot; p != nil {
+		sw.Printf("--- Pivot %s x %s (%s) ---\n", p.Rows, p.Cols, pivotFuncLabel(p.Pivot))
+		for _, row := range pivotRows(p) {
+			sw.Printf("%s\n", strings.Join(row, "\t"))
+		}
+	}
+	for _, top := range sum.TopValues {
+		sw.Printf("--- Top values of %s (%d of %d distinct, %d records) ---\n", top.Column, len(top.Values), top.Distinct, top.Total)
+		for _, v := range top.Values {
+			sw.Printf("%s:[%s] %d (%.1f%%)\n", headerColor(top.Column), valueColor(topValueLabel(v.Value)), v.Count, top.Percent(v.Count))
+		}
+		if top.Others > 0 {
+			sw.Printf("(others) %d (%.1f%%)\n", top.Others, top.Percent(top.Others))
+		}
+	}
+	for _, c := range sum.KeyConflicts {
+		sw.Printf("%s\n", errorColor(fmt.Sprintf("--- Duplicate key %s=%s: %d rows ---", strings.Join(sum.UniqueKey, ","), strings.Join(c.Key, ","), len(c.Locations))))
+		for _, loc := range c.Locations {
+			sw.Printf("%s:%d\n", loc.File, loc.Line)
+		}
+	}
+	for _, v := range sum.Validations {
+		sw.Printf("%s\n", errorColor(fmt.Sprintf("--- Validation %s: %d violations ---", v.Rule, v.Count)))
+		for _, e := range v.Examples {
+			sw.Printf("%s:%d:[%s]\n", e.File, e.Line, e.Value)
+		}
+		if more := v.Count - len(v.Examples); more > 0 {
+			sw.Printf("(%d more)\n", more)
+		}
+	}
+	if locked := sum.LockedFiles(); len(locked) > 0 {
+		sw.Printf("%s\n", errorColor(fmt.Sprintf("--- Locked files (close them and run again): %s ---", strings.Join(locked, ", "))))
+	}
+	for _, e := range sum.Errors {
+		sw.Printf("%s\n", errorColor("--- Error: "+e.Error()+" ---"))
+	}
+	for _, v := range sum.SchemaViolations {
+		sw.Printf("%s\n", errorColor(fmt.Sprintf("--- Missing required columns in %s: %s ---", v.File, strings.Join(v.Missing, ", "))))
+	}
+	if sum.Interrupted {
+		sw.Printf("%s\n", noticeColor(fmt.Sprintf("--- Interrupted after %d of %d files: partial results ---", sum.FilesScanned, sum.FilesTotal)))
+	}
+	if sum.Aborted {
+		sw.Printf("%s\n", noticeColor("--- Aborted in strict mode: partial results ---"))
+	}
+	for _, notice := range truncationNotices(sum) {
+		sw.Printf("%s\n", noticeColor("--- "+notice+" ---"))
+	}
+	return sw.Err
+}
+
+// TSVRenderer はレコードをタブ区切りのテキストとして出力します。表計算ソフトへの貼り付けに適しています。
+// 1行目は見出しで、ファイル名と行番号に続けて抽出する列を Config.Columns の順に並べます。
+type TSVRenderer struct {
+	// ContextColumn が true の場合、CSVRenderer と同様に行番号の後に Context 列を加えます。
+	ContextColumn bool
+
+	w       io.Writer
+	columns []Column
+}
+
+// NewTSVRenderer は columns の列を出力する新しい TSVRenderer を作成します。
+func NewTSVRenderer(w io.Writer, columns []Column) *TSVRenderer {
+	return &TSVRenderer{w: w, columns: columns}
+}
+
+// tsvEscaper はセルの区切りを壊すタブと改行を空白に置き換えます。
+var tsvEscaper = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+// Begin は見出しの行を出力します。
+func (r *TSVRenderer) Begin() error {
+	sw := render.Writer{W: r.w}
+	sw.WriteString("File\tLine")
+	if r.ContextColumn {
+		sw.WriteString("\tContext")
+	}
+	for _, col := range r.columns {
+		sw.WriteString("\t" + tsvEscaper.Replace(col.Label))
+	}
+	sw.WriteString("\n")
+	return sw.Err
+}
+
+// Render は1件のレコードを1行として出力します。ファイルにない列は空になります。
+func (r *TSVRenderer) Render(rec Record) error {
+	sw := render.Writer{W: r.w}
+	sw.Printf("%s\t%d", tsvEscaper.Replace(rec.File), rec.Line)
+	if r.ContextColumn {
+		sw.WriteString("\t" + contextMarker(rec))
+	}
+	// 同じ列を異なる表示名で指定できるため、表示名で対応付ける
+	values := make(map[string]string, len(rec.Fields))
+	for _, f := range rec.Fields {
+		values[f.Column.Label] = f.Value
+	}
+	for _, col := range r.columns {
+		sw.WriteString("\t" + tsvEscaper.Replace(values[col.Label]))
+	}
+	sw.WriteString("\n")
+	return sw.Err
+}
+
+// End はTSV出力では何もしません。読み込みエラーなどはログと終了コードで確認します。
+func (r *TSVRenderer) End(sum Summary) error { return nil }
+
+// HTMLOptions は HTMLRenderer の出力設定です。
+type HTMLOptions struct {
+	Title string
+	Font  string // 値（データ）部分に適用するフォント名。.woff2、.woff、.ttf、.otf のパスの場合はファイルを埋め込みます
+	// Tags は DefaultTags に加えて使用するタグの定義です。同じ名前の組み込みタグは上書きされます。
+	Tags []TagDef
+	// Generator はフッターに表示する、レポートを生成したプログラムとそのバージョンです。空の場合は表示しません。
+	Generator string
+	// Timeline が指定されている場合、レコードをファイルごとではなく Record.Date の日付ごとの見出しの下に並べ、
+	// 日付へのリンクを左側に表示します。値は見出しの列名で、レコードは日付の順に渡す必要があります。
+	Timeline string
+	// ImageColumns は値を画像のパスまたはURLとしてサムネイルで表示する列の名前です。
+	// 相対パスはCSVファイルのフォルダを基準に解決します。
+	ImageColumns []string
+	// EmbedImages が true の場合、ImageColumns の画像をデータURLとしてレポートに埋め込みます。
+	// レポートのファイルだけを共有しても画像が表示されます。
+	EmbedImages bool
+	// ShowCodes が true の場合、Config.ValueMaps で置き換えた値に元のコードをツールチップとして表示します。
+	ShowCodes bool
+	// JSONColumns は値がJSONの場合に、整形して色分けして表示する列の名前です。JSONでない値はそのまま表示します。
+	JSONColumns []string
+	// MaxValueLen が正の場合、これより長い値は先頭だけを表示し、クリックすると全体を表示します。
+	MaxValueLen int
+	// CommandLine と Options は、レポートの末尾の「レポートの情報」に記載する実行時のコマンドラインと、
+	// 設定ファイルなどを反映した実際のオプションの名前と値です。空の場合は記載しません。
+	CommandLine string
+	Options     [][2]string
+	// Dashboard が指定されている場合、レポートをこのパネルを順に格子状に並べたダッシュボードとして出力します。
+	// 指定しないパネル（records を含む）は出力しません。レコードの一覧を最後に組み立てるため、出力するレコードはメモリ上に保持します。
+	// Timeline と同時に指定した場合は無視します。
+	Dashboard []DashboardPanel
+	// Theme はレポートの配色を変えるCSSの変数の値です。キーは ThemeVariables の名前です。
+	Theme map[string]string
+	// Palette は強調表示とタグの配色です。Theme で指定した色は配色より優先します。
+	Palette Palette
+	// PrintLayout はA4用紙への印刷に合わせたレポートを出力します。ファイルごとのセクションの前で改ページし、
+	// MaxValueLen で長い値を省略せず、レポートの情報を開いた状態で出力します。
+	PrintLayout bool
+}
+
+// HTMLRenderer はレコードをCSSでスタイリングされたHTMLレポートとして出力します。
+type HTMLRenderer struct {
+	w           io.Writer
+	opts        HTMLOptions
+	currentFile string
+	legend      tagLegend
+	// days と seenFiles はタイムライン表示の日付の見出しと、凡例に数えたファイルです。
+	days      []timelineDay
+	seenFiles map[string]bool
+	// geo は Config.Geo の地図に描くレコードです。
+	geo geoMap
+	// gantt は Config.Gantt の工程表に描くレコードです。
+	gantt ganttChart
+	// records は HTMLOptions.Dashboard の場合に、レコードの一覧のパネルの内容を保持します。
+	records bytes.Buffer
+}
+
+// NewHTMLRenderer は新しい HTMLRenderer を作成します。
+func NewHTMLRenderer(w io.Writer, opts HTMLOptions) *HTMLRenderer {
+	if opts.Title == "" {
+		opts.Title = "CSV抽出レポート"
+	}
+	if opts.Timeline != "" {
+		opts.Dashboard = nil
+	}
+	if opts.PrintLayout {
+		opts.MaxValueLen = 0
+	}
+	return &HTMLRenderer{w: w, opts: opts}
+}
+
+const htmlHeader = `<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="UTF-8">
+<title>%s</title>
+<style>
+%s%sbody { font-family: "Meiryo UI", "Hiragino Sans", sans-serif; margin: 1.5em; color: var(--text); background: var(--background); }
+h1 { font-size: 1.4em; border-bottom: 2px solid var(--accent); padding-bottom: 0.2em; }
+.file { margin-bottom: 1.5em; }
+.file-info { font-weight: bold; background: var(--accent-light); padding: 0.3em 0.6em; border-left: 4px solid var(--accent); }
+.duplicate-files { display: block; font-weight: normal; font-size: 0.85em; color: #555; word-break: break-all; }
+.record { background: var(--surface); border: 1px solid var(--border); margin: 0.5em 0; padding: 0.4em 0.8em; }
+.record-info { color: var(--muted); font-size: 0.85em; margin-bottom: 0.2em; }
+.key { color: var(--accent-dark); font-weight: bold; }
+.value { color: var(--value); font-family: %s; white-space: pre-wrap; }
+.record:target { outline: 3px solid var(--accent); }
+.permalink { margin-left: 0.4em; text-decoration: none; opacity: 0.4; }
+.record:hover .permalink, .record:target .permalink { opacity: 1; }
+.record.highlighted { border-left: 4px solid var(--highlight-border); }
+.value.highlight { background: var(--highlight); color: var(--highlight-text); font-weight: bold; }
+.record.invalid { border-right: 4px solid var(--error); }
+.value.invalid { background: #ffebee; color: var(--error); text-decoration: underline wavy var(--error); }
+.value.invalid::after { content: " ⚠"; }
+.validation td.value-cell { word-break: break-all; }
+.validation a { color: var(--accent-dark); }
+.record.duplicate-key { border-left: 4px solid var(--error); }
+.badge-duplicate { margin-left: 0.5em; padding: 0 0.4em; border: 1px solid var(--error); border-radius: 3px; color: var(--error); font-size: 0.85em; font-weight: normal; text-decoration: none; }
+.key-conflicts td { word-break: break-all; }
+.key-conflicts a { color: var(--accent-dark); }
+.summary { background: var(--surface); border: 1px solid var(--accent); padding: 0.6em 0.8em; margin: 1em 0; }
+.summary-info { color: var(--accent-dark); font-weight: bold; margin-bottom: 0.3em; }
+.summary th { text-align: left; font-weight: normal; color: #555; padding-right: 1.5em; }
+.summary td.number { text-align: right; padding-left: 1em; }
+.summary td.bar-cell { width: 20em; padding-left: 1em; }
+.bar { height: 0.9em; background: var(--bar); }
+.chart { display: block; margin: 0.3em 0 0.6em; }
+.geo-map { display: block; max-width: 100%%; height: auto; margin: 0.3em 0; }
+.geo-point { fill: var(--accent); fill-opacity: 0.7; stroke: #fff; stroke-width: 1; }
+.geo-point.highlighted { fill: var(--highlight-border); fill-opacity: 1; }
+.geo-point:hover { fill: #e53935; }
+.chart-note { color: #555; font-size: 0.85em; }
+.dashboard { display: grid; grid-template-columns: repeat(auto-fit, minmax(28em, 1fr)); gap: 1em; align-items: start; }
+.panel { background: var(--surface); border: 1px solid var(--accent); padding: 0.6em 0.8em; overflow: auto; min-width: 0; }
+.panel h2 { font-size: 1.1em; color: var(--accent-dark); margin: 0 0 0.4em; }
+.panel-records, .panel-gantt { grid-column: 1 / -1; }
+.panel .summary { border: none; padding: 0; margin: 0 0 0.6em; }
+.panel .legend { position: static; border: none; box-shadow: none; padding: 0; }
+.gantt-chart { display: block; max-width: 100%%; height: auto; margin: 0.3em 0; }
+.gantt-grid { stroke: #e0e0e0; }
+.gantt-tick { font-size: 11px; fill: #777; }
+.gantt-label { font-size: 12px; fill: var(--accent-dark); }
+.gantt-bar { fill: var(--bar); }
+.gantt-bar.highlighted { fill: var(--highlight-border); }
+.gantt-bar:hover { fill: var(--accent); }
+.pivot td, .pivot th { border: 1px solid var(--border); }
+.pivot thead th { text-align: center; }
+.locked { background: #fff8e1; border: 1px solid var(--highlight-border); padding: 0.6em 0.8em; margin: 1em 0; }
+.locked-info { color: #e65100; font-weight: bold; }
+.locked ul { margin: 0.3em 0 0; word-break: break-all; }
+.errors { background: #ffebee; border: 1px solid var(--error); padding: 0.6em 0.8em; margin: 1em 0; }
+.errors-info { color: var(--error); font-weight: bold; margin-bottom: 0.3em; }
+.error { font-family: monospace; white-space: pre-wrap; }
+.errors table { border-collapse: collapse; background: var(--surface); }
+.errors th, .errors td { border: 1px solid #ef9a9a; padding: 0.2em 0.6em; text-align: left; vertical-align: top; }
+.errors td.error-file { word-break: break-all; }
+.interrupted { position: fixed; top: 0; left: 0; right: 0; z-index: 20; background: var(--error); color: #fff; font-size: 1.2em; font-weight: bold; text-align: center; padding: 0.6em; }
+body:has(.interrupted) { padding-top: 3em; }
+.notice { background: #fff3e0; border: 1px solid #ef6c00; color: #e65100; font-weight: bold; padding: 0.6em 0.8em; margin: 1em 0; }
+.legend { position: fixed; top: 1em; right: 1em; background: var(--surface); border: 1px solid var(--accent); padding: 0.5em 0.8em; font-size: 0.9em; box-shadow: 0 1px 4px rgba(0,0,0,0.2); }
+.legend label { display: block; }
+.timeline { margin-left: 13em; }
+.timeline-nav { position: fixed; top: 1em; left: 1em; width: 11em; max-height: calc(100vh - 2em); overflow-y: auto; background: var(--surface); border: 1px solid var(--accent); padding: 0.5em 0.8em; font-size: 0.9em; }
+.timeline-nav-title { color: var(--accent-dark); font-weight: bold; margin-bottom: 0.3em; }
+.timeline-nav a { display: block; color: var(--accent-dark); text-decoration: none; }
+.timeline-nav .count { color: var(--muted); font-size: 0.85em; }
+.day-heading { position: sticky; top: 0; font-size: 1.1em; background: var(--accent-light); padding: 0.3em 0.6em; border-left: 4px solid var(--accent); margin: 1em 0 0.5em; }
+.record.context { opacity: 0.55; border-left-style: dashed; }
+.long-value { display: inline; }
+.long-value summary { display: inline; cursor: pointer; }
+.long-value .length { color: var(--accent-dark); font-size: 0.85em; margin-left: 0.3em; }
+.long-value[open] .preview { display: none; }
+pre.json { margin: 0.2em 0 0.2em 1.5em; padding: 0.4em 0.6em; background: #f5f5f5; border: 1px solid var(--border); max-height: 30em; overflow: auto; color: #333; }
+.json-key { color: #6a1b9a; }
+.json-string { color: #2e7d32; }
+.json-number { color: #1565c0; }
+.json-literal { color: #e65100; }
+.thumb { max-width: 240px; max-height: 160px; border: 1px solid var(--border); vertical-align: top; }
+.toolbar { margin: 0.5em 0; }
+.toolbar button { background: var(--accent); color: #fff; border: none; border-radius: 0.3em; padding: 0.3em 0.9em; cursor: pointer; }
+.metadata { color: #555; font-size: 0.85em; margin-top: 2em; }
+.metadata summary { cursor: pointer; color: #888; }
+.metadata .generator { margin-left: 1em; }
+.metadata table { border-collapse: collapse; margin: 0.4em 0; }
+.metadata th, .metadata td { text-align: left; vertical-align: top; padding: 0.1em 1em 0.1em 0; }
+.metadata td.number { text-align: right; }
+.metadata code { white-space: pre-wrap; word-break: break-all; }
+.badge-new { display: inline-block; font-size: 0.8em; font-weight: bold; color: #fff; background: #e65100; border-radius: 0.3em; padding: 0 0.5em; margin-left: 0.4em; }
+.tag { display: inline-block; font-size: 0.8em; font-weight: normal; color: #fff; background: #90a4ae; border-radius: 0.8em; padding: 0 0.6em; margin-left: 0.4em; }
+%s%s%s</style>
+</head>
+<body>
+<h1>%s</h1>
+`
+
+const htmlFooter = `</body>
+</html>
+`
+
+// Begin はHTMLのヘッダーとスタイルシートを出力します。
+func (r *HTMLRenderer) Begin() error {
+	title := html.EscapeString(r.opts.Title)
+	face, family, err := fontFace(r.opts.Font)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(r.w, htmlHeader, title, themeCSS(r.opts.Palette, r.opts.Theme), face, render.FontFamily(family), tagCSS(r.opts.Palette.tagDefs(MergeTagDefs(r.opts.Tags))), r.opts.Palette.css(), r.printStyles(), title)
+	if err == nil {
+		_, err = io.WriteString(r.w, "<div class=\"toolbar\"><button type=\"button\" id=\"export-csv\">CSVダウンロード</button></div>\n")
+	}
+	if err == nil && r.opts.Timeline != "" {
+		_, err = io.WriteString(r.w, "<div class=\"timeline\">\n")
+	}
+	return err
+}
+
+// tagCSS はタグごとの .tag-<名前> のCSSを返します。
+// 名前と色は ParseTagDef で検証済みであることを前提とします。
+func tagCSS(defs []TagDef) string {
+	var b strings.Builder
+	for _, d := range defs {
+		fmt.Fprintf(&b, ".tag-%s { background: %s; }\n", d.Name, d.Color)
+	}
+	return b.String()
+}
+
+// Render は1件のレコードを出力します。ファイルが切り替わるとファイルごとのセクションを開始します。
+func (r *HTMLRenderer) Render(rec Record) error {
+	sw := render.Writer{W: r.recordWriter()}
+	r.geo.add(rec)
+	r.gantt.add(rec)
+	if r.opts.Timeline != "" {
+		r.renderTimeline(&sw, rec)
+		return sw.Err
+	}
+	if rec.File != r.currentFile {
+		if r.currentFile != "" {
+			sw.WriteString("</div>\n")
+		}
+		if len(rec.Tags) > 0 {
+			sw.Printf("<div class=\"file\" data-tags=\"%s\">\n", html.EscapeString(strings.Join(rec.Tags, " ")))
+		} else {
+			sw.WriteString("<div class=\"file\">\n")
+		}
+		sw.Printf("<div class=\"file-info\">File: %s", html.EscapeString(rec.File))
+		for _, tag := range rec.Tags {
+			t := html.EscapeString(tag)
+			sw.Printf("<span class=\"tag tag-%s\">%s</span>", t, t)
+		}
+		if len(rec.DuplicateFiles) > 0 {
+			sw.Printf("<span class=\"duplicate-files\">同じ内容のため省略: %s</span>", html.EscapeString(strings.Join(rec.DuplicateFiles, ", ")))
+		}
+		sw.WriteString("</div>\n")
+		r.currentFile = rec.File
+		r.legend.addFile(rec.Tags)
+	}
+	if !rec.Context {
+		r.legend.addRecord(rec.Tags)
+	}
+	recordClass := "record"
+	switch {
+	case rec.Context:
+		recordClass = "record context"
+	case rec.Highlighted:
+		recordClass = "record highlighted"
+	}
+	if rec.Invalid && !rec.Context {
+		recordClass += " invalid"
+	}
+	if rec.DuplicateOf != nil {
+		recordClass += " duplicate-key"
+	}
+	id := recordID(rec)
+	sw.Printf("<div class=\"%s\" id=\"%s\" data-file=\"%s\" data-line=\"%d\">\n<div class=\"record-info\">Line: %d",
+		recordClass, id, html.EscapeString(rec.File), rec.Line, rec.Line)
+	if rec.New {
+		sw.WriteString("<span class=\"badge-new\">新規</span>")
+	}
+	for _, tag := range rec.RowTags {
+		t := html.EscapeString(tag)
+		sw.Printf("<span class=\"tag tag-%s\">%s</span>", t, t)
+	}
+	if d := rec.DuplicateOf; d != nil {
+		sw.Printf("<a class=\"badge-duplicate\" href=\"#%s\" title=\"キーの値が重複しているレコード\">キー重複: %s:%d</a>",
+			recordID(Record{File: d.File, Line: d.Line}), html.EscapeString(d.File), d.Line)
+	}
+	writePermalink(&sw, id)
+	sw.WriteString("</div>\n")
+	r.writeFields(&sw, rec)
+	sw.WriteString("</div>\n")
+	return sw.Err
+}
+
+// recordWriter はレコードの出力先を返します。ダッシュボードの場合は、レコードの一覧のパネルに出力するまで保持します。
+func (r *HTMLRenderer) recordWriter() io.Writer {
+	if len(r.opts.Dashboard) > 0 {
+		return &r.records
+	}
+	return r.w
+}
+
+// recordID はレコードのファイルのパスと行番号から、レポートを作り直しても変わらないHTMLの id を返します。
+// パスをそのまま使うと id に使えない文字が含まれるため、パスはハッシュ値にします。
+func recordID(rec Record) string {
+	h := fnv.New32a()
+	h.Write([]byte(filepath.ToSlash(rec.File)))
+	return fmt.Sprintf("r-%08x-%d", h.Sum32(), rec.Line)
+}
+
+// writePermalink はレコードへのリンクを出力します。アドレスバーのURLをそのまま共有すると、受け取った人はそのレコードを開けます。
+func writePermalink(sw *render.Writer, id string) {
+	sw.Printf("<a class=\"permalink\" href=\"#%s\" title=\"このレコードへのリンク\">🔗</a>", id)
+}
+
+// writeFields はレコードの列名と値を1行ずつ出力します。値の中のURLとメールアドレスはリンクにし、画像の列はサムネイルで、
+// JSONの列は整形して表示します。
+func (r *HTMLRenderer) writeFields(sw *render.Writer, rec Record) {
+	for _, f := range rec.Fields {
+		valueClass := "value"
+		if f.Highlighted {
+			valueClass = "value highlight"
+		}
+		if len(f.Violations) > 0 {
+			valueClass += " invalid"
+		}
+		if r.isImageColumn(f.Column) {
+			sw.Printf("<div><span class=\"key\">%s</span>: <span class=\"%s\" data-value=\"%s\">", html.EscapeString(f.Column.Label), valueClass, html.EscapeString(f.Value))
+			r.writeImage(sw, f, rec.File)
+			sw.WriteString("</span></div>\n")
+			continue
+		}
+		if r.isJSONColumn(f.Column) {
+			if pretty, ok := prettyJSON(f.Value); ok {
+				sw.Printf("<div><span class=\"key\">%s</span>: <span class=\"%s\" data-value=\"%s\"><pre class=\"json\">%s</pre></span></div>\n",
+					html.EscapeString(f.Column.Label), valueClass, html.EscapeString(f.Value), pretty)
+				continue
+			}
+		}
+		var titles []string
+		if r.opts.ShowCodes && f.Code != "" {
+			titles = append(titles, f.Code)
+		}
+		for _, v := range f.Violations {
+			titles = append(titles, "検証ルールに違反: "+v)
+		}
+		attrs := ""
+		if len(titles) > 0 {
+			attrs = fmt.Sprintf(" title=\"%s\"", html.EscapeString(strings.Join(titles, "\n")))
+		}
+		content := render.Linkify(f.Value)
+		if preview, length, ok := render.Truncate(f.Value, r.opts.MaxValueLen); ok {
+			// CSVのダウンロードには、省略した表示ではなく元の値を使う
+			attrs += fmt.Sprintf(" data-value=\"%s\"", html.EscapeString(f.Value))
+			content = fmt.Sprintf("<details class=\"long-value\"><summary><span class=\"preview\">%s…</span><span class=\"length\">（全%d文字）</span></summary>%s</details>",
+				render.Linkify(preview), length, content)
+		}
+		sw.Printf("<div><span class=\"key\">%s</span>: <span class=\"%s\"%s>%s</span></div>\n",
+			html.EscapeString(f.Column.Label), valueClass, attrs, content)
+	}
+}
+
+// End は開いているセクションを閉じ、集計、列の集計値と頻出値、クロス集計、エラー一覧、各種の通知、レポートの情報、HTMLのフッターを出力します。
+func (r *HTMLRenderer) End(sum Summary) error {
+	sw := render.Writer{W: r.recordWriter()}
+	if r.currentFile != "" {
+		sw.WriteString("</div>\n")
+		r.currentFile = ""
+	}
+	if len(r.days) > 0 {
+		sw.WriteString("</div>\n")
+	}
+	sw.W = r.w
+	if len(r.opts.Dashboard) > 0 {
+		r.writeDashboard(&sw, sum)
+	} else {
+		writeSummaryTable(&sw, sum)
+		r.geo.write(&sw, sum)
+		r.gantt.write(&sw, sum)
+		for _, agg := range sum.Aggregates {
+			writeAggregateTable(&sw, agg)
+		}
+		for _, top := range sum.TopValues {
+			writeTopValuesTable(&sw, top)
+		}
+		if sum.Pivot != nil {
+			writePivotTable(&sw, sum.Pivot)
+		}
+		writeValidationTable(&sw, sum.Validations)
+		writeKeyConflictTable(&sw, sum.UniqueKey, sum.KeyConflicts)
+	}
+	writeLockedFiles(&sw, sum)
+	writeErrorList(&sw, sum)
+	// 中断されたレポートを完全なものと見誤らないよう、画面の上端に固定して表示する
+	if sum.Interrupted {
+		sw.Printf("<div class=\"interrupted\">処理が中断されました（%d/%dファイル）。このレポートには途中までの結果のみが含まれています。</div>\n", sum.FilesScanned, sum.FilesTotal)
+	}
+	if len(r.opts.Dashboard) == 0 {
+		r.legend.write(&sw)
+	}
+	if sum.Aborted {
+		sw.WriteString("<div class=\"notice\">厳格モードのため、読み込みエラーが発生した時点で処理を中止しました。このレポートには途中までの結果のみが含まれています。</div>\n")
+	}
+	for _, notice := range truncationNotices(sum) {
+		sw.Printf("<div class=\"notice\">%s</div>\n", html.EscapeString(notice))
+	}
+	sw.WriteString(exportScript)
+	r.writeMetadata(&sw, sum)
+	if r.opts.Timeline != "" {
+		r.writeTimelineNav(&sw)
+		sw.WriteString("</div>\n")
+	}
+	sw.WriteString(htmlFooter)
+	return sw.Err
+}
+
+// writeSummaryTable は処理したファイルや一致した行の数などの集計の表を出力します。
+func writeSummaryTable(sw *render.Writer, sum Summary) {
+	sw.WriteString("<div class=\"summary\">\n<div class=\"summary-info\">集計</div>\n<table>\n")
+	for _, item := range [][2]string{
+		{"処理したファイル", fmt.Sprintf("%d", sum.FilesScanned)},
+		{"一致したファイル", fmt.Sprintf("%d", sum.FilesWithMatches)},
+		{"読み込んだ行", fmt.Sprintf("%d", sum.RowsScanned)},
+		{"一致した行", fmt.Sprintf("%d", sum.Matches)},
+		{"読み込みエラー", fmt.Sprintf("%d", len(sum.Errors))},
+		{"見つからなかった列", fmt.Sprintf("%d", sum.ColumnWarnings)},
+		{"処理時間", sum.Elapsed.Round(time.Millisecond).String()},
+	} {
+		sw.Printf("<tr><th>%s</th><td>%s</td></tr>\n", item[0], item[1])
+	}
+	sw.WriteString("</table>\n</div>\n")
+}
+
+// formatAggregateValues は "sum=1200, avg=400" のように funcs の集計結果を並べた文字列を返します。
+func formatAggregateValues(v AggregateValues, funcs []AggregateFunc) string {
+	parts := make([]string, 0, len(funcs)+1)
+	for _, f := range funcs {
+		parts = append(parts, string(f)+"="+v.Format(f))
+	}
+	if v.Invalid > 0 {
+		parts = append(parts, fmt.Sprintf("non-numeric=%d", v.Invalid))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeAggregateTable は1つの列の集計値を、全体とファイルごとの小計の表として出力します。
+// 小計は複数のファイルからレコードを出力した場合にだけ出力します。
+func writeAggregateTable(sw *render.Writer, agg AggregateResult) {
+	sw.Printf("<div class=\"summary\">\n<div class=\"summary-info\">集計値: %s</div>\n<table>\n<thead><tr><th></th>", html.EscapeString(agg.Column))
+	for _, f := range agg.Funcs {
+		sw.Printf("<th>%s</th>", f)
+	}
+	invalid := agg.Total.Invalid > 0
+	if invalid {
+		sw.WriteString("<th>数値以外</th>")
+	}
+	sw.WriteString("</tr></thead>\n")
+	row := func(label string, v AggregateValues) {
+		sw.Printf("<tr><th>%s</th>", html.EscapeString(label))
+		for _, f := range agg.Funcs {
+			sw.Printf("<td class=\"number\">%s</td>", v.Format(f))
+		}
+		if invalid {
+			sw.Printf("<td class=\"number\">%d</td>", v.Invalid)
+		}
+		sw.WriteString("</tr>\n")
+	}
+	row("全体", agg.Total)
+	if len(agg.PerFile) > 1 {
+		for _, fa := range agg.PerFile {
+			row(fa.File, fa.Values)
+		}
+	}
+	sw.WriteString("</table>\n</div>\n")
+}
+
+// topValueLabel は頻出値の表示に使う値です。空の値は "(空欄)" と表示します。
+func topValueLabel(v string) string {
+	if v == "" {
+		return "(空欄)"
+	}
+	return v
+}
+
+// writeTopValuesTable は1つの列の頻出値を、円グラフと、件数と割合、割合に応じた長さの棒を並べた表として出力します。
+func writeTopValuesTable(sw *render.Writer, top TopValuesResult) {
+	sw.Printf("<div class=\"summary\">\n<div class=\"summary-info\">頻出値: %s（上位%d件 / %d種類、%d件中）</div>\n",
+		html.EscapeString(top.Column), len(top.Values), top.Distinct, top.Total)
+	sw.WriteString(PieChart(top.Values, top.Others))
+	sw.WriteString("<table>\n")
+	sw.Printf("<thead><tr><th>%s</th><th>件数</th><th>割合</th><th></th></tr></thead>\n", html.EscapeString(top.Column))
+	row := func(label string, count int) {
+		pct := top.Percent(count)
+		sw.Printf("<tr><th>%s</th><td class=\"number\">%d</td><td class=\"number\">%.1f%%</td><td class=\"bar-cell\"><div class=\"bar\" style=\"width: %.1f%%\"></div></td></tr>\n",
+			html.EscapeString(label), count, pct, pct)
+	}
+	for _, v := range top.Values {
+		row(topValueLabel(v.Value), v.Count)
+	}
+	if top.Others > 0 {
+		row("その他", top.Others)
+	}
+	sw.WriteString("</table>\n</div>\n")
+}
+
+// pivotFuncLabel はクロス集計で集計する値の説明を返します。
+func pivotFuncLabel(p Pivot) string {
+	if p.Func == AggCount {
+		return "count"
+	}
+	return string(p.Func) + " of " + p.Value
+}
+
+// pivotRows はクロス集計の結果を、見出しと合計を含む文字列の表として返します。
+// 1行目は列の見出し、各行の先頭は行の見出しです。レコードのないセルは空になります。
+func pivotRows(p *PivotResult) [][]string {
+	f := p.Func
+	cell := func(c PivotCell) string {
+		if c.Records == 0 {
+			return ""
+		}
+		return c.Format(f)
+	}
+	rows := make([][]string, 0, len(p.RowKeys)+2)
+	header := append([]string{p.Rows + " \\ " + p.Cols}, p.ColKeys...)
+	rows = append(rows, append(header, "合計"))
+	for i, key := range p.RowKeys {
+		row := []string{key}
+		for _, c := range p.Cells[i] {
+			row = append(row, cell(c))
+		}
+		rows = append(rows, append(row, cell(p.RowTotals[i])))
+	}
+	total := []string{"合計"}
+	for _, c := range p.ColTotals {
+		total = append(total, cell(c))
+	}
+	return append(rows, append(total, cell(p.Total)))
+}
+
+// writePivotTable はクロス集計の結果をHTMLの表として出力します。
+func writePivotTable(sw *render.Writer, p *PivotResult) {
+	sw.Printf("<div class=\"summary\">\n<div class=\"summary-info\">クロス集計: %s × %s（%s）</div>\n<table class=\"pivot\">\n",
+		html.EscapeString(p.Rows), html.EscapeString(p.Cols), html.EscapeString(pivotFuncLabel(p.Pivot)))
+	for i, row := range pivotRows(p) {
+		if i == 0 {
+			sw.WriteString("<thead>")
+		}
+		sw.WriteString("<tr>")
+		for j, v := range row {
+			if i == 0 || j == 0 {
+				sw.Printf("<th>%s</th>", html.EscapeString(topValueLabel(v)))
+			} else {
+				sw.Printf("<td class=\"number\">%s</td>", v)
+			}
+		}
+		sw.WriteString("</tr>")
+		if i == 0 {
+			sw.WriteString("</thead>")
+		}
+		sw.WriteString("\n")
+	}
+	sw.WriteString("</table>\n</div>\n")
+}
+
+// writeValidationTable は検証ルールごとの違反の数と、違反した箇所へのリンクを表として出力します。
+func writeValidationTable(sw *render.Writer, results []ValidationResult) {
+	if len(results) == 0 {
+		return
+	}
+	total := 0
+	for _, v := range results {
+		total += v.Count
+	}
+	sw.Printf("<div class=\"summary validation\">\n<div class=\"summary-info\">検証ルールの違反（%d件）</div>\n<table>\n", total)
+	sw.WriteString("<thead><tr><th>ルール</th><th>違反</th><th>該当箇所</th></tr></thead>\n")
+	for _, v := range results {
+		sw.Printf("<tr><th>%s</th><td class=\"number\">%d</td><td class=\"value-cell\">", html.EscapeString(v.Rule.String()), v.Count)
+		for i, e := range v.Examples {
+			if i > 0 {
+				sw.WriteString("、")
+			}
+			sw.Printf("<a href=\"#%s\">%s:%d</a> [%s]", recordID(Record{File: e.File, Line: e.Line}), html.EscapeString(e.File), e.Line, html.EscapeString(e.Value))
+		}
+		if more := v.Count - len(v.Examples); more > 0 {
+			sw.Printf("、ほか%d件", more)
+		}
+		sw.WriteString("</td></tr>\n")
+	}
+	sw.WriteString("</table>\n</div>\n")
+}
+
+// writeKeyConflictTable は Config.UniqueKey の値が重複したレコードを、値ごとに該当箇所へのリンクとともに表として出力します。
+func writeKeyConflictTable(sw *render.Writer, columns []string, conflicts []KeyConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	sw.Printf("<div class=\"summary key-conflicts\">\n<div class=\"summary-info\">キーの重複（%s、%d件）</div>\n<table>\n",
+		html.EscapeString(strings.Join(columns, ", ")), len(conflicts))
+	sw.WriteString("<thead><tr>")
+	for _, col := range columns {
+		sw.Printf("<th>%s</th>", html.EscapeString(col))
+	}
+	sw.WriteString("<th>件数</th><th>該当箇所</th></tr></thead>\n")
+	for _, c := range conflicts {
+		sw.WriteString("<tr>")
+		for _, v := range c.Key {
+			sw.Printf("<th>%s</th>", html.EscapeString(v))
+		}
+		sw.Printf("<td class=\"number\">%d</td><td>", len(c.Locations))
+		for i, loc := range c.Locations {
+			if i > 0 {
+				sw.WriteString("、")
+			}
+			sw.Printf("<a href=\"#%s\">%s:%d</a>", recordID(Record{File: loc.File, Line: loc.Line}), html.EscapeString(loc.File), loc.Line)
+		}
+		sw.WriteString("</td></tr>\n")
+	}
+	sw.WriteString("</table>\n</div>\n")
+}
+
+// tagLegend はレポートに含まれるタグごとのファイル数とレコード数を、最初に現れた順に集計します。
+type tagLegend struct {
+	counts   []*tagCount
+	untagged tagCount
+}
+
+// tagCount は1つのタグのファイル数とレコード数です。tag が空の場合はタグのないファイルを表します。
+type tagCount struct {
+	tag     string
+	files   int
+	records int
+}
+
+// lookup は tags に対応する集計を返します。tags が空の場合はタグのないファイルの集計を返します。
+func (l *tagLegend) lookup(tags []string) []*tagCount {
+	if len(tags) == 0 {
+		return []*tagCount{&l.untagged}
+	}
+	result := make([]*tagCount, 0, len(tags))
+	for _, tag := range tags {
+		var c *tagCount
+		for _, existing := range l.counts {
+			if existing.tag == tag {
+				c = existing
+				break
+			}
+		}
+		if c == nil {
+			c = &tagCount{tag: tag}
+			l.counts = append(l.counts, c)
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func (l *tagLegend) addFile(tags []string) {
+	for _, c := range l.lookup(tags) {
+		c.files++
+	}
+}
+
+func (l *tagLegend) addRecord(tags []string) {
+	for _, c := range l.lookup(tags) {
+		c.records++
+	}
+}
+
+// tagFilterScript はタグの凡例のチェックボックスに応じて、ファイルごとのセクション
+// （タイムライン表示ではレコード）を表示・非表示にします。
+// ファイルは付いているタグのいずれかがチェックされていれば表示されます。
+const tagFilterScript = `<script>
+function applyTagFilter() {
+  const checked = new Set(Array.from(document.querySelectorAll(".legend input:checked"), c => c.value));
+  document.querySelectorAll(".file, .timeline .record").forEach(f => {
+    const tags = f.dataset.tags ? f.dataset.tags.split(" ") : [""];
+    f.hidden = !tags.some(t => checked.has(t));
+  });
+}
+document.querySelectorAll(".legend input").forEach(c => c.addEventListener("change", applyTagFilter));
+</script>
+`
+
+// exportScript は「CSVダウンロード」のボタンで、タグの凡例などで絞り込んだ後に表示されているレコードを
+// CSVファイルとしてブラウザ上で作成し、ダウンロードさせます。列はファイル、行番号と、レコードに現れた順の列名です。
+// Excelで文字化けしないよう、先頭にBOMを付けます。
+const exportScript = `<script>
+document.getElementById("export-csv").addEventListener("click", () => {
+  const labels = [], rows = [];
+  document.querySelectorAll(".record").forEach(rec => {
+    if (rec.closest("[hidden]")) return;
+    const row = {File: rec.dataset.file, Line: rec.dataset.line};
+    rec.querySelectorAll(":scope > div > .key").forEach(k => {
+      const v = k.nextElementSibling;
+      const label = k.textContent;
+      if (!labels.includes(label)) labels.push(label);
+      row[label] = v.dataset.value !== undefined ? v.dataset.value : v.textContent;
+    });
+    rows.push(row);
+  });
+  const quote = s => /[",\r\n]/.test(s) ? '"' + s.replace(/"/g, '""') + '"' : s;
+  const header = ["File", "Line", ...labels];
+  const lines = [header.map(quote).join(",")];
+  rows.forEach(row => lines.push(header.map(h => quote(row[h] ?? "")).join(",")));
+  const blob = new Blob(["\ufeff" + lines.join("\r\n") + "\r\n"], {type: "text/csv"});
+  const a = document.createElement("a");
+  a.href = URL.createObjectURL(blob);
+  a.download = (document.title || "report") + ".csv";
+  a.click();
+  URL.revokeObjectURL(a.href);
+});
+</script>
+`
+
+// write はタグの凡例と、タグで表示を絞り込むためのチェックボックスを出力します。
+// タグの付いたファイルが1つもない場合は何も出力しません。
+func (l *tagLegend) write(sw *render.Writer) {
+	if len(l.counts) == 0 {
+		return
+	}
+	sw.WriteString("<div class=\"legend\">\n")
+	for _, c := range l.counts {
+		t := html.EscapeString(c.tag)
+		sw.Printf("<label><input type=\"checkbox\" value=\"%s\" checked><span class=\"tag tag-%s\">%s</span> %dファイル / %d件</label>\n", t, t, t, c.files, c.records)
+	}
+	if l.untagged.files > 0 {
+		sw.Printf("<label><input type=\"checkbox\" value=\"\" checked>タグなし %dファイル / %d件</label>\n", l.untagged.files, l.untagged.records)
+	}
+	sw.WriteString("</div>\n")
+	sw.WriteString(tagFilterScript)
+}
+
+// truncationNotices は重複の除外や件数の上限で結果を打ち切ったことを知らせるメッセージを返します。
+func truncationNotices(sum Summary) []string {
+	var notices []string
+	if sum.JoinMisses > 0 {
+		notices = append(notices, fmt.Sprintf("結合先のファイルにキーが見つからなかったレコードが %d 件あります。", sum.JoinMisses))
+	}
+	if sum.Duplicates > 0 {
+		notices = append(notices, fmt.Sprintf("重複するレコード %d 件を除外しました。", sum.Duplicates))
+	}
+	if sum.Sample != nil {
+		notices = append(notices, fmt.Sprintf("抽出: %sだけを出力しています。", sum.Sample))
+	}
+	if sum.DuplicateFiles > 0 {
+		notices = append(notices, fmt.Sprintf("ほかのファイルと内容が同じファイル %d 件の処理を省略しました。", sum.DuplicateFiles))
+	}
+	if sum.ResultLimit > 0 {
+		notices = append(notices, fmt.Sprintf("結果が打ち切られました: 出力件数が上限（%d件）に達しました。", sum.ResultLimit))
+	}
+	for _, f := range sum.TruncatedFiles {
+		notices = append(notices, fmt.Sprintf("結果が打ち切られました: %s の一致件数がファイルごとの上限（%d件）に達しました。", f, sum.PerFileLimit))
+	}
+	return notices
+}
@@ -0,0 +1,101 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Replacement は列の値を正規表現で置換する指定です。照合と出力の前に適用されます。
+type Replacement struct {
+	Column string
+	// Pattern は置換する部分の正規表現、Template は regexp.Regexp.Expand の形式の置換後の文字列です。
+	Pattern  *regexp.Regexp
+	Template string
+	// All が true の場合はすべての一致を、false の場合は最初の一致だけを置換します。
+	All bool
+
+	spec string
+}
+
+// sedGroupRef は sed 形式の後方参照 \1 から \9 です。
+var sedGroupRef = regexp.MustCompile(`\\([0-9])`)
+
+// ParseReplacement は "列名:s|正規表現|置換後|フラグ" 形式の指定を解析します。
+// s の直後の文字を区切り文字とし、区切り文字そのものは \ を前に付けて書きます。
+// フラグは g（すべての一致を置換）と i（大文字と小文字を区別しない）を組み合わせて指定できます。
+// 置換後の文字列には sed と同様に \1 のような後方参照を使えます。
+func ParseReplacement(s string) (Replacement, error) {
+	column, expr, found := strings.Cut(s, ":")
+	column = strings.TrimSpace(column)
+	if !found || column == "" {
+		return Replacement{}, fmt.Errorf("invalid replace %q: expected column:s/pattern/replacement/flags", s)
+	}
+	if len(expr) < 2 || expr[0] != 's' {
+		return Replacement{}, fmt.Errorf("invalid replace %q: expression must start with s and a delimiter, e.g. s/old/new/g", s)
+	}
+	parts := splitSedExpr(expr[2:], expr[1])
+	if len(parts) != 3 {
+		return Replacement{}, fmt.Errorf("invalid replace %q: expected s%cpattern%creplacement%cflags", s, expr[1], expr[1], expr[1])
+	}
+	pattern, template, flags := parts[0], parts[1], parts[2]
+	rep := Replacement{Column: column, spec: s}
+	for _, f := range flags {
+		switch f {
+		case 'g':
+			rep.All = true
+		case 'i':
+			pattern = "(?i)" + pattern
+		default:
+			return Replacement{}, fmt.Errorf("invalid replace %q: unknown flag %q (expected g or i)", s, f)
+		}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Replacement{}, fmt.Errorf("invalid replace %q: %v", s, err)
+	}
+	rep.Pattern = re
+	rep.Template = sedGroupRef.ReplaceAllString(strings.ReplaceAll(template, "$", "$$"), "$${$1}")
+	return rep, nil
+}
+
+// splitSedExpr は s を区切り文字 delim で分割します。\ に続く区切り文字は区切りとせず、区切り文字そのものにします。
+// それ以外の \ に続く文字は、正規表現のエスケープとしてそのまま残します。
+func splitSedExpr(s string, delim byte) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			if s[i+1] != delim {
+				cur.WriteByte('\\')
+			}
+			cur.WriteByte(s[i+1])
+			i++
+		case s[i] == delim:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(s[i])
+		}
+	}
+	return append(parts, cur.String())
+}
+
+// String は指定された形式のまま rep を返します。
+func (rep Replacement) String() string {
+	return rep.spec
+}
+
+// apply は v に置換を適用した値を返します。
+func (rep Replacement) apply(v string) string {
+	if rep.All {
+		return rep.Pattern.ReplaceAllString(v, rep.Template)
+	}
+	loc := rep.Pattern.FindStringSubmatchIndex(v)
+	if loc == nil {
+		return v
+	}
+	dst := rep.Pattern.ExpandString(nil, rep.Template, v, loc)
+	return v[:loc[0]] + string(dst) + v[loc[1]:]
+}
@@ -0,0 +1,97 @@
+package chiicgrep
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// update は -update を指定してテストを実行した場合に、ゴールデンファイルを現在の出力で書き換えます。
+var update = flag.Bool("update", false, "update golden files")
+
+// volatileCells は実行のたびに変わる、作成日時と処理時間のセルです。
+var volatileCells = regexp.MustCompile(`<tr><th>(作成日時|処理時間)</th><td>[^<]*</td></tr>`)
+
+// recordIDHash はレコードのIDに含まれるファイルのパスのハッシュです。パスの区切り文字がOSで異なるため比較から除きます。
+var recordIDHash = regexp.MustCompile(`\br-[0-9a-f]{8}-`)
+
+func TestHTMLReportGolden(t *testing.T) {
+	mustConditions := func(exprs ...string) []Condition {
+		conds, err := ParseConditions(exprs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conds
+	}
+	mustAggregate := func(s string) Aggregate {
+		agg, err := ParseAggregate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return agg
+	}
+	tests := []struct {
+		name   string
+		cfg    Config
+		opts   HTMLOptions
+		golden string
+	}{
+		{
+			name: "検索文字列に一致した行と強調表示",
+			cfg: Config{
+				Columns:        ParseColumns("注文番号,顧客,金額:金額（円）,備考"),
+				SearchTarget:   "保留",
+				HighlightRules: mustConditions("金額>=10000"),
+			},
+			golden: "report_match.golden.html",
+		},
+		{
+			name: "集計値と頻出値",
+			cfg: Config{
+				Columns:      ParseColumns("注文番号,ステータス"),
+				SearchTarget: "",
+				Aggregates:   []Aggregate{mustAggregate("金額:sum,avg")},
+				TopValues:    []TopValues{{Column: "ステータス", N: 2}},
+			},
+			golden: "report_aggregate.golden.html",
+		},
+		{
+			name:   "長い値を省略する",
+			cfg:    Config{Columns: ParseColumns("注文番号,備考"), SearchTarget: "山田"},
+			opts:   HTMLOptions{Title: "山田商店の注文", MaxValueLen: 4},
+			golden: "report_truncate.golden.html",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.InputPath = filepath.Join("testdata", "report")
+			var buf bytes.Buffer
+			p := NewProcessor(cfg, NewHTMLRenderer(&buf, tt.opts))
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got := volatileCells.ReplaceAllString(filepath.ToSlash(buf.String()), "<tr><th>$1</th><td>-</td></tr>")
+			got = recordIDHash.ReplaceAllString(got, "r-file-")
+
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("report differs from %s (run go test -update to regenerate)\ngot:\n%s", path, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,847 @@
+package chiicgrep
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ChiiCgrep/internal/scan"
+)
+
+var (
+	// errResultLimit は出力件数が Config.MaxResults に達したことを示します。
+	errResultLimit = errors.New("result limit reached")
+	// errFileLimit はファイルの一致件数が Config.MaxPerFile に達したことを示します。
+	errFileLimit = errors.New("per-file limit reached")
+)
+
+// run は1回の抽出処理の状態を保持します。
+type run struct {
+	cfg   Config
+	src   Source
+	files []string
+	stats runStats
+	// lookup は Config.Join の参照用のファイルです。
+	lookup *lookupTable
+	// valueMaps は Config.ValueMaps の対応表です。
+	valueMaps []map[string]string
+
+	mu             sync.Mutex
+	limitReached   bool
+	duplicates     int
+	truncatedFiles []string
+	fileErrors     []FileError
+	missingColumns map[string][]string
+	columnWarnings int
+	aggregates     []aggregateState
+	topCounts      []topValueCounts
+	pivotCells     map[pivotKey]*PivotCell
+	validations    []ValidationResult
+	// uniqueKeys は Config.UniqueKey の値ごとの、その値だったレコードの位置です。
+	uniqueKeys map[string][]KeyLocation
+	// keyConflicts は uniqueKeys のうち2件以上あった値で、2件目が見つかった順に並びます。
+	keyConflicts []string
+	joinMisses   int
+	// fileRows は処理を終えたファイルごとの、読み込んだデータ行の数です。
+	fileRows map[string]int64
+	// valueLists は Config.ValueLists のリストの値の集合です。Config.ValueLists と同じ順序で並びます。
+	valueLists []map[string]bool
+	// sample は Config.Sample に、実際に使う乱数の種を設定したものです。
+	sample *Sample
+	// duplicateFiles は Config.DedupFiles により省略したファイルを、同じ内容で処理するファイルごとに保持します。
+	duplicateFiles map[string][]string
+}
+
+// newRun は cfg の入力を列挙し、処理を開始する準備をします。
+// 入力が1つもない場合は ErrNoCSVFiles を返します。
+func newRun(ctx context.Context, cfg Config) (*run, error) {
+	src := cfg.source()
+	files, err := cfg.listFiles(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoCSVFiles
+	}
+	r := &run{cfg: cfg, src: src, files: files}
+	if cfg.Timeline != "" {
+		// タイムラインの列を最優先の並べ替えキーとする
+		r.cfg.Sort = append([]SortKey{{Column: cfg.Timeline, Type: SortDate}}, cfg.Sort...)
+	}
+	if cfg.Join != nil {
+		if r.lookup, err = loadLookup(cfg); err != nil {
+			return nil, err
+		}
+		// 参照用のファイルが入力のフォルダにある場合は、検索の対象から除く
+		r.files = slices.DeleteFunc(r.files, func(f string) bool {
+			if isSameFile(f, cfg.Join.File) {
+				debugf(f, "Skipping %s: used as the join file", f)
+				return true
+			}
+			return false
+		})
+	}
+	for _, m := range cfg.ValueMaps {
+		labels, err := loadValueMap(cfg, m)
+		if err != nil {
+			return nil, err
+		}
+		r.valueMaps = append(r.valueMaps, labels)
+		// 対応表が入力のフォルダにある場合は、検索の対象から除く
+		r.files = slices.DeleteFunc(r.files, func(f string) bool {
+			if isSameFile(f, m.File) {
+				debugf(f, "Skipping %s: used as a value map", f)
+				return true
+			}
+			return false
+		})
+	}
+	// 内容を比較できるのはファイルシステム上のファイルだけのため、ほかの Source では無視する
+	if _, ok := src.(*FileSource); ok && cfg.DedupFiles {
+		if r.files, r.duplicateFiles, err = dedupeFiles(ctx, r.files); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range cfg.ValueLists {
+		values, err := loadValueList(cfg, l)
+		if err != nil {
+			return nil, err
+		}
+		r.valueLists = append(r.valueLists, values)
+		// リストが入力のフォルダにある場合は、検索の対象から除く
+		r.files = slices.DeleteFunc(r.files, func(f string) bool {
+			if isSameFile(f, l.File) {
+				debugf(f, "Skipping %s: used as a value list", f)
+				return true
+			}
+			return false
+		})
+	}
+	if len(r.files) == 0 {
+		return nil, ErrNoCSVFiles
+	}
+	if cfg.Sample != nil {
+		s := *cfg.Sample
+		if s.Mode == SampleRandom && s.Seed == 0 {
+			// 後から同じ抽出を再現できるよう、-seed に指定しやすい大きさの正の値にする
+			s.Seed = rand.Int64N(1_000_000_000) + 1
+		}
+		r.sample = &s
+	}
+	r.stats.start(len(r.files), cfg.OnProgress)
+	return r, nil
+}
+
+// summary は処理結果のうち、件数と処理時間、読み込みエラー、必須列の欠落、件数の上限による打ち切りの情報を返します。
+func (r *run) summary() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sum := Summary{
+		FilesScanned:   int(r.stats.filesDone.Load()),
+		FilesTotal:     len(r.files),
+		RowsScanned:    r.stats.rows.Load(),
+		ColumnWarnings: r.columnWarnings,
+		Elapsed:        time.Since(r.stats.begin),
+		Errors:         append([]FileError(nil), r.fileErrors...),
+	}
+	sum.Duplicates = r.duplicates
+	sum.Sample = r.sample
+	sum.Geo = r.cfg.Geo
+	sum.Gantt = r.cfg.Gantt
+	for _, dups := range r.duplicateFiles {
+		sum.DuplicateFiles += len(dups)
+	}
+	sum.Aggregates = r.aggregateResults()
+	sum.TopValues = r.topValueResults()
+	sum.Pivot = r.pivotResult()
+	sum.Validations = r.validationResults()
+	sum.UniqueKey = r.cfg.UniqueKey
+	sum.KeyConflicts = r.keyConflictResults()
+	sum.JoinMisses = r.joinMisses
+	if r.limitReached {
+		sum.ResultLimit = r.cfg.MaxResults
+	}
+	for _, f := range r.files {
+		if rows, ok := r.fileRows[f]; ok {
+			sum.Files = append(sum.Files, FileStats{File: f, Rows: rows})
+		}
+		if missing, ok := r.missingColumns[f]; ok {
+			sum.SchemaViolations = append(sum.SchemaViolations, SchemaViolation{File: f, Missing: missing})
+		}
+	}
+	if len(r.truncatedFiles) > 0 {
+		sum.TruncatedFiles = append([]string(nil), r.truncatedFiles...)
+		sum.PerFileLimit = r.cfg.MaxPerFile
+	}
+	return sum
+}
+
+// recordFileError はファイル単位の処理エラーをログに記録し、集計に加えます。
+// 出力と同じ順序で記録されるよう、そのファイルのレコードを出力した後に呼び出します。
+// Config.Strict の場合は処理を中止するための ErrStrict を包んだエラーを返します。
+func (r *run) recordFileError(name string, err error) error {
+	logFileError(name, err)
+	r.mu.Lock()
+	r.fileErrors = append(r.fileErrors, FileError{File: name, Err: err})
+	r.mu.Unlock()
+	if r.cfg.Strict {
+		return fmt.Errorf("%w: %s: %v", ErrStrict, name, err)
+	}
+	return nil
+}
+
+// checkRequiredColumns は Config.RequiredColumns のうち headerMap にない列を記録します。
+func (r *run) checkRequiredColumns(headerMap map[string]int, name string) {
+	var missing []string
+	for _, col := range r.cfg.RequiredColumns {
+		if _, ok := headerMap[col]; !ok {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	warnf(LogKindMissingRequiredColumn, name, "Required columns missing in %s: %s", name, strings.Join(missing, ", "))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.missingColumns == nil {
+		r.missingColumns = make(map[string][]string)
+	}
+	r.missingColumns[name] = missing
+}
+
+// addColumnWarnings は指定された列がファイルに見つからなかった件数を加算します。
+func (r *run) addColumnWarnings(n int) {
+	if n == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.columnWarnings += n
+}
+
+// addFileRows はファイル name の処理を終えたことと、読み込んだデータ行の数を記録します。
+func (r *run) addFileRows(name string, rows int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fileRows == nil {
+		r.fileRows = make(map[string]int64)
+	}
+	r.fileRows[name] += rows
+}
+
+// addJoinMisses は Config.Join の参照用のファイルにキーが見つからなかった行の数を加算します。
+func (r *run) addJoinMisses(n int) {
+	if n == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.joinMisses += n
+}
+
+// addTruncatedFile は Config.MaxPerFile により打ち切ったファイルを記録します。
+// 出力と同じ順序で記録されるよう、レコードを出力した後に呼び出します。
+func (r *run) addTruncatedFile(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.truncatedFiles = append(r.truncatedFiles, name)
+}
+
+// processFiles は r.files を処理し、Config.MaxResults に達した場合はそこで処理を終えます。
+// Config.Sort が指定されている場合は、すべてのレコードを並べ替えてから fn に渡します。
+func (r *run) processFiles(ctx context.Context, fn func(Record) error) error {
+	matched := r.dedupe(r.limitResults(r.aggregate(r.countTopValues(r.countPivot(r.countViolations(r.checkUniqueKeys(fn)))))))
+	if r.cfg.Context > 0 {
+		// 前後の行は重複の除外、件数の上限、集計を経ずにそのまま渡す
+		output := fn
+		fn = func(rec Record) error {
+			if rec.Context {
+				return output(rec)
+			}
+			return matched(rec)
+		}
+	} else {
+		fn = matched
+	}
+	var err error
+	if len(r.cfg.Sort) > 0 {
+		err = r.processSorted(ctx, fn)
+	} else {
+		err = r.processAll(ctx, fn)
+	}
+	if errors.Is(err, errResultLimit) {
+		return nil
+	}
+	return err
+}
+
+// limitResults は Config.MaxResults 件を超えると errResultLimit を返すよう fn を包みます。
+func (r *run) limitResults(fn func(Record) error) func(Record) error {
+	if r.cfg.MaxResults <= 0 {
+		return fn
+	}
+	count := 0
+	return func(rec Record) error {
+		if count == r.cfg.MaxResults {
+			r.mu.Lock()
+			r.limitReached = true
+			r.mu.Unlock()
+			return errResultLimit
+		}
+		count++
+		return fn(rec)
+	}
+}
+
+// dedupe は Config.Dedup が指定されている場合に、既に渡したレコードと重複するレコードを除外するよう fn を包みます。
+func (r *run) dedupe(fn func(Record) error) func(Record) error {
+	if !r.cfg.Dedup {
+		return fn
+	}
+	seen := make(map[string]struct{})
+	var b strings.Builder
+	return func(rec Record) error {
+		key := rec.dedupKey
+		if len(r.cfg.DedupBy) == 0 {
+			b.Reset()
+			for _, f := range rec.Fields {
+				b.WriteString(f.Column.Label)
+				b.WriteByte(0)
+				b.WriteString(f.Value)
+				b.WriteByte(0)
+			}
+			key = b.String()
+		}
+		if _, dup := seen[key]; dup {
+			r.mu.Lock()
+			r.duplicates++
+			r.mu.Unlock()
+			return nil
+		}
+		seen[key] = struct{}{}
+		return fn(rec)
+	}
+}
+
+// processSorted はすべてのファイルのレコードを集めて Config.Sort の順に並べ替え、fn に渡します。
+// Config.SortBuffer 件を超えるレコードは一時ファイルに書き出して並べ替えます。
+// 処理が中断された場合も、それまでに集めたレコードを並べ替えて渡してからエラーを返します。
+func (r *run) processSorted(ctx context.Context, fn func(Record) error) error {
+	sorter := newRecordSorter(r.cfg.Sort, r.cfg.SortBuffer, r.cfg.TempDir)
+	defer sorter.close()
+	err := r.processAll(ctx, sorter.add)
+	if err := sorter.each(fn); err != nil {
+		return err
+	}
+	return err
+}
+
+// processAll は r.files を順に開いて処理します。
+// コールバックのエラーとコンテキストのキャンセルは処理を中断しますが、
+// ファイル単位のエラーはログに記録して次のファイルへ進みます（Config.Strict の場合は中止します）。
+func (r *run) processAll(ctx context.Context, fn func(Record) error) error {
+	if r.cfg.Jobs > 1 && len(r.files) > 1 {
+		return r.processFilesParallel(ctx, r.cfg.Jobs, fn)
+	}
+	for _, file := range r.files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := r.processFile(ctx, file, func(rec Record) error {
+			if err := fn(rec); err != nil {
+				return &callbackError{err: err}
+			}
+			return nil
+		})
+		if errors.Is(err, errFileLimit) {
+			r.addTruncatedFile(file)
+			continue
+		}
+		if err != nil {
+			var cbErr *callbackError
+			if errors.As(err, &cbErr) {
+				return cbErr.err
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err := r.recordFileError(file, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// processFile は単一のCSVファイルを処理し、一致した行ごとに fn を呼び出します。
+func (r *run) processFile(ctx context.Context, name string, fn func(Record) error) error {
+	done := r.stats.beginFile()
+	defer done(name)
+
+	file, err := r.openFile(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if r.sample == nil {
+		return r.scan(ctx, file, name, fn)
+	}
+	// 途中で読み込みに失敗した場合も、それまでに選んだレコードは出力する
+	s := newSampler(*r.sample, name, fn)
+	err = r.scan(ctx, file, name, s.add)
+	if errors.Is(err, errSampleDone) {
+		err = nil
+	}
+	if ferr := s.flush(); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// resolveColumns は指定された列をヘッダー上のインデックスに解決します。
+// 結果は -cols の指定順を保ち、同じ列の重複指定もそのまま残します。
+func resolveColumns(columns []Column, headerMap map[string]int, filePath string) ([]int, []Column) {
+	indices := make([]int, 0, len(columns))
+	resolved := make([]Column, 0, len(columns))
+	for _, col := range columns {
+		if idx, ok := headerMap[col.Name]; ok {
+			debugf(filePath, "Column '%s' resolved to index %d in %s", col.Name, idx, filePath)
+			indices = append(indices, idx)
+			resolved = append(resolved, col)
+		} else {
+			warnf(LogKindMissingColumn, filePath, "Column '%s' not found in %s", col.Name, filePath)
+		}
+	}
+	return indices, resolved
+}
+
+// resolveKeyColumns は出力以外の用途（usage）で参照する列 columns の位置を返します。
+// ファイルにない列は警告を出し、位置を -1 とします。
+func (r *run) resolveKeyColumns(columns []string, headerMap map[string]int, name, usage string) []int {
+	if len(columns) == 0 {
+		return nil
+	}
+	indices := make([]int, len(columns))
+	for i, col := range columns {
+		idx, ok := headerMap[col]
+		if !ok {
+			warnf(LogKindMissingColumn, name, "Column '%s' used in %s not found in %s", col, usage, name)
+			r.addColumnWarnings(1)
+			idx = -1
+		} else {
+			debugf(name, "Column '%s' used in %s resolved to index %d in %s", col, usage, idx, name)
+		}
+		indices[i] = idx
+	}
+	return indices
+}
+
+// scanOptions は cfg のCSVの解析オプションです。
+func (cfg Config) scanOptions() scan.Options {
+	return scan.Options{LazyQuotes: cfg.LazyQuotes, VariableFields: cfg.AllowVariableFields}
+}
+
+// scan は rd からCSVデータを読み込み、条件に一致した行ごとに fn を呼び出します。
+// ctx がキャンセルされると次の行を読む前に中断し、ctx.Err() を返します。
+func (r *run) scan(ctx context.Context, rd io.Reader, name string, fn func(Record) error) error {
+	cfg := r.cfg
+	reader := scan.NewReader(bufio.NewReader(rd), cfg.scanOptions())
+	reader.ReuseRecord = true
+	var rows int64
+	defer func() { r.addFileRows(name, rows) }()
+
+	clean := cfg.cellCleaner()
+	headers, err := scan.ReadHeader(reader, clean)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read headers: %w", err)
+	}
+	cfg.mapHeaders(name, headers)
+
+	// 結合する場合は、参照用のファイルの列を入力ファイルの列の後ろに加える
+	baseColumns := len(headers)
+	joinKey := -1
+	var joinColumns []int
+	if r.lookup != nil {
+		headers, joinKey, joinColumns = r.lookup.extendHeaders(headers, cfg.Join.Key)
+		if joinKey < 0 {
+			warnf(LogKindMissingColumn, name, "Join key column '%s' not found in %s", cfg.Join.Key, name)
+			r.addColumnWarnings(1)
+		}
+	}
+
+	headerMap := scan.HeaderIndex(headers)
+	cfg.addAliases(headerMap, name)
+
+	r.checkRequiredColumns(headerMap, name)
+	targetIndices, targetColumns := resolveColumns(cfg.Columns, headerMap, name)
+	r.addColumnWarnings(len(cfg.Columns) - len(targetColumns))
+
+	if len(targetIndices) == 0 {
+		warnf(LogKindFileSkipped, name, "None of the specified columns found in %s. Skipping file.", name)
+		return nil
+	}
+
+	var where *boundWhere
+	if cfg.Where != nil {
+		var err error
+		if where, err = cfg.Where.bind(headerMap); err != nil {
+			warnf(LogKindFileSkipped, name, "-where cannot be used with the columns of %s. Skipping file: %v", name, err)
+			r.addColumnWarnings(1)
+			return nil
+		}
+	}
+
+	// 絞り込みの条件を除くと関係のない行まで一致してしまうため、列がなければファイルごと読み飛ばす
+	listIndices := make([]int, len(cfg.ValueLists))
+	for i, l := range cfg.ValueLists {
+		idx, ok := headerMap[l.Column]
+		if !ok {
+			warnf(LogKindFileSkipped, name, "Column '%s' used in value list %s not found in %s. Skipping file.", l.Column, l.File, name)
+			r.addColumnWarnings(1)
+			return nil
+		}
+		listIndices[i] = idx
+	}
+	filters := bindConditions(cfg.Filters, headerMap, name, "filter")
+	if len(filters) < len(cfg.Filters) {
+		warnf(LogKindFileSkipped, name, "Columns used in -filter not found in %s. Skipping file.", name)
+		r.addColumnWarnings(len(cfg.Filters) - len(filters))
+		return nil
+	}
+
+	highlights := bindConditions(cfg.HighlightRules, headerMap, name, "highlight rule")
+	r.addColumnWarnings(len(cfg.HighlightRules) - len(highlights))
+	rowTags := bindRowTagRules(cfg.RowTagRules, headerMap, name)
+	r.addColumnWarnings(len(cfg.RowTagRules) - len(rowTags))
+	sortColumns := make([]string, len(cfg.Sort))
+	for i, key := range cfg.Sort {
+		sortColumns[i] = key.Column
+	}
+	sortIndices := r.resolveKeyColumns(sortColumns, headerMap, name, "sort key")
+	var dedupIndices []int
+	if cfg.Dedup {
+		dedupIndices = r.resolveKeyColumns(cfg.DedupBy, headerMap, name, "dedup key")
+	}
+	uniqueIndices := r.resolveKeyColumns(cfg.UniqueKey, headerMap, name, "unique key")
+	aggregateColumns := make([]string, len(cfg.Aggregates))
+	for i, agg := range cfg.Aggregates {
+		aggregateColumns[i] = agg.Column
+	}
+	aggregateIndices := r.resolveKeyColumns(aggregateColumns, headerMap, name, "aggregate")
+	topColumns := make([]string, len(cfg.TopValues))
+	for i, top := range cfg.TopValues {
+		topColumns[i] = top.Column
+	}
+	topIndices := r.resolveKeyColumns(topColumns, headerMap, name, "top values")
+	validationColumns := make([]string, len(cfg.Validations))
+	for i, v := range cfg.Validations {
+		validationColumns[i] = v.Column
+	}
+	validationIndices := r.resolveKeyColumns(validationColumns, headerMap, name, "validation")
+	var geoIndices []int
+	if cfg.Geo != nil {
+		geoIndices = r.resolveKeyColumns([]string{cfg.Geo.Lat, cfg.Geo.Lon}, headerMap, name, "geo")
+	}
+	var ganttIndices []int
+	if g := cfg.Gantt; g != nil {
+		columns := []string{g.Start, g.End}
+		if g.Label != "" {
+			columns = append(columns, g.Label)
+		}
+		ganttIndices = r.resolveKeyColumns(columns, headerMap, name, "gantt")
+	}
+	var pivotIndices []int
+	if p := cfg.Pivot; p != nil {
+		pivotIndices = r.resolveKeyColumns([]string{p.Rows, p.Cols}, headerMap, name, "pivot")
+		if p.Value != "" {
+			pivotIndices = append(pivotIndices, r.resolveKeyColumns([]string{p.Value}, headerMap, name, "pivot")...)
+		} else {
+			pivotIndices = append(pivotIndices, -1)
+		}
+		// 行または列の見出しがないファイルのレコードは集計しない
+		if pivotIndices[0] < 0 || pivotIndices[1] < 0 {
+			pivotIndices = nil
+		}
+	}
+	// 置換は組み込みのフックとして適用する。ここでは列がない場合の警告だけを出す
+	replaceColumns := make([]string, len(cfg.Replacements))
+	for i, rep := range cfg.Replacements {
+		replaceColumns[i] = rep.Column
+	}
+	r.resolveKeyColumns(replaceColumns, headerMap, name, "replace")
+	hooks := cfg.hooks()
+	row := Row{File: name, Headers: headers, index: headerMap}
+	mapColumns := make([]string, len(cfg.ValueMaps))
+	for i, m := range cfg.ValueMaps {
+		mapColumns[i] = m.Column
+	}
+	mapIndices := r.resolveKeyColumns(mapColumns, headerMap, name, "value map")
+	maskColumns := make([]string, len(cfg.Masks))
+	for i, m := range cfg.Masks {
+		maskColumns[i] = m.Column
+	}
+	maskIndices := r.resolveKeyColumns(maskColumns, headerMap, name, "mask")
+	tags := FileTags(cfg.TagRules, name)
+	if len(tags) > 0 {
+		debugf(name, "File %s tagged %s", name, strings.Join(tags, ", "))
+	}
+	numColumns := len(headers)
+	highlighted := make([]bool, numColumns)
+	codes := make([]string, numColumns)
+	violations := make([][]string, numColumns)
+	var violated []int
+
+	// 正規化する場合は、照合には正規化した値を、出力には元の値を使う
+	target := cfg.SearchTarget
+	normalize := cfg.Normalize.normalizer()
+	var normalized []string
+	if normalize != nil {
+		target = normalize(target)
+		for i := range filters {
+			filters[i].Value = normalize(filters[i].Value)
+		}
+		for i := range highlights {
+			highlights[i].Value = normalize(highlights[i].Value)
+		}
+		for i := range rowTags {
+			rowTags[i].Value = normalize(rowTags[i].Value)
+		}
+	}
+
+	// 並べ替える場合は前後の行が一致した行と離れてしまうため、前後の行を出力しない
+	contextRows := cfg.Context
+	if len(r.cfg.Sort) > 0 {
+		contextRows = 0
+	}
+	var before []Record
+	after := 0
+
+	matches, joinMisses := 0, 0
+	defer func() { r.addJoinMisses(joinMisses) }()
+	lineNum := 1
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		lineNum++
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if pErr, ok := err.(*csv.ParseError); ok {
+				return &parseError{line: pErr.Line, column: pErr.Column, err: pErr.Err}
+			}
+			return fmt.Errorf("failed to read record at line %d: %w", lineNum, err)
+		}
+		r.stats.addRow()
+		rows++
+		// フィールド数が可変の場合、短い行は不足する列を空文字列で補う
+		record = scan.Pad(record, baseColumns)
+		if clean != nil {
+			for i, cell := range record {
+				record[i] = clean(cell)
+			}
+		}
+		joinMissed := false
+		if joinKey >= 0 {
+			record = record[:baseColumns]
+			joined, ok := r.lookup.rows[record[joinKey]]
+			for _, idx := range joinColumns {
+				if ok {
+					record = append(record, joined[idx])
+				} else {
+					record = append(record, "")
+				}
+			}
+			joinMissed = !ok
+		}
+
+		if len(hooks) > 0 {
+			row.Line, row.Values = lineNum, record
+			keep, err := runBeforeHooks(hooks, &row)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue
+			}
+		}
+
+		values := record
+		if normalize != nil {
+			normalized = normalized[:0]
+			for _, cell := range record {
+				normalized = append(normalized, normalize(cell))
+			}
+			values = normalized
+		}
+
+		matched := true
+		if target != "" {
+			matched = false
+			for _, cell := range values {
+				if strings.Contains(cell, target) {
+					matched = true
+					break
+				}
+			}
+		}
+		for i, idx := range listIndices {
+			if !matched {
+				break
+			}
+			matched = idx < len(record) && r.valueLists[i][record[idx]] != cfg.ValueLists[i].Exclude
+		}
+		for _, f := range filters {
+			if !matched {
+				break
+			}
+			matched = f.match(values)
+		}
+		if matched && where != nil {
+			matched = where.match(record, name, lineNum)
+		}
+		if !matched && contextRows == 0 {
+			continue
+		}
+
+		if matched {
+			if cfg.MaxPerFile > 0 && matches == cfg.MaxPerFile {
+				return errFileLimit
+			}
+			matches++
+		}
+
+		rec := Record{File: name, Line: lineNum, Fields: make([]Field, 0, len(targetColumns)), Tags: tags, DuplicateFiles: r.duplicateFiles[name], Context: !matched}
+		clear(highlighted)
+		if matched {
+			for _, h := range highlights {
+				if h.match(values) {
+					highlighted[h.index] = true
+					rec.Highlighted = true
+				}
+			}
+			for _, t := range rowTags {
+				if t.match(values) && !slices.Contains(rec.RowTags, t.tag) {
+					rec.RowTags = append(rec.RowTags, t.tag)
+				}
+			}
+		}
+		// 検証は置き換えや伏せる前の値で行う
+		clear(violations)
+		violated = violated[:0]
+		if matched {
+			for i, idx := range validationIndices {
+				if idx >= 0 && !cfg.Validations[i].valid(record[idx]) {
+					violations[idx] = append(violations[idx], cfg.Validations[i].String())
+					violated = append(violated, i)
+				}
+			}
+		}
+		// 照合が済んだ後でコードを置き換えてから値を伏せ、以降の出力と集計にはその値だけを使う
+		clear(codes)
+		for i, idx := range mapIndices {
+			if idx < 0 {
+				continue
+			}
+			if label, ok := r.valueMaps[i][record[idx]]; ok {
+				codes[idx] = record[idx]
+				record[idx] = label
+			}
+		}
+		for i, idx := range maskIndices {
+			if idx >= 0 {
+				record[idx] = cfg.Masks[i].apply(record[idx], cfg.MaskKey)
+				codes[idx] = ""
+			}
+		}
+		for i, col := range targetColumns {
+			idx := targetIndices[i]
+			if idx < len(record) {
+				rec.Fields = append(rec.Fields, Field{Column: col, Value: record[idx], Highlighted: highlighted[idx], Code: codes[idx], Violations: violations[idx]})
+			}
+		}
+		for _, i := range violated {
+			// 違反の一覧には、出力と同じく置き換えや伏せた後の値を記録する
+			rec.violations = append(rec.violations, recordViolation{Rule: i, Value: record[validationIndices[i]]})
+			rec.Invalid = true
+		}
+		if !matched {
+			// 直前の一致の後の行はすぐに出力し、それ以外は次の一致に備えて直近の contextRows 行だけを保持する
+			if after > 0 {
+				after--
+				if err := fn(rec); err != nil {
+					return err
+				}
+			} else {
+				if len(before) == contextRows {
+					before = append(before[:0], before[1:]...)
+				}
+				before = append(before, rec)
+			}
+			continue
+		}
+		if len(dedupIndices) > 0 {
+			rec.dedupKey = strings.Join(scan.Pick(record, dedupIndices), "\x00")
+		}
+		if len(uniqueIndices) > 0 {
+			rec.uniqueKey = strings.Join(scan.Pick(record, uniqueIndices), "\x00")
+		}
+		rec.sortValues = scan.Pick(record, sortIndices)
+		if cfg.Timeline != "" {
+			rec.Date = timelineDate(rec.sortValues[0])
+		}
+		rec.aggregateValues = scan.Pick(record, aggregateIndices)
+		rec.topValues = scan.Pick(record, topIndices)
+		rec.pivotValues = scan.Pick(record, pivotIndices)
+		if len(geoIndices) > 0 {
+			v := scan.Pick(record, geoIndices)
+			rec.Location = parseGeoPoint(v[0], v[1])
+		}
+		if len(ganttIndices) > 0 {
+			v := append(scan.Pick(record, ganttIndices), "")
+			rec.Span = parseGanttSpan(v[0], v[1], v[2])
+		}
+		if joinMissed {
+			joinMisses++
+		}
+		if len(hooks) > 0 {
+			keep, err := runAfterHooks(hooks, &rec)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue
+			}
+		}
+		for _, c := range before {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		before = before[:0]
+		after = contextRows
+		r.stats.addMatch()
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package chiicgrep
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveColumns(t *testing.T) {
+	headerMap := map[string]int{"氏名": 0, "住所": 1, "金額": 2, "備考": 3}
+	tests := []struct {
+		name        string
+		cols        string
+		wantIndices []int
+		wantLabels  []string
+	}{
+		{
+			name:        "ヘッダーの順序ではなく指定した順序に並ぶ",
+			cols:        "備考,氏名,住所",
+			wantIndices: []int{3, 0, 1},
+			wantLabels:  []string{"備考", "氏名", "住所"},
+		},
+		{
+			name:        "同じ列を異なる表示名で2回出力する",
+			cols:        "金額:税抜,氏名,金額:税込",
+			wantIndices: []int{2, 0, 2},
+			wantLabels:  []string{"税抜", "氏名", "税込"},
+		},
+		{
+			name:        "表示名が空の場合は列名を使う",
+			cols:        "住所:",
+			wantIndices: []int{1},
+			wantLabels:  []string{"住所"},
+		},
+		{
+			name:        "ファイルにない列は除く",
+			cols:        "氏名,電話番号,住所",
+			wantIndices: []int{0, 1},
+			wantLabels:  []string{"氏名", "住所"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indices, resolved := resolveColumns(ParseColumns(tt.cols), headerMap, "test.csv")
+			if !reflect.DeepEqual(indices, tt.wantIndices) {
+				t.Errorf("indices = %v, want %v", indices, tt.wantIndices)
+			}
+			labels := make([]string, len(resolved))
+			for i, col := range resolved {
+				labels[i] = col.Label
+			}
+			if !reflect.DeepEqual(labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestProcessReaderColumnOrder(t *testing.T) {
+	const data = "氏名,住所,金額\n山田,東京,1000\n"
+	records, err := ProcessReader(strings.NewReader(data), "test.csv", Config{Columns: ParseColumns("金額:税込,氏名,金額:")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	var got []string
+	for _, f := range records[0].Fields {
+		got = append(got, f.Column.Label+"="+f.Value)
+	}
+	want := []string{"税込=1000", "氏名=山田", "金額=1000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
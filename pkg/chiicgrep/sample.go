@@ -0,0 +1,109 @@
+package chiicgrep
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+)
+
+// SampleMode はファイルごとに出力するレコードを選ぶ方法です。
+type SampleMode int
+
+const (
+	// SampleRandom は一致したレコードから無作為に選びます。
+	SampleRandom SampleMode = iota
+	// SampleHead は最初に一致したレコードを選びます。
+	SampleHead
+	// SampleTail は最後に一致したレコードを選びます。
+	SampleTail
+)
+
+// Sample は巨大なファイルの傾向を確かめるために、一致したレコードのうちファイルごとに Size 件だけを出力する設定です。
+// 選んだレコードは行の順序で出力します。前後の行（Config.Context）は出力しません。
+type Sample struct {
+	Mode SampleMode
+	Size int
+	// Seed は SampleRandom の乱数の種です。同じ種と入力からは同じレコードを選びます。
+	// 0 の場合は実行ごとに異なる種を使い、使った種を Summary.Sample に記録します。
+	Seed int64
+}
+
+// String は抽出の方法を説明する文字列を返します。
+func (s Sample) String() string {
+	switch s.Mode {
+	case SampleHead:
+		return fmt.Sprintf("ファイルごとに一致した行の先頭 %d 件", s.Size)
+	case SampleTail:
+		return fmt.Sprintf("ファイルごとに一致した行の末尾 %d 件", s.Size)
+	}
+	return fmt.Sprintf("ファイルごとに一致した行から無作為に %d 件（-seed %d）", s.Size, s.Seed)
+}
+
+// errSampleDone は SampleHead で必要な件数のレコードを出力し、残りの行を読む必要がないことを示します。
+var errSampleDone = errors.New("sample complete")
+
+// sampler は1つのファイルの一致したレコードから、Sample に従って出力するレコードを選びます。
+type sampler struct {
+	cfg     Sample
+	fn      func(Record) error
+	rng     *rand.Rand
+	seen    int
+	records []Record
+}
+
+// newSampler はファイル name のレコードを選んで fn に渡す sampler を作成します。
+// 乱数はファイルごとに種とファイル名から作るため、並行処理の順序によらず同じレコードを選びます。
+func newSampler(cfg Sample, name string, fn func(Record) error) *sampler {
+	s := &sampler{cfg: cfg, fn: fn}
+	if cfg.Mode == SampleRandom {
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		s.rng = rand.New(rand.NewPCG(uint64(cfg.Seed), h.Sum64()))
+	}
+	return s
+}
+
+// add は一致したレコードを受け取ります。SampleHead で件数に達すると errSampleDone を返します。
+func (s *sampler) add(rec Record) error {
+	if rec.Context {
+		return nil
+	}
+	s.seen++
+	switch s.cfg.Mode {
+	case SampleHead:
+		if err := s.fn(rec); err != nil {
+			return err
+		}
+		if s.seen >= s.cfg.Size {
+			return errSampleDone
+		}
+	case SampleTail:
+		// 出力の前に行の順序に並べ替えるため、古いものから順に上書きする
+		if len(s.records) < s.cfg.Size {
+			s.records = append(s.records, rec)
+		} else {
+			s.records[(s.seen-1)%s.cfg.Size] = rec
+		}
+	default:
+		// リザーバーサンプリングにより、レコード数によらず Size 件だけを保持する
+		if len(s.records) < s.cfg.Size {
+			s.records = append(s.records, rec)
+		} else if j := s.rng.IntN(s.seen); j < s.cfg.Size {
+			s.records[j] = rec
+		}
+	}
+	return nil
+}
+
+// flush は選んだレコードを行の順序で fn に渡します。
+func (s *sampler) flush() error {
+	slices.SortFunc(s.records, func(a, b Record) int { return a.Line - b.Line })
+	for _, rec := range s.records {
+		if err := s.fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
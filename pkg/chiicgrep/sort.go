@@ -0,0 +1,223 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortType は並べ替えの際の値の比較方法です。
+type SortType string
+
+const (
+	// SortAuto は空でない値がすべて数値なら数値、すべて日付なら日付、それ以外は文字列として比較します。
+	SortAuto SortType = ""
+	// SortNumber は数値として比較します。数値でない値は数値より前に並びます。
+	SortNumber SortType = "num"
+	// SortDate は日付として比較します。日付でない値は日付より前に並びます。
+	SortDate SortType = "date"
+	// SortString は文字列として比較します。
+	SortString SortType = "str"
+)
+
+// SortKey はレコードを並べ替えるキーとなる列です。
+type SortKey struct {
+	Column string
+	Desc   bool
+	Type   SortType
+}
+
+// ParseSortKeys は "列名[:asc|desc][:num|date|str],..." 形式の並べ替えキーを解析します。
+// 先に指定したキーほど優先されます。
+func ParseSortKeys(s string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Split(part, ":")
+		key := SortKey{Column: strings.TrimSpace(fields[0])}
+		if key.Column == "" {
+			return nil, fmt.Errorf("invalid sort key %q: missing column name", part)
+		}
+		for _, opt := range fields[1:] {
+			switch strings.ToLower(strings.TrimSpace(opt)) {
+			case "asc":
+				key.Desc = false
+			case "desc":
+				key.Desc = true
+			case "num":
+				key.Type = SortNumber
+			case "date":
+				key.Type = SortDate
+			case "str":
+				key.Type = SortString
+			default:
+				return nil, fmt.Errorf("invalid sort key %q: unknown option %q (expected asc, desc, num, date or str)", part, opt)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// dateLayouts は日付として解釈を試みる書式です。
+var dateLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-1-2 15:04",
+	"2006/1/2 15:04",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-1-2",
+	"2006/1/2",
+	"20060102",
+}
+
+// parseDate は s を dateLayouts のいずれかの書式で日付として解釈します。
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareSortValues は typ に従って a と b を比較します。typ に SortAuto は指定できません。
+func compareSortValues(a, b string, typ SortType) int {
+	switch typ {
+	case SortNumber:
+		fa, okA := parseNumber(a)
+		fb, okB := parseNumber(b)
+		if okA && okB {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+		return compareValidity(okA, okB)
+	case SortDate:
+		ta, okA := parseDate(a)
+		tb, okB := parseDate(b)
+		if okA && okB {
+			return ta.Compare(tb)
+		}
+		return compareValidity(okA, okB)
+	}
+	return strings.Compare(a, b)
+}
+
+// parseNumber は s を数値として解釈します。桁区切りのカンマは無視します。
+func parseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 64)
+	return f, err == nil
+}
+
+// compareValidity は解釈できなかった値を解釈できた値より前に並べるための比較結果を返します。
+func compareValidity(okA, okB bool) int {
+	switch {
+	case okA == okB:
+		return 0
+	case okB:
+		return -1
+	}
+	return 1
+}
+
+// detectSortType は values のうち空でない値がすべて数値なら SortNumber、
+// すべて日付なら SortDate、それ以外は SortString を返します。
+func detectSortType(values []string) SortType {
+	d := newSortTypeDetector()
+	for _, v := range values {
+		if !d.add(v) {
+			break
+		}
+	}
+	return d.sortType()
+}
+
+// sortTypeDetector は detectSortType と同じ判定を、値を1つずつ受け取りながら行います。
+type sortTypeDetector struct {
+	numbers, dates bool
+}
+
+// newSortTypeDetector は値をまだ受け取っていない sortTypeDetector を作成します。
+func newSortTypeDetector() sortTypeDetector {
+	return sortTypeDetector{numbers: true, dates: true}
+}
+
+// add は値 v を判定に加えます。以降の値によらず SortString に決まった場合は false を返します。
+func (d *sortTypeDetector) add(v string) bool {
+	if strings.TrimSpace(v) == "" {
+		return true
+	}
+	if d.numbers {
+		_, d.numbers = parseNumber(v)
+	}
+	if d.dates {
+		_, d.dates = parseDate(v)
+	}
+	return d.numbers || d.dates
+}
+
+// sortType はそれまでに受け取った値から決まる比較方法を返します。
+func (d sortTypeDetector) sortType() SortType {
+	switch {
+	case d.numbers:
+		return SortNumber
+	case d.dates:
+		return SortDate
+	}
+	return SortString
+}
+
+// sortTypes は keys の比較方法を返します。SortAuto のキーは detectors の判定に従います。
+func sortTypes(keys []SortKey, detectors []sortTypeDetector) []SortType {
+	types := make([]SortType, len(keys))
+	for i, key := range keys {
+		types[i] = key.Type
+		if types[i] == SortAuto {
+			types[i] = detectors[i].sortType()
+		}
+	}
+	return types
+}
+
+// sortRecords は keys に従って records を安定に並べ替えます。
+// 各レコードの rec.sortValues には keys と同じ順序でキーの値が設定されている必要があります。
+func sortRecords(records []Record, keys []SortKey) {
+	detectors := make([]sortTypeDetector, len(keys))
+	for i := range keys {
+		detectors[i] = newSortTypeDetector()
+		for _, rec := range records {
+			if !detectors[i].add(rec.sortValues[i]) {
+				break
+			}
+		}
+	}
+	sortRecordsBy(records, keys, sortTypes(keys, detectors))
+}
+
+// sortRecordsBy は keys と比較方法 types に従って records を安定に並べ替えます。
+func sortRecordsBy(records []Record, keys []SortKey, types []SortType) {
+	slices.SortStableFunc(records, func(a, b Record) int {
+		return compareRecords(a, b, keys, types)
+	})
+}
+
+// compareRecords は keys と比較方法 types に従って a と b を比較します。
+func compareRecords(a, b Record, keys []SortKey, types []SortType) int {
+	for i, key := range keys {
+		c := compareSortValues(a.sortValues[i], b.sortValues[i], types[i])
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
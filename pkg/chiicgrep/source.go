@@ -0,0 +1,88 @@
+package chiicgrep
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"go-ChiiCgrep/internal/discover"
+)
+
+// IgnoreFileName は入力フォルダ内で処理から除外するファイルとフォルダを指定するファイルの名前です。
+// 書式は .gitignore と同じで、各行のパターンはそのファイルがあるフォルダからの相対パスと照合します。
+// 下の階層のファイルの指定は上の階層の指定より優先され、同じファイル内では後の行が優先されます。
+const IgnoreFileName = discover.IgnoreFileName
+
+// Source は処理対象のCSVデータを提供します。
+// ファイルシステム以外（標準入力、アーカイブ、URL、データベースなど）からの入力も、
+// Source を実装することで同じ抽出処理に渡せます。
+type Source interface {
+	// List は処理対象の入力名を処理順に返します。
+	List(ctx context.Context) ([]string, error)
+	// Open は List が返した名前の入力を開きます。
+	Open(name string) (io.ReadCloser, error)
+}
+
+// FileSource はファイルシステム上のCSVファイルを提供する既定の Source です。
+// Root がファイルの場合はそのファイルのみ、フォルダの場合はその中のCSVファイルを対象とします。
+type FileSource struct {
+	Root      string
+	Recursive bool
+	// NoIgnore はフォルダ内の除外ファイル（IgnoreFileName）を無視し、すべてのCSVファイルを対象とします。
+	NoIgnore bool
+	// Exclude は対象から除くファイルの絶対パスです。
+	Exclude []string
+}
+
+// List は Root 以下のCSVファイルのパスを返します。
+func (s *FileSource) List(ctx context.Context) ([]string, error) {
+	return discover.CSVFiles(ctx, s.Root, discover.Options{
+		Recursive: s.Recursive,
+		UseIgnore: !s.NoIgnore,
+		Exclude:   s.Exclude,
+		Debugf:    debugf,
+		Warnf: func(path, format string, args ...any) {
+			warnf(LogKindReadError, path, format, args...)
+		},
+	})
+}
+
+// Open は指定されたパスのファイルを開きます。
+func (s *FileSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+// ReaderSource は単一の io.Reader を1つの入力として提供します。
+// 標準入力やメモリ上のデータを処理する場合に使用します。
+type ReaderSource struct {
+	Name   string
+	Reader io.Reader
+}
+
+// List は Name のみを返します。
+func (s *ReaderSource) List(ctx context.Context) ([]string, error) {
+	return []string{s.Name}, nil
+}
+
+// Open は Reader をそのまま返します。Close は Reader を閉じません。
+func (s *ReaderSource) Open(name string) (io.ReadCloser, error) {
+	return io.NopCloser(s.Reader), nil
+}
+
+// source は cfg に対応する Source を返します。
+// Source が設定されていない場合は InputPath と Recursive から FileSource を構成します。
+func (cfg Config) source() Source {
+	if cfg.Source != nil {
+		return cfg.Source
+	}
+	return &FileSource{Root: cfg.InputPath, Recursive: cfg.Recursive, NoIgnore: cfg.NoIgnore, Exclude: cfg.ExcludeFiles}
+}
+
+// listFiles は src の入力を列挙し、cfg.OnlyTags が指定されていればタグで絞り込みます。
+func (cfg Config) listFiles(ctx context.Context, src Source) ([]string, error) {
+	files, err := src.List(ctx)
+	if err != nil || len(cfg.OnlyTags) == 0 {
+		return files, err
+	}
+	return filterByTags(files, cfg.TagRules, cfg.OnlyTags), nil
+}
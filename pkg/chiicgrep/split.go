@@ -0,0 +1,140 @@
+package chiicgrep
+
+// UntaggedTag は TagSplitRenderer でタグのないファイルのレコードを振り分ける先の名前です。
+const UntaggedTag = "untagged"
+
+// TagSplitRenderer はレコードをファイルのタグ（Record.Tags）ごとに別の Renderer に振り分けます。
+// 複数のタグが付いたファイルのレコードはそれぞれのタグに、タグのないファイルのレコードは UntaggedTag に渡します。
+// 各タグの Renderer は、そのタグのレコードが最初に見つかった時点で open により作成します。
+//
+// 各 Renderer の End には、件数をそのタグのレコードに限った Summary を渡します。
+// Aggregates、TopValues、Pivot はすべてのレコードについての集計のため、振り分けた Renderer には渡しません。
+type TagSplitRenderer struct {
+	open  func(tag string) (Renderer, error)
+	byTag map[string]*tagSlice
+	order []string
+}
+
+// tagSlice は1つのタグに振り分けたレコードの出力先と件数です。
+type tagSlice struct {
+	renderer Renderer
+	matches  map[string]int
+	total    int
+}
+
+// NewTagSplitRenderer は open で作成した Renderer にタグごとのレコードを振り分ける TagSplitRenderer を作成します。
+func NewTagSplitRenderer(open func(tag string) (Renderer, error)) *TagSplitRenderer {
+	return &TagSplitRenderer{open: open, byTag: make(map[string]*tagSlice)}
+}
+
+// Begin は何もしません。各タグの Renderer の Begin は、その Renderer を作成した時点で呼び出します。
+func (t *TagSplitRenderer) Begin() error { return nil }
+
+// Render は rec をそのタグの Renderer に渡します。
+func (t *TagSplitRenderer) Render(rec Record) error {
+	tags := rec.Tags
+	if len(tags) == 0 {
+		tags = []string{UntaggedTag}
+	}
+	for _, tag := range tags {
+		s, err := t.slice(tag)
+		if err != nil {
+			return err
+		}
+		if err := s.renderer.Render(rec); err != nil {
+			return err
+		}
+		if !rec.Context {
+			s.matches[rec.File]++
+			s.total++
+		}
+	}
+	return nil
+}
+
+// slice は tag の出力先を返します。まだない場合は作成します。
+func (t *TagSplitRenderer) slice(tag string) (*tagSlice, error) {
+	if s, ok := t.byTag[tag]; ok {
+		return s, nil
+	}
+	r, err := t.open(tag)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Begin(); err != nil {
+		return nil, err
+	}
+	s := &tagSlice{renderer: r, matches: make(map[string]int)}
+	t.byTag[tag] = s
+	t.order = append(t.order, tag)
+	return s, nil
+}
+
+// End はタグごとの Summary を作り、それぞれの Renderer の End を呼び出します。最初のエラーを返します。
+func (t *TagSplitRenderer) End(sum Summary) error {
+	var first error
+	for _, tag := range t.order {
+		s := t.byTag[tag]
+		if err := s.renderer.End(partialSummary(sum, s.matches, s.total)); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Tags は振り分けたタグを、最初にレコードが見つかった順に返します。
+func (t *TagSplitRenderer) Tags() []string {
+	return t.order
+}
+
+// partialSummary は sum の件数を、一部のレコードだけを出力した Renderer のものに置き換えた Summary を返します。
+// matches はファイルごとの出力したレコード数、total はその合計です。
+// Aggregates、TopValues、Pivot はすべてのレコードについての集計のため、取り除きます。
+func partialSummary(sum Summary, matches map[string]int, total int) Summary {
+	ps := sum
+	ps.Matches = total
+	ps.FilesWithMatches = len(matches)
+	ps.Files = nil
+	for _, f := range sum.Files {
+		if n, ok := matches[f.File]; ok {
+			f.Matches = n
+			ps.Files = append(ps.Files, f)
+		}
+	}
+	ps.Aggregates, ps.TopValues, ps.Pivot = nil, nil, nil
+	return ps
+}
+
+// FilterRenderer は keep が true を返すレコードだけを r に渡します。
+// r の End には、件数を渡したレコードに限った Summary を渡します。集計については TagSplitRenderer と同じです。
+type FilterRenderer struct {
+	r       Renderer
+	keep    func(Record) bool
+	matches map[string]int
+	total   int
+}
+
+// NewFilterRenderer は新しい FilterRenderer を作成します。
+func NewFilterRenderer(r Renderer, keep func(Record) bool) *FilterRenderer {
+	return &FilterRenderer{r: r, keep: keep, matches: make(map[string]int)}
+}
+
+// Begin は r の Begin を呼び出します。
+func (f *FilterRenderer) Begin() error { return f.r.Begin() }
+
+// Render は keep が true を返す場合に rec を r に渡します。
+func (f *FilterRenderer) Render(rec Record) error {
+	if !f.keep(rec) {
+		return nil
+	}
+	if !rec.Context {
+		f.matches[rec.File]++
+		f.total++
+	}
+	return f.r.Render(rec)
+}
+
+// End は件数を渡したレコードに限った Summary で r の End を呼び出します。
+func (f *FilterRenderer) End(sum Summary) error {
+	return f.r.End(partialSummary(sum, f.matches, f.total))
+}
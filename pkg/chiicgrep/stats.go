@@ -0,0 +1,125 @@
+package chiicgrep
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ValueCount は列の1つの値と、その値を持つ行数です。
+type ValueCount struct {
+	Value string
+	Count int
+}
+
+// CountValues は cfg の条件に一致した行について、列 column の値ごとの行数を数えます。
+// cfg.Columns は無視され、column だけが抽出されます。column がないファイルは警告を出して除外されます。
+// 結果は行数の多い順に、行数が同じ場合は値の順に並びます。
+func CountValues(ctx context.Context, cfg Config, column string) ([]ValueCount, error) {
+	cfg.Columns = []Column{{Name: column, Label: column}}
+	counts := make(map[string]int)
+	err := Process(ctx, cfg, func(rec Record) error {
+		if len(rec.Fields) > 0 {
+			counts[rec.Fields[0].Value]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortValueCounts(counts), nil
+}
+
+// sortValueCounts は値ごとの行数を、行数の多い順に、行数が同じ場合は値の順に並べて返します。
+func sortValueCounts(counts map[string]int) []ValueCount {
+	result := make([]ValueCount, 0, len(counts))
+	for v, n := range counts {
+		result = append(result, ValueCount{Value: v, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+// GroupCount は GroupValues の1つのグループです。
+type GroupCount struct {
+	// Values はグループの列の値です。列と同じ順序で並びます。
+	Values []string
+	Count  int
+	// Sum は合計する列の値の集計です。合計する列を指定しない場合は空です。
+	Sum AggregateValues
+}
+
+// GroupValues は cfg の条件に一致した行について、列 columns の値の組み合わせごとの行数を数えます。
+// sum が空でない場合は、グループごとに列 sum の数値も合計します。columns のいずれかがないファイルは警告を出して除外されます。
+// 結果は1列目の値でまとめ、1列目の値ごとの行数の多い順に、その中では行数の多い順に、行数が同じ場合は値の順に並びます。
+// 1列目の値が同じグループが続くため、1列目の値ごとの小計を挟んで表示できます。
+func GroupValues(ctx context.Context, cfg Config, columns []string, sum string) ([]GroupCount, error) {
+	cfg.Columns = make([]Column, 0, len(columns)+1)
+	for _, col := range columns {
+		cfg.Columns = append(cfg.Columns, Column{Name: col, Label: col})
+	}
+	if sum != "" {
+		cfg.Columns = append(cfg.Columns, Column{Name: sum, Label: sum})
+	}
+	groups := make(map[string]*GroupCount)
+	err := Process(ctx, cfg, func(rec Record) error {
+		values := make(map[string]string, len(rec.Fields))
+		for _, f := range rec.Fields {
+			values[f.Column.Name] = f.Value
+		}
+		key := make([]string, len(columns))
+		for i, col := range columns {
+			v, ok := values[col]
+			if !ok {
+				return nil
+			}
+			key[i] = v
+		}
+		k := strings.Join(key, "\x00")
+		g, ok := groups[k]
+		if !ok {
+			g = &GroupCount{Values: key}
+			groups[k] = g
+		}
+		g.Count++
+		if sum != "" {
+			g.Sum.add(values[sum])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortGroupCounts(groups), nil
+}
+
+// sortGroupCounts はグループを GroupValues の順序で並べて返します。
+func sortGroupCounts(groups map[string]*GroupCount) []GroupCount {
+	result := make([]GroupCount, 0, len(groups))
+	firstCounts := make(map[string]int)
+	for _, g := range groups {
+		result = append(result, *g)
+		firstCounts[g.Values[0]] += g.Count
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Values[0] != b.Values[0] {
+			if firstCounts[a.Values[0]] != firstCounts[b.Values[0]] {
+				return firstCounts[a.Values[0]] > firstCounts[b.Values[0]]
+			}
+			return a.Values[0] < b.Values[0]
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return slices.Compare(a.Values, b.Values) < 0
+	})
+	return result
+}
@@ -0,0 +1,73 @@
+package chiicgrep
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGroupValues(t *testing.T) {
+	// b.csv には「担当」の列がないため、「担当」でまとめる場合は除外される
+	cfg := Config{InputPath: filepath.Join("testdata", "stats")}
+	type group struct {
+		Values []string
+		Count  int
+		Sum    float64
+	}
+	tests := []struct {
+		name    string
+		columns []string
+		sum     string
+		want    []group
+	}{
+		{
+			name:    "1列は行数の多い順で、b.csv も数える",
+			columns: []string{"部署"},
+			want: []group{
+				{Values: []string{"営業"}, Count: 4},
+				{Values: []string{"開発"}, Count: 3},
+				{Values: []string{"総務"}, Count: 1},
+			},
+		},
+		{
+			// 営業と開発はどちらも3行のため値の順に並び、それぞれの中では行数の多い順に並ぶ
+			name:    "2列は1列目の値ごとにまとめて並ぶ",
+			columns: []string{"部署", "担当"},
+			want: []group{
+				{Values: []string{"営業", "山田"}, Count: 2},
+				{Values: []string{"営業", "佐藤"}, Count: 1},
+				{Values: []string{"開発", "鈴木"}, Count: 2},
+				{Values: []string{"開発", "田中"}, Count: 1},
+				{Values: []string{"総務", "高橋"}, Count: 1},
+			},
+		},
+		{
+			name:    "グループごとに合計する",
+			columns: []string{"部署", "担当"},
+			sum:     "金額",
+			want: []group{
+				{Values: []string{"営業", "山田"}, Count: 2, Sum: 150},
+				{Values: []string{"営業", "佐藤"}, Count: 1, Sum: 200},
+				{Values: []string{"開発", "鈴木"}, Count: 2, Sum: 320},
+				{Values: []string{"開発", "田中"}, Count: 1, Sum: 10},
+				{Values: []string{"総務", "高橋"}, Count: 1, Sum: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := GroupValues(context.Background(), cfg, tt.columns, tt.sum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]group, len(groups))
+			for i, g := range groups {
+				got[i] = group{Values: g.Values, Count: g.Count, Sum: g.Sum.Sum}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupValues(%q, %q) = %v, want %v", tt.columns, tt.sum, got, tt.want)
+			}
+		})
+	}
+}
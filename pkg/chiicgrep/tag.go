@@ -0,0 +1,236 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// TagDef はタグの名前と表示色です。HTMLレポートでは .tag-<名前> のCSSクラスとして出力されます。
+type TagDef struct {
+	Name  string
+	Color string
+}
+
+// DefaultTags は定義しなくても使用できる組み込みのタグです。
+var DefaultTags = []TagDef{
+	{Name: "important", Color: "#d32f2f"},
+	{Name: "warning", Color: "#ef6c00"},
+	{Name: "archived", Color: "#757575"},
+	{Name: "completed", Color: "#2e7d32"},
+}
+
+// cssColorPattern はタグの色として受け付ける値（#RGB 形式の16進数またはCSSの色名）です。
+var cssColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// ParseTagDef は "名前:色" 形式のタグ定義を解析します。色は "#ff0000" のような16進数か "red" のような色名です。
+func ParseTagDef(s string) (TagDef, error) {
+	name, col, found := strings.Cut(s, ":")
+	name, col = strings.TrimSpace(name), strings.TrimSpace(col)
+	if !found || col == "" {
+		return TagDef{}, fmt.Errorf("invalid tag definition %q: expected <name>:<color>", s)
+	}
+	if err := validateTagName(name); err != nil {
+		return TagDef{}, err
+	}
+	if !cssColorPattern.MatchString(col) {
+		return TagDef{}, fmt.Errorf("invalid color %q for tag '%s': expected #rrggbb or a CSS color name", col, name)
+	}
+	return TagDef{Name: name, Color: col}, nil
+}
+
+// MergeTagDefs は DefaultTags に defs を加えたタグ定義を返します。同じ名前のタグは defs の色で上書きされます。
+func MergeTagDefs(defs []TagDef) []TagDef {
+	merged := append([]TagDef(nil), DefaultTags...)
+	for _, d := range defs {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == d.Name {
+				merged[i] = d
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+// validateTagName はタグ名がCSSのクラス名として使えるかを確認します。
+// 文字・数字・"-"・"_" のみを受け付けるため、日本語のタグ名も使用できます。
+func validateTagName(name string) error {
+	if name == "" {
+		return fmt.Errorf("tag name must not be empty")
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return fmt.Errorf("invalid tag name %q: only letters, digits, '-' and '_' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// TagRule はファイルにタグを付ける規則です。
+// パスに Keyword を含むファイル、Pattern が設定されている場合はパスが Pattern に一致するファイル、
+// Dir が設定されている場合は Dir 以下にあるファイルに Tag が付きます。
+type TagRule struct {
+	Tag     string
+	Keyword string
+	Pattern *regexp.Regexp
+	Dir     string
+	// BaseName が true の場合、パス全体ではなくファイル名の部分だけを照合します。Dir には影響しません。
+	BaseName bool
+}
+
+// ParseTagRule は "タグ:キーワード" または "タグ:/正規表現/" 形式のタグ付け規則を解析します。
+func ParseTagRule(s string) (TagRule, error) {
+	tag, keyword, found := strings.Cut(s, ":")
+	tag = strings.TrimSpace(tag)
+	if !found || keyword == "" {
+		return TagRule{}, fmt.Errorf("invalid tag rule %q: expected <tag>:<keyword> or <tag>:/<regexp>/", s)
+	}
+	if err := validateTagName(tag); err != nil {
+		return TagRule{}, err
+	}
+	rule := TagRule{Tag: tag, Keyword: keyword}
+	if len(keyword) >= 2 && strings.HasPrefix(keyword, "/") && strings.HasSuffix(keyword, "/") {
+		re, err := regexp.Compile(keyword[1 : len(keyword)-1])
+		if err != nil {
+			return TagRule{}, fmt.Errorf("invalid regexp in tag rule %q: %w", s, err)
+		}
+		rule.Keyword, rule.Pattern = "", re
+	}
+	return rule, nil
+}
+
+// ParseTagDirRule は "タグ:フォルダ" 形式の、フォルダ以下のファイルにタグを付ける規則を解析します。
+func ParseTagDirRule(s string) (TagRule, error) {
+	tag, dir, found := strings.Cut(s, ":")
+	tag = strings.TrimSpace(tag)
+	if !found || dir == "" {
+		return TagRule{}, fmt.Errorf("invalid tag rule %q: expected <tag>:<directory>", s)
+	}
+	if err := validateTagName(tag); err != nil {
+		return TagRule{}, err
+	}
+	return TagRule{Tag: tag, Dir: filepath.Clean(dir)}, nil
+}
+
+// String はタグ付け規則を ParseTagRule（Dir の場合は ParseTagDirRule）で解析できる形式で返します。
+func (r TagRule) String() string {
+	if r.Dir != "" {
+		return r.Tag + ":" + r.Dir
+	}
+	if r.Pattern != nil {
+		return r.Tag + ":/" + r.Pattern.String() + "/"
+	}
+	return r.Tag + ":" + r.Keyword
+}
+
+// matchFile はファイル name がこの規則に該当するかを判定します。
+func (r TagRule) matchFile(name string) bool {
+	if r.Dir != "" {
+		return isUnderDir(name, r.Dir)
+	}
+	if r.BaseName {
+		name = filepath.Base(name)
+	}
+	if r.Pattern != nil {
+		return r.Pattern.MatchString(name)
+	}
+	return strings.Contains(name, r.Keyword)
+}
+
+// filterByTags は files のうち、rules により only のタグのいずれかが付くファイルだけを返します。
+func filterByTags(files []string, rules []TagRule, only []string) []string {
+	var result []string
+	for _, f := range files {
+		for _, tag := range FileTags(rules, f) {
+			if slices.Contains(only, tag) {
+				result = append(result, f)
+				break
+			}
+		}
+		if len(result) == 0 || result[len(result)-1] != f {
+			debugf(f, "Skipping %s: not tagged with %s", f, strings.Join(only, ", "))
+		}
+	}
+	return result
+}
+
+// isUnderDir はファイル name がフォルダ dir 以下にあるかを判定します。
+// 相対パスはカレントディレクトリを基準に解決してから比較します。
+func isUnderDir(name, dir string) bool {
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absName)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// FileTags は rules のうち name に該当する規則のタグを、重複なく規則の順序で返します。
+func FileTags(rules []TagRule, name string) []string {
+	var tags []string
+	for _, r := range rules {
+		if r.matchFile(name) && !slices.Contains(tags, r.Tag) {
+			tags = append(tags, r.Tag)
+		}
+	}
+	return tags
+}
+
+// RowTagRule は条件を満たしたレコードにタグを付ける規則です。
+type RowTagRule struct {
+	Tag       string
+	Condition Condition
+}
+
+// ParseRowTagRule は "タグ:列名 演算子 値" 形式の行タグ規則を解析します。
+func ParseRowTagRule(s string) (RowTagRule, error) {
+	tag, expr, found := strings.Cut(s, ":")
+	tag = strings.TrimSpace(tag)
+	if !found || expr == "" {
+		return RowTagRule{}, fmt.Errorf("invalid row tag rule %q: expected <tag>:<column><op><value>", s)
+	}
+	if err := validateTagName(tag); err != nil {
+		return RowTagRule{}, err
+	}
+	cond, err := ParseCondition(expr)
+	if err != nil {
+		return RowTagRule{}, err
+	}
+	return RowTagRule{Tag: tag, Condition: cond}, nil
+}
+
+// String は行タグ規則を ParseRowTagRule で解析できる形式で返します。
+func (r RowTagRule) String() string {
+	return r.Tag + ":" + r.Condition.String()
+}
+
+// boundRowTagRule は列をファイルごとのインデックスに解決した RowTagRule です。
+type boundRowTagRule struct {
+	boundCondition
+	tag string
+}
+
+// bindRowTagRules は rules の列をヘッダー上のインデックスに解決します。
+// 列が見つからない規則は警告を出したうえで除外されます。
+func bindRowTagRules(rules []RowTagRule, headerMap map[string]int, name string) []boundRowTagRule {
+	bound := make([]boundRowTagRule, 0, len(rules))
+	for _, rule := range rules {
+		for _, b := range bindConditions([]Condition{rule.Condition}, headerMap, name, "row tag rule") {
+			bound = append(bound, boundRowTagRule{boundCondition: b, tag: rule.Tag})
+		}
+	}
+	return bound
+}
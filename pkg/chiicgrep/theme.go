@@ -0,0 +1,82 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ThemeVariable はHTMLレポートの配色を決めるCSSの変数（--名前）と、その既定値です。
+type ThemeVariable struct {
+	Name        string
+	Default     string
+	Description string
+}
+
+// ThemeVariables はHTMLOptions.Theme で値を変えられるCSSの変数です。
+var ThemeVariables = []ThemeVariable{
+	{"accent", "#0097a7", "見出しの線、枠、ボタンなどの基調色"},
+	{"accent-dark", "#00838f", "列名やリンクなどの文字の基調色"},
+	{"accent-light", "#e0f7fa", "ファイル名や日付の見出しの背景色"},
+	{"text", "#222", "本文の文字色"},
+	{"background", "#fafafa", "ページの背景色"},
+	{"surface", "#fff", "レコードや集計の枠の背景色"},
+	{"border", "#ddd", "レコードや表の枠線の色"},
+	{"muted", "#777", "行番号などの補足の文字色"},
+	{"value", "#2e7d32", "値の文字色"},
+	{"highlight", "#fff59d", "強調表示したセルの背景色"},
+	{"highlight-text", "#000", "強調表示したセルの文字色"},
+	{"highlight-border", "#f9a825", "強調表示したレコードの印と、地図や工程表での色"},
+	{"error", "#c62828", "読み込みエラーと中断の表示の色"},
+	{"bar", "#4dd0e1", "頻出値の棒の色"},
+}
+
+// themeValuePattern はCSSの変数の値として受け付ける文字です。"#003366"、"rgb(0, 51, 102)"、"navy" などを受け付け、
+// スタイルシートの外に出る記号（; { } < など）は受け付けません。
+var themeValuePattern = regexp.MustCompile(`^[#0-9A-Za-z(),.%/ -]+$`)
+
+// CheckTheme は theme のキーが ThemeVariables の名前で、値がCSSの色として使える文字だけからなるかを確認します。
+func CheckTheme(theme map[string]string) error {
+	for name, value := range theme {
+		if !isThemeVariable(name) {
+			names := make([]string, len(ThemeVariables))
+			for i, v := range ThemeVariables {
+				names[i] = v.Name
+			}
+			return fmt.Errorf("unknown theme color %q (expected %s)", name, strings.Join(names, ", "))
+		}
+		if !themeValuePattern.MatchString(strings.TrimSpace(value)) {
+			return fmt.Errorf("invalid value %q for theme color %q", value, name)
+		}
+	}
+	return nil
+}
+
+// isThemeVariable は name が ThemeVariables の名前かを返します。
+func isThemeVariable(name string) bool {
+	for _, v := range ThemeVariables {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// themeCSS は ThemeVariables の既定値を配色 palette の色に置き換え、さらに theme で上書きしたCSSの変数の定義を返します。
+// theme は CheckTheme で検証済みであることを前提とします。
+func themeCSS(palette Palette, theme map[string]string) string {
+	var b strings.Builder
+	b.WriteString(":root {")
+	for _, v := range ThemeVariables {
+		value := v.Default
+		if t, ok := palette.theme()[v.Name]; ok {
+			value = t
+		}
+		if t, ok := theme[v.Name]; ok {
+			value = strings.TrimSpace(t)
+		}
+		fmt.Fprintf(&b, " --%s: %s;", v.Name, value)
+	}
+	b.WriteString(" }\n")
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// timelineDate は Config.Timeline の列の値 v の日付の部分を "2006-01-02" 形式で返します。
+// 日付として解釈できない場合は空文字列を返します。
+func timelineDate(v string) string {
+	t, ok := parseDate(v)
+	if !ok {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// timelineDay はタイムライン表示の1日分の見出しです。
+type timelineDay struct {
+	date    string
+	records int
+}
+
+// timelineDayLabel は日付の見出しを返します。日付のないレコードは "日付なし" の見出しにまとめます。
+func timelineDayLabel(date string) string {
+	if date == "" {
+		return "日付なし"
+	}
+	return date
+}
+
+// renderTimeline は Config.Timeline の日付ごとの見出しの下に、1件のレコードを出力します。
+// レコードは日付の順に渡されることを前提とし、日付が切り替わると新しい見出しを開始します。
+func (r *HTMLRenderer) renderTimeline(sw *render.Writer, rec Record) {
+	if len(r.days) == 0 || rec.Date != r.days[len(r.days)-1].date {
+		if len(r.days) > 0 {
+			sw.WriteString("</div>\n")
+		}
+		sw.Printf("<div class=\"day\" id=\"day-%d\">\n<h2 class=\"day-heading\">%s</h2>\n", len(r.days), html.EscapeString(timelineDayLabel(rec.Date)))
+		r.days = append(r.days, timelineDay{date: rec.Date})
+	}
+	r.days[len(r.days)-1].records++
+	if r.seenFiles == nil {
+		r.seenFiles = make(map[string]bool)
+	}
+	if !r.seenFiles[rec.File] {
+		r.seenFiles[rec.File] = true
+		r.legend.addFile(rec.Tags)
+	}
+	r.legend.addRecord(rec.Tags)
+
+	recordClass := "record"
+	if rec.Highlighted {
+		recordClass = "record highlighted"
+	}
+	id := recordID(rec)
+	sw.Printf("<div class=\"%s\" id=\"%s\" data-file=\"%s\" data-line=\"%d\"", recordClass, id, html.EscapeString(rec.File), rec.Line)
+	if len(rec.Tags) > 0 {
+		sw.Printf(" data-tags=\"%s\"", html.EscapeString(strings.Join(rec.Tags, " ")))
+	}
+	sw.Printf(">\n<div class=\"record-info\">File: %s, Line: %d", html.EscapeString(rec.File), rec.Line)
+	for _, tag := range append(append([]string(nil), rec.Tags...), rec.RowTags...) {
+		t := html.EscapeString(tag)
+		sw.Printf("<span class=\"tag tag-%s\">%s</span>", t, t)
+	}
+	writePermalink(sw, id)
+	sw.WriteString("</div>\n")
+	r.writeFields(sw, rec)
+	sw.WriteString("</div>\n")
+}
+
+// writeTimelineNav は日付の見出しへのリンクを並べたナビゲーションを出力します。
+func (r *HTMLRenderer) writeTimelineNav(sw *render.Writer) {
+	if len(r.days) == 0 {
+		return
+	}
+	sw.Printf("<nav class=\"timeline-nav\">\n<div class=\"timeline-nav-title\">%s</div>\n", html.EscapeString(r.opts.Timeline))
+	for i, d := range r.days {
+		sw.Printf("<a href=\"#day-%d\">%s <span class=\"count\">%s</span></a>\n", i, html.EscapeString(timelineDayLabel(d.date)), fmt.Sprintf("%d件", d.records))
+	}
+	sw.WriteString("</nav>\n")
+}
@@ -0,0 +1,115 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-ChiiCgrep/internal/render"
+)
+
+// defaultTopN は TopValues の件数を省略した場合に出力する値の数です。
+const defaultTopN = 10
+
+// TopValues は出力したレコードについて、列の値を出現回数の多い順に N 件数える指定です。
+type TopValues struct {
+	Column string
+	N      int
+}
+
+// ParseTopValues は "列名:N" 形式の指定を解析します。N を省略した場合は10件です。
+func ParseTopValues(s string) (TopValues, error) {
+	column, n, found := strings.Cut(s, ":")
+	top := TopValues{Column: strings.TrimSpace(column), N: defaultTopN}
+	if top.Column == "" {
+		return TopValues{}, fmt.Errorf("invalid top values %q: missing column name", s)
+	}
+	if found && strings.TrimSpace(n) != "" {
+		v, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || v <= 0 {
+			return TopValues{}, fmt.Errorf("invalid top values %q: count must be a positive integer", s)
+		}
+		top.N = v
+	}
+	return top, nil
+}
+
+// TopValuesResult は Config.TopValues の1つの指定に対する集計結果です。
+type TopValuesResult struct {
+	TopValues
+	// Total は数えたレコードの数です。割合の分母になります。
+	Total int
+	// Distinct は異なる値の数です。
+	Distinct int
+	// Values は出現回数の多い順に並んだ最大 N 件の値です。同数の場合は値の順に並びます。
+	Values []ValueCount
+	// Others は Values に含まれなかった値を持つレコードの数です。
+	Others int
+}
+
+// Percent は count が Total に占める割合を百分率で返します。
+func (t TopValuesResult) Percent(count int) float64 {
+	if t.Total == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(t.Total)
+}
+
+// topValueCounts は1つの列の値ごとの出現回数です。
+type topValueCounts struct {
+	total  int
+	counts map[string]int
+}
+
+// countTopValues は Config.TopValues が指定されている場合に、fn に渡すレコードの値を数えるよう fn を包みます。
+func (r *run) countTopValues(fn func(Record) error) func(Record) error {
+	if len(r.cfg.TopValues) == 0 {
+		return fn
+	}
+	r.topCounts = make([]topValueCounts, len(r.cfg.TopValues))
+	for i := range r.topCounts {
+		r.topCounts[i].counts = make(map[string]int)
+	}
+	return func(rec Record) error {
+		r.mu.Lock()
+		for i, v := range rec.topValues {
+			r.topCounts[i].total++
+			r.topCounts[i].counts[v]++
+		}
+		r.mu.Unlock()
+		return fn(rec)
+	}
+}
+
+// topValueResults は集計結果を返します。r.mu を保持した状態で呼び出します。
+func (r *run) topValueResults() []TopValuesResult {
+	if len(r.topCounts) == 0 {
+		return nil
+	}
+	results := make([]TopValuesResult, len(r.topCounts))
+	for i, tc := range r.topCounts {
+		top := r.cfg.TopValues[i]
+		values := sortValueCounts(tc.counts)
+		res := TopValuesResult{TopValues: top, Total: tc.total, Distinct: len(values)}
+		if len(values) > top.N {
+			values = values[:top.N]
+		}
+		res.Values = values
+		res.Others = tc.total
+		for _, v := range values {
+			res.Others -= v.Count
+		}
+		results[i] = res
+	}
+	return results
+}
+
+// PieChart は値ごとの件数 values と、values に含まれない値の件数 others を、凡例付きの円グラフとして
+// インラインSVGで返します。空の値は "(空欄)" と表示します。件数が1件もない場合は空文字列を返します。
+func PieChart(values []ValueCount, others int) string {
+	slices := make([]render.Slice, len(values))
+	for i, v := range values {
+		slices[i] = render.Slice{Label: topValueLabel(v.Value), Count: v.Count}
+	}
+	return render.PieChart(slices, others)
+}
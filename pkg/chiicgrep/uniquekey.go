@@ -0,0 +1,59 @@
+package chiicgrep
+
+import (
+	"slices"
+	"strings"
+)
+
+// KeyLocation はレコードのファイルと行番号です。
+type KeyLocation struct {
+	File string
+	Line int
+}
+
+// KeyConflict は Config.UniqueKey の値が同じレコードが、実行全体で2件以上あったことを示します。
+type KeyConflict struct {
+	// Key は Config.UniqueKey の列の値です。列と同じ順序で並びます。
+	Key []string
+	// Locations は値が同じレコードの位置で、出力した順に並びます。
+	Locations []KeyLocation
+}
+
+// checkUniqueKeys は Config.UniqueKey が指定されている場合に、fn に渡すレコードのキーの値が既に渡したレコードと
+// 重複していないかを調べるよう fn を包みます。重複したレコードには、最初に同じ値だったレコードの位置を設定します。
+// キーの値がすべて空のレコードは調べません。
+func (r *run) checkUniqueKeys(fn func(Record) error) func(Record) error {
+	if len(r.cfg.UniqueKey) == 0 {
+		return fn
+	}
+	r.uniqueKeys = make(map[string][]KeyLocation)
+	return func(rec Record) error {
+		if strings.Trim(rec.uniqueKey, "\x00") == "" {
+			return fn(rec)
+		}
+		r.mu.Lock()
+		locs := r.uniqueKeys[rec.uniqueKey]
+		if len(locs) > 0 {
+			first := locs[0]
+			rec.DuplicateOf = &first
+			if len(locs) == 1 {
+				r.keyConflicts = append(r.keyConflicts, rec.uniqueKey)
+			}
+		}
+		r.uniqueKeys[rec.uniqueKey] = append(locs, KeyLocation{File: rec.File, Line: rec.Line})
+		r.mu.Unlock()
+		return fn(rec)
+	}
+}
+
+// keyConflictResults は重複したキーを、2件目が見つかった順に返します。r.mu を保持した状態で呼び出します。
+func (r *run) keyConflictResults() []KeyConflict {
+	if len(r.keyConflicts) == 0 {
+		return nil
+	}
+	conflicts := make([]KeyConflict, len(r.keyConflicts))
+	for i, key := range r.keyConflicts {
+		conflicts[i] = KeyConflict{Key: strings.Split(key, "\x00"), Locations: slices.Clone(r.uniqueKeys[key])}
+	}
+	return conflicts
+}
@@ -0,0 +1,260 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validationMaxExamples は Summary.Validations に、ルールごとに記録する違反した箇所の最大数です。
+const validationMaxExamples = 100
+
+// ValidationKind は検証ルールの種類です。
+type ValidationKind string
+
+const (
+	// ValidateRegex は値が正規表現に一致することを検証します。
+	ValidateRegex ValidationKind = "regex"
+	// ValidateRange は値が数値または日付で、範囲内にあることを検証します。
+	ValidateRange ValidationKind = "range"
+	// ValidateLength は値の文字数が範囲内にあることを検証します。
+	ValidateLength ValidationKind = "len"
+	// ValidateEnum は値が列挙した値のいずれかであることを検証します。
+	ValidateEnum ValidationKind = "enum"
+	// ValidateType は値が整数（int）、数値（number）、日付（date）として解釈できることを検証します。
+	ValidateType ValidationKind = "type"
+	// ValidateRequired は値が空でないことを検証します。
+	ValidateRequired ValidationKind = "required"
+)
+
+// ValidationRule は列の値の検証ルールです。空の値は ValidateRequired 以外のルールでは検証しません。
+type ValidationRule struct {
+	Column string
+	Kind   ValidationKind
+	// Arg はルールの引数（正規表現、範囲、列挙した値など）の元の表記です。
+	Arg string
+
+	pattern *regexp.Regexp
+	// min と max は ValidateRange と ValidateLength の範囲です。省略した側は nil です。
+	min, max *float64
+	// dates は ValidateRange の範囲が日付であることを示します。min と max は Unix 時刻（秒）です。
+	dates  bool
+	values []string
+}
+
+// String は "列:種類:引数" 形式の表記を返します。
+func (v ValidationRule) String() string {
+	if v.Arg == "" {
+		return v.Column + ":" + string(v.Kind)
+	}
+	return v.Column + ":" + string(v.Kind) + ":" + v.Arg
+}
+
+// ParseValidationRule は "列:種類:引数" 形式の検証ルールを解析します。種類は次のとおりです。
+//
+//	regex:<正規表現>      値が正規表現に一致する（例: "メール:regex:^[^@]+@[^@]+$"）
+//	range:<最小>..<最大>  値が数値または日付で範囲内にある。どちらかは省略できる（例: "金額:range:0..10000000"）
+//	len:<最小>..<最大>    値の文字数が範囲内にある（例: "郵便番号:len:7..8"）
+//	enum:<値>|<値>...     値が列挙した値のいずれかである（例: "区分:enum:A|B|C"）
+//	type:<int|number|date> 値を整数、数値、日付として解釈できる
+//	required              値が空でない
+func ParseValidationRule(s string) (ValidationRule, error) {
+	column, rest, found := strings.Cut(s, ":")
+	column = strings.TrimSpace(column)
+	if !found || column == "" {
+		return ValidationRule{}, fmt.Errorf("invalid validation rule %q: expected <column>:<kind>[:<argument>]", s)
+	}
+	kind, arg, _ := strings.Cut(rest, ":")
+	v := ValidationRule{Column: column, Kind: ValidationKind(strings.ToLower(strings.TrimSpace(kind))), Arg: arg}
+	var err error
+	switch v.Kind {
+	case ValidateRegex:
+		if v.pattern, err = regexp.Compile(arg); err != nil {
+			return ValidationRule{}, fmt.Errorf("invalid regexp in validation rule %q: %w", s, err)
+		}
+	case ValidateRange:
+		if v.min, v.max, v.dates, err = parseValidationRange(arg, true); err != nil {
+			return ValidationRule{}, fmt.Errorf("invalid validation rule %q: %w", s, err)
+		}
+	case ValidateLength:
+		if v.min, v.max, _, err = parseValidationRange(arg, false); err != nil {
+			return ValidationRule{}, fmt.Errorf("invalid validation rule %q: %w", s, err)
+		}
+	case ValidateEnum:
+		for _, value := range strings.Split(arg, "|") {
+			v.values = append(v.values, strings.TrimSpace(value))
+		}
+	case ValidateType:
+		v.Arg = strings.ToLower(strings.TrimSpace(arg))
+		if v.Arg != "int" && v.Arg != "number" && v.Arg != "date" {
+			return ValidationRule{}, fmt.Errorf("invalid validation rule %q: type must be int, number or date", s)
+		}
+	case ValidateRequired:
+		if arg != "" {
+			return ValidationRule{}, fmt.Errorf("invalid validation rule %q: required takes no argument", s)
+		}
+	default:
+		return ValidationRule{}, fmt.Errorf("invalid validation rule %q: unknown kind %q (expected regex, range, len, enum, type or required)", s, kind)
+	}
+	return v, nil
+}
+
+// parseValidationRange は "<最小>..<最大>" 形式の範囲を解析します。
+// allowDates の場合、数値として解釈できない境界は日付として解釈し、dates を true にします。
+func parseValidationRange(s string, allowDates bool) (min, max *float64, dates bool, err error) {
+	lo, hi, found := strings.Cut(s, "..")
+	lo, hi = strings.TrimSpace(lo), strings.TrimSpace(hi)
+	if !found || (lo == "" && hi == "") {
+		return nil, nil, false, fmt.Errorf("expected <min>..<max>")
+	}
+	bound := func(b string) (*float64, bool, error) {
+		if b == "" {
+			return nil, false, nil
+		}
+		if f, ok := parseNumber(b); ok {
+			return &f, false, nil
+		}
+		if allowDates {
+			if t, ok := parseDate(b); ok {
+				f := float64(t.Unix())
+				return &f, true, nil
+			}
+		}
+		return nil, false, fmt.Errorf("invalid bound %q", b)
+	}
+	min, loDate, err := bound(lo)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	max, hiDate, err := bound(hi)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if lo != "" && hi != "" && loDate != hiDate {
+		return nil, nil, false, fmt.Errorf("bounds must both be numbers or both be dates")
+	}
+	if min != nil && max != nil && *min > *max {
+		return nil, nil, false, fmt.Errorf("minimum is greater than maximum")
+	}
+	return min, max, loDate || hiDate, nil
+}
+
+// valid は value がルールを満たすかを返します。
+func (v ValidationRule) valid(value string) bool {
+	if strings.TrimSpace(value) == "" {
+		return v.Kind != ValidateRequired
+	}
+	switch v.Kind {
+	case ValidateRegex:
+		return v.pattern.MatchString(value)
+	case ValidateRange:
+		var f float64
+		if v.dates {
+			t, ok := parseDate(value)
+			if !ok {
+				return false
+			}
+			f = float64(t.Unix())
+		} else {
+			n, ok := parseNumber(value)
+			if !ok {
+				return false
+			}
+			f = n
+		}
+		return inRange(f, v.min, v.max)
+	case ValidateLength:
+		return inRange(float64(utf8.RuneCountInString(value)), v.min, v.max)
+	case ValidateEnum:
+		return slices.Contains(v.values, strings.TrimSpace(value))
+	case ValidateType:
+		switch v.Arg {
+		case "int":
+			_, err := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(value), ",", ""), 10, 64)
+			return err == nil
+		case "number":
+			_, ok := parseNumber(value)
+			return ok
+		default:
+			_, ok := parseDate(value)
+			return ok
+		}
+	}
+	return true
+}
+
+// inRange は f が min 以上 max 以下かを返します。nil の境界は判定しません。
+func inRange(f float64, min, max *float64) bool {
+	return (min == nil || f >= *min) && (max == nil || f <= *max)
+}
+
+// Violation は検証ルールに違反した1つの値です。
+type Violation struct {
+	File  string
+	Line  int
+	Value string
+}
+
+// ValidationResult は Config.Validations の1つのルールに対する検証の結果です。
+type ValidationResult struct {
+	Rule ValidationRule
+	// Count は違反したレコードの数です。
+	Count int
+	// Examples は違反した箇所のうち、出力した順に最大 validationMaxExamples 件です。
+	Examples []Violation
+}
+
+// recordViolation はレコードが違反した検証ルールの位置と、その値です。
+// 一時ファイルに書き出せるよう、項目を公開しています。
+type recordViolation struct {
+	Rule  int
+	Value string
+}
+
+// countViolations は Config.Validations が指定されている場合に、fn に渡すレコードの違反を数えるよう fn を包みます。
+func (r *run) countViolations(fn func(Record) error) func(Record) error {
+	if len(r.cfg.Validations) == 0 {
+		return fn
+	}
+	r.validations = make([]ValidationResult, len(r.cfg.Validations))
+	for i, v := range r.cfg.Validations {
+		r.validations[i].Rule = v
+	}
+	return func(rec Record) error {
+		r.mu.Lock()
+		for _, v := range rec.violations {
+			res := &r.validations[v.Rule]
+			res.Count++
+			if len(res.Examples) < validationMaxExamples {
+				res.Examples = append(res.Examples, Violation{File: rec.File, Line: rec.Line, Value: v.Value})
+			}
+		}
+		r.mu.Unlock()
+		return fn(rec)
+	}
+}
+
+// validationResults は検証の結果を返します。r.mu を保持した状態で呼び出します。
+func (r *run) validationResults() []ValidationResult {
+	if len(r.validations) == 0 {
+		return nil
+	}
+	results := make([]ValidationResult, len(r.validations))
+	for i, res := range r.validations {
+		res.Examples = slices.Clone(res.Examples)
+		results[i] = res
+	}
+	return results
+}
+
+// Violations はルールごとの違反の件数の合計を返します。
+func (s Summary) Violations() int {
+	n := 0
+	for _, v := range s.Validations {
+		n += v.Count
+	}
+	return n
+}
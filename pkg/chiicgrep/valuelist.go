@@ -0,0 +1,66 @@
+package chiicgrep
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ValueList は列の値を、リストのファイルにある値に限る、またはリストにある値を除く指定です。
+// 社員番号の一覧のように、-filter を何度も指定しては書ききれない数千件の値で絞り込む場合に使用します。
+type ValueList struct {
+	Column string
+	// File は1行に1つの値を書いたテキストファイルのパスです。空行は無視します。
+	File string
+	// Exclude が true の場合は、値がリストにある行を除きます。false の場合は、値がリストにある行だけを残します。
+	Exclude bool
+}
+
+// ParseValueList は "列名:リスト.txt" 形式の指定を解析します。
+func ParseValueList(s string, exclude bool) (ValueList, error) {
+	column, file, found := strings.Cut(s, ":")
+	l := ValueList{Column: strings.TrimSpace(column), File: strings.TrimSpace(file), Exclude: exclude}
+	if !found || l.Column == "" || l.File == "" {
+		return ValueList{}, fmt.Errorf("invalid value list %q: expected column:file", s)
+	}
+	return l, nil
+}
+
+// String は ParseValueList で解析できる形式で l を返します。
+func (l ValueList) String() string {
+	return l.Column + ":" + l.File
+}
+
+// loadValueList は l のリストを読み込み、値の集合を返します。
+// 値には入力のセルと同じ前後の空白の除去（Config.TrimCells など）を適用します。
+func loadValueList(cfg Config, l ValueList) (map[string]bool, error) {
+	f, err := os.Open(l.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open value list: %w", err)
+	}
+	defer f.Close()
+
+	clean := cfg.cellCleaner()
+	values := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	first := true
+	for sc.Scan() {
+		v := strings.TrimSuffix(sc.Text(), "\r")
+		if first {
+			v = strings.TrimPrefix(v, "\ufeff")
+			first = false
+		}
+		if clean != nil {
+			v = clean(v)
+		}
+		if v != "" {
+			values[v] = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read value list %s: %w", l.File, err)
+	}
+	debugf(l.File, "Loaded %d values from value list %s", len(values), l.File)
+	return values, nil
+}
@@ -0,0 +1,76 @@
+package chiicgrep
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go-ChiiCgrep/internal/scan"
+)
+
+// ValueMap はコード値を表示用の名前に置き換える、列と対応表のファイルの指定です。
+type ValueMap struct {
+	Column string
+	// File は1列目にコード、2列目に表示名を持つCSVファイルのパスです。1行目は見出しとして読み飛ばします。
+	File string
+}
+
+// ParseValueMap は "列名:対応表.csv" 形式の指定を解析します。
+func ParseValueMap(s string) (ValueMap, error) {
+	column, file, found := strings.Cut(s, ":")
+	m := ValueMap{Column: strings.TrimSpace(column), File: strings.TrimSpace(file)}
+	if !found || m.Column == "" || m.File == "" {
+		return ValueMap{}, fmt.Errorf("invalid value map %q: expected column:file", s)
+	}
+	return m, nil
+}
+
+// String は ParseValueMap で解析できる形式で m を返します。
+func (m ValueMap) String() string {
+	return m.Column + ":" + m.File
+}
+
+// loadValueMap は m の対応表を読み込み、コードから表示名への対応を返します。
+// コードが重複する行は最初の行を採用し、警告を出します。
+func loadValueMap(cfg Config, m ValueMap) (map[string]string, error) {
+	f, err := os.Open(m.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open value map: %w", err)
+	}
+	defer f.Close()
+
+	reader := scan.NewReader(f, cfg.scanOptions())
+	reader.FieldsPerRecord = -1
+	if _, err := reader.Read(); err != nil {
+		return nil, fmt.Errorf("failed to read headers of value map %s: %w", m.File, err)
+	}
+	clean := cfg.cellCleaner()
+	if clean == nil {
+		clean = func(s string) string { return s }
+	}
+	labels := make(map[string]string)
+	duplicates := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value map %s: %w", m.File, err)
+		}
+		if len(record) < 2 {
+			continue
+		}
+		code := clean(record[0])
+		if _, exists := labels[code]; exists {
+			duplicates++
+			continue
+		}
+		labels[code] = clean(record[1])
+	}
+	if duplicates > 0 {
+		warnf(LogKindDuplicateKey, m.File, "%d rows with duplicate codes in value map %s were ignored", duplicates, m.File)
+	}
+	return labels, nil
+}
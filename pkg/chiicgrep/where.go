@@ -0,0 +1,101 @@
+package chiicgrep
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// WhereExpr は行を絞り込む条件式です。式は expr（https://expr-lang.org）の構文で記述し、列の値を列名で参照します。
+// 列の値はすべて文字列のため、数値として比較する場合は int()、float() または num() で変換します。
+// 列名に空白や記号を含む場合は col("列名") で参照します。
+//
+//	int(金額) > 10000 && 備考 contains "至急"
+//	num(請求額) != num(入金額)
+//	col("発注 番号") startsWith "PO-" || 部署 in ["営業", "企画"]
+//
+// ファイルに式で参照する列がない場合や、型が合わない場合は、そのファイルの行は一致しません。
+type WhereExpr struct {
+	src string
+}
+
+// whereFuncs は条件式で使用できる、expr の組み込み関数以外の関数です。row は評価中の行です。
+func whereFuncs(row func() map[string]any) []expr.Option {
+	return []expr.Option{
+		// num はカンマ区切りの数値（"1,234"）も解釈する
+		expr.Function("num", func(params ...any) (any, error) {
+			f, ok := parseNumber(params[0].(string))
+			if !ok {
+				return nil, fmt.Errorf("not a number: %q", params[0])
+			}
+			return f, nil
+		}, new(func(string) float64)),
+		expr.Function("col", func(params ...any) (any, error) {
+			v, ok := row()[params[0].(string)]
+			if !ok {
+				return nil, fmt.Errorf("column %q not found", params[0])
+			}
+			return v, nil
+		}, new(func(string) string)),
+	}
+}
+
+// ParseWhere は条件式の構文を検証します。列の有無と型は、ファイルごとにヘッダーに対して検証します。
+func ParseWhere(s string) (*WhereExpr, error) {
+	opts := append([]expr.Option{expr.AllowUndefinedVariables(), expr.AsBool()}, whereFuncs(nil)...)
+	if _, err := expr.Compile(s, opts...); err != nil {
+		return nil, fmt.Errorf("invalid -where expression: %v", err)
+	}
+	return &WhereExpr{src: s}, nil
+}
+
+// String は条件式を返します。
+func (w *WhereExpr) String() string {
+	return w.src
+}
+
+// boundWhere はファイルの列に対してコンパイルした WhereExpr です。
+type boundWhere struct {
+	program *vm.Program
+	headers map[string]int
+	env     map[string]any
+	vm      vm.VM
+	// failed は評価のエラーを一度警告したことを示します。
+	failed bool
+}
+
+// bind は w をファイルのヘッダー headerMap の列に対してコンパイルします。
+func (w *WhereExpr) bind(headerMap map[string]int) (*boundWhere, error) {
+	b := &boundWhere{headers: headerMap, env: make(map[string]any, len(headerMap))}
+	for h := range headerMap {
+		b.env[h] = ""
+	}
+	opts := append([]expr.Option{expr.Env(b.env), expr.AsBool()}, whereFuncs(func() map[string]any { return b.env })...)
+	program, err := expr.Compile(w.src, opts...)
+	if err != nil {
+		return nil, err
+	}
+	b.program = program
+	return b, nil
+}
+
+// match は行 record が条件式を満たすかを判定します。評価に失敗した行は一致しないものとします。
+func (b *boundWhere) match(record []string, name string, line int) bool {
+	for h, i := range b.headers {
+		if i < len(record) {
+			b.env[h] = record[i]
+		} else {
+			b.env[h] = ""
+		}
+	}
+	out, err := b.vm.Run(b.program, b.env)
+	if err != nil {
+		if !b.failed {
+			b.failed = true
+			warnf(LogKindWhereError, name, "-where could not be evaluated at line %d in %s (such rows are skipped): %v", line, name, err)
+		}
+		return false
+	}
+	return out.(bool)
+}